@@ -25,7 +25,7 @@ import (
 	"google.golang.org/grpc"
 )
 
-// 将指定钱包里的TRX/USDT 转到 指定钱包
+// 将指定钱包里的TRX/USDT 转到 指定钱包,只用于临时处理,正式的归集使用 tron/sweep
 
 var (
 	filePath  = `user_wallet.json`
@@ -23,6 +23,11 @@ type TradeNotify interface {
 	Notify(protocol Protocol, details []*TransactionDetail) error
 }
 
+// TradeRevertNotify 交易回滚通知,区块被分叉替换时,之前返回的交易失效
+type TradeRevertNotify interface {
+	Revert(protocol Protocol, blockNumber int64, details []*TransactionDetail) error
+}
+
 // TradeBusinessDetail 交易细节
 type TradeBusinessDetail struct {
 	From                  string          `json:"fromAddress"`             // 支付地址
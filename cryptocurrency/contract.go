@@ -131,7 +131,7 @@ var (
 	ContractTRC20SGMT = newContract(TronSGMTContractAddress, "test", SGMT, six)
 	ContractTRC20USDT = newContract(TronUSDTContractAddress, "production", USDT, six)
 	ContractERC20Fly  = newContract(FLYContractAddress, "test", SGMT, eight)
-	ContractERC20USDT = newContract(USDTContractAddress, "production", USDT, eight)
+	ContractERC20USDT = newContract(USDTContractAddress, "production", USDT, six)
 )
 
 /*NewETHContractLocator 新建一个基于map的以太坊合约地址定位器
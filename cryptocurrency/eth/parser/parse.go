@@ -0,0 +1,27 @@
+package parser
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/fighterlyt/common/cryptocurrency"
+)
+
+// Backend 以太坊节点,*ethclient.Client 和 *backends.SimulatedBackend 都实现了该接口
+type Backend interface {
+	BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error)
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	TransactionByHash(ctx context.Context, hash common.Hash) (tx *types.Transaction, isPending bool, err error)
+	TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error)
+	FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error)
+}
+
+// ERC20Parser 以太坊区块解析器,和 TronParser 对应
+type ERC20Parser interface {
+	Parse(ctx context.Context, blockNumber int64) (trades []*cryptocurrency.Trade, err error)
+	// IncludeETH 是否解析ETH转账
+	IncludeETH(include bool)
+}
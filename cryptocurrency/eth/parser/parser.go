@@ -0,0 +1,297 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/fighterlyt/common/cryptocurrency"
+	"github.com/fighterlyt/log"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+)
+
+const (
+	ethPrecision      = 18 // ETH 精度
+	transferTopicSize = 3  // Transfer(address indexed,address indexed,uint256)
+	kilo              = 1000
+)
+
+var (
+	// transferTopic keccak256("Transfer(address,address,uint256)")
+	transferTopic = common.HexToHash(`0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef`)
+)
+
+type erc20Parser struct {
+	backend    Backend                    // 节点
+	chainID    *big.Int                   // 链ID,用于恢复发送方
+	logger     log.Logger                 // 日志器
+	concern    cryptocurrency.Concern     // 对地址的关注
+	contract   cryptocurrency.Contract    // 关注的ERC20合约
+	notify     cryptocurrency.TradeNotify // 交易通知
+	includeETH bool                       // 是否包含ETH
+}
+
+/*
+NewERC20Parser 以太坊解析器
+参数:
+*	concern    	cryptocurrency.Concern    	关心账号
+*	backend    	Backend                   	节点
+*	chainID    	*big.Int                  	链ID
+*	logger     	log.Logger                	日志器
+*	contract   	cryptocurrency.Contract   	合约
+*	notify     	cryptocurrency.TradeNotify	通知器
+返回值:
+*	ERC20Parser	ERC20Parser               	解析器
+*/
+func NewERC20Parser(concern cryptocurrency.Concern, backend Backend, chainID *big.Int, logger log.Logger, contract cryptocurrency.Contract, notify cryptocurrency.TradeNotify) ERC20Parser { //nolint:lll
+	return &erc20Parser{
+		backend:  backend,
+		chainID:  chainID,
+		logger:   logger,
+		concern:  concern,
+		contract: contract,
+		notify:   notify,
+	}
+}
+
+func (e *erc20Parser) IncludeETH(include bool) {
+	e.includeETH = include
+}
+
+/*
+Parse 解析区块中的ETH转账和关注合约的Transfer日志
+参数:
+*	ctx        	context.Context        	上下文
+*	blockNumber	int64                  	区块号
+返回值:
+*	trades     	[]*cryptocurrency.Trade	交易详情
+*	err        	error                  	错误信息
+*/
+func (e erc20Parser) Parse(ctx context.Context, blockNumber int64) (trades []*cryptocurrency.Trade, err error) {
+	logger := e.logger.With(zap.Int64(`区块号码`, blockNumber))
+
+	var (
+		block         *types.Block
+		notifyDetails []*cryptocurrency.TransactionDetail
+	)
+
+	logger.Info(`解析区块`)
+
+	if e.concern == nil {
+		return nil, nil
+	}
+
+	if block, err = e.backend.BlockByNumber(ctx, big.NewInt(blockNumber)); err != nil {
+		return nil, errors.Wrap(err, `BlockByNumber`)
+	}
+
+	if e.includeETH {
+		for _, tx := range block.Transactions() {
+			trade, detail, parseErr := e.parseETH(ctx, block, tx, logger)
+			if parseErr != nil {
+				err = multierr.Append(err, parseErr)
+				logger.Error(`解析错误`, zap.String(`错误`, parseErr.Error()))
+
+				continue
+			}
+
+			if trade != nil {
+				trades = append(trades, trade)
+				notifyDetails = append(notifyDetails, detail)
+			}
+		}
+	}
+
+	if e.contract != nil {
+		logTrades, logDetails, logErr := e.parseLogs(ctx, block, logger)
+		if logErr != nil {
+			err = multierr.Append(err, logErr)
+			logger.Error(`解析日志错误`, zap.String(`错误`, logErr.Error()))
+		}
+
+		trades = append(trades, logTrades...)
+		notifyDetails = append(notifyDetails, logDetails...)
+	}
+
+	logger.Info(`解析完成`, zap.Int(`交易数量`, len(notifyDetails)), zap.Bool(`是否有通知项`, e.notify != nil))
+
+	if e.notify != nil && len(notifyDetails) > 0 {
+		if notifyErr := e.notify.Notify(cryptocurrency.Erc20, notifyDetails); notifyErr != nil {
+			err = multierr.Append(err, errors.Wrap(notifyErr, `通知`))
+		}
+	}
+
+	return trades, err
+}
+
+/*
+parseETH 解析ETH转账,只有关注的交易才查询收据
+参数:
+*	ctx    	context.Context                   	上下文
+*	block  	*types.Block                      	区块
+*	tx     	*types.Transaction                	交易
+*	logger 	log.Logger                        	日志器
+返回值:
+*	trade  	*cryptocurrency.Trade             	交易
+*	detail 	*cryptocurrency.TransactionDetail	通知
+*	err    	error                             	错误
+*/
+func (e erc20Parser) parseETH(ctx context.Context, block *types.Block, tx *types.Transaction, logger log.Logger) (trade *cryptocurrency.Trade, detail *cryptocurrency.TransactionDetail, err error) { //nolint:lll
+	if tx.To() == nil || tx.Value().Sign() <= 0 {
+		return nil, nil, nil
+	}
+
+	var (
+		sender  common.Address
+		receipt *types.Receipt
+		matched bool
+	)
+
+	if sender, err = types.Sender(types.NewLondonSigner(e.chainID), tx); err != nil {
+		return nil, nil, errors.Wrapf(err, `恢复交易[%s]发送方`, tx.Hash().Hex())
+	}
+
+	from, to := sender.Hex(), tx.To().Hex()
+	amount := decimal.NewFromBigInt(tx.Value(), -ethPrecision)
+
+	if matched, _, err = e.concern.FilterConcernedAccounts(from, to, amount); err != nil {
+		return nil, nil, errors.Wrapf(err, `判断关注交易错误,转出[%s]转入[%s],金额[%s]`, from, to, amount.String())
+	}
+
+	if !matched {
+		return nil, nil, nil
+	}
+
+	if receipt, err = e.backend.TransactionReceipt(ctx, tx.Hash()); err != nil {
+		return nil, nil, errors.Wrapf(err, `TransactionReceipt[%s]`, tx.Hash().Hex())
+	}
+
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return nil, nil, nil
+	}
+
+	logger.Info(`ETH转账匹配`, zap.String(`交易`, tx.Hash().Hex()), zap.Strings(`from/to/amount`, []string{from, to, amount.String()}))
+
+	fee := transactionFee(tx, receipt, block.BaseFee())
+	tradeTime := int64(block.Time()) * kilo
+	txID := tx.Hash().Hex()
+
+	trade = cryptocurrency.NewTrade(cryptocurrency.Erc20, from, to, amount, cryptocurrency.ETH, txID, tradeTime, block.Number().Int64(), fee, cryptocurrency.TradeTransfer)                  // nolint:golint,lll
+	detail = cryptocurrency.NewFullTransactionDetail(amount, cryptocurrency.Erc20, cryptocurrency.ETH, from, to, block.Number().Int64(), txID, fee, tradeTime, cryptocurrency.TradeTransfer) //nolint:lll
+
+	return trade, detail, nil
+}
+
+/*
+parseLogs 解析区块中关注合约的Transfer日志,一个交易有多笔转账时使用 hash_日志序号 作为ID
+参数:
+*	ctx    	context.Context                     	上下文
+*	block  	*types.Block                        	区块
+*	logger 	log.Logger                          	日志器
+返回值:
+*	trades 	[]*cryptocurrency.Trade             	交易
+*	details	[]*cryptocurrency.TransactionDetail	通知
+*	err    	error                               	错误
+*/
+func (e erc20Parser) parseLogs(ctx context.Context, block *types.Block, logger log.Logger) (trades []*cryptocurrency.Trade, details []*cryptocurrency.TransactionDetail, err error) { //nolint:lll
+	var (
+		logs []types.Log
+	)
+
+	hash := block.Hash()
+
+	if logs, err = e.backend.FilterLogs(ctx, ethereum.FilterQuery{
+		BlockHash: &hash,
+		Addresses: []common.Address{common.HexToAddress(e.contract.Address())},
+		Topics:    [][]common.Hash{{transferTopic}},
+	}); err != nil {
+		return nil, nil, errors.Wrap(err, `FilterLogs`)
+	}
+
+	counts := make(map[common.Hash]int, len(logs))
+
+	for _, item := range logs {
+		if len(item.Topics) == transferTopicSize && !item.Removed {
+			counts[item.TxHash]++
+		}
+	}
+
+	receipts := make(map[common.Hash]*types.Receipt)
+
+	for _, item := range logs {
+		if len(item.Topics) != transferTopicSize || item.Removed {
+			continue
+		}
+
+		from := common.BytesToAddress(item.Topics[1].Bytes()).Hex()
+		to := common.BytesToAddress(item.Topics[2].Bytes()).Hex()
+		amount := decimal.NewFromBigInt(new(big.Int).SetBytes(item.Data), -e.contract.Precision())
+
+		matched, _, matchErr := e.concern.FilterConcernedAccounts(from, to, amount)
+		if matchErr != nil {
+			err = multierr.Append(err, errors.Wrapf(matchErr, `判断关注交易错误,转出[%s]转入[%s],金额[%s]`, from, to, amount.String()))
+			continue
+		}
+
+		if !matched {
+			continue
+		}
+
+		receipt, exist := receipts[item.TxHash]
+		if !exist {
+			if receipt, matchErr = e.backend.TransactionReceipt(ctx, item.TxHash); matchErr != nil {
+				err = multierr.Append(err, errors.Wrapf(matchErr, `TransactionReceipt[%s]`, item.TxHash.Hex()))
+				continue
+			}
+
+			receipts[item.TxHash] = receipt
+		}
+
+		logger.Info(`ERC20转账匹配`, zap.String(`交易`, item.TxHash.Hex()), zap.Strings(`from/to/amount`, []string{from, to, amount.String()}))
+
+		txID := item.TxHash.Hex()
+		if counts[item.TxHash] > 1 {
+			txID = fmt.Sprintf(`%s_%d`, txID, item.Index)
+		}
+
+		fee := transactionFee(block.Transaction(item.TxHash), receipt, block.BaseFee())
+		tradeTime := int64(block.Time()) * kilo
+
+		trades = append(trades, cryptocurrency.NewTrade(cryptocurrency.Erc20, from, to, amount, e.contract.Token(), txID, tradeTime, int64(item.BlockNumber), fee, cryptocurrency.TradeTransfer))                   // nolint:golint,lll
+		details = append(details, cryptocurrency.NewFullTransactionDetail(amount, cryptocurrency.Erc20, e.contract.Token(), from, to, int64(item.BlockNumber), txID, fee, tradeTime, cryptocurrency.TradeTransfer)) //nolint:lll
+	}
+
+	return trades, details, err
+}
+
+/*
+transactionFee 实际手续费,单位ETH.EIP-1559交易的实际单价为 min(小费上限+基础费用,费用上限)
+参数:
+*	tx     	*types.Transaction	交易,为空时手续费为0
+*	receipt	*types.Receipt    	收据
+*	baseFee	*big.Int          	区块基础费用,London之前为空
+返回值:
+*	fee    	decimal.Decimal   	手续费
+*/
+func transactionFee(tx *types.Transaction, receipt *types.Receipt, baseFee *big.Int) decimal.Decimal {
+	if tx == nil || receipt == nil {
+		return decimal.Zero
+	}
+
+	price := tx.GasPrice()
+
+	if baseFee != nil && tx.Type() == types.DynamicFeeTxType {
+		price = new(big.Int).Add(tx.GasTipCap(), baseFee)
+		if price.Cmp(tx.GasFeeCap()) > 0 {
+			price = tx.GasFeeCap()
+		}
+	}
+
+	return decimal.NewFromBigInt(new(big.Int).Mul(price, new(big.Int).SetUint64(receipt.GasUsed)), -ethPrecision)
+}
@@ -0,0 +1,167 @@
+package parser
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/fighterlyt/common/cryptocurrency"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+// transferEmitter 收到 transfer(address,uint256) 调用时发出 Transfer(caller,to,amount) 日志,不维护余额
+var transferEmitter = hexutil.MustDecode(`0x6032600c60003960326000f3` +
+	`6020602460003760043533` + `7f` + transferTopic.Hex()[2:] + `60206000a300`)
+
+type testContract string
+
+func (t testContract) Address() string  { return string(t) }
+func (t testContract) Kind() string     { return `test` }
+func (t testContract) Token() string    { return cryptocurrency.USDT }
+func (t testContract) Precision() int32 { return 6 }
+
+type testConcern map[string]bool
+
+func (t testConcern) FilterConcernedAccounts(_, to string, _ decimal.Decimal) (matched bool, data interface{}, err error) {
+	return t[to], nil, nil
+}
+
+type testNotify struct {
+	details []*cryptocurrency.TransactionDetail
+}
+
+func (t *testNotify) Notify(_ cryptocurrency.Protocol, details []*cryptocurrency.TransactionDetail) error {
+	t.details = append(t.details, details...)
+	return nil
+}
+
+type simulated struct {
+	*backends.SimulatedBackend
+	key    *ecdsa.PrivateKey
+	signer types.Signer
+	nonce  uint64
+}
+
+func newSimulated(t *testing.T) *simulated {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	owner := crypto.PubkeyToAddress(key.PublicKey)
+	backend := backends.NewSimulatedBackend(core.GenesisAlloc{owner: {Balance: new(big.Int).Mul(big.NewInt(params.Ether), big.NewInt(100))}}, 10000000)
+
+	t.Cleanup(func() {
+		_ = backend.Close()
+	})
+
+	return &simulated{SimulatedBackend: backend, key: key, signer: types.NewLondonSigner(big.NewInt(1337))}
+}
+
+func (s *simulated) send(t *testing.T, to *common.Address, value *big.Int, data []byte) *types.Transaction {
+	head, err := s.HeaderByNumber(context.Background(), nil)
+	require.NoError(t, err)
+
+	tx, err := types.SignNewTx(s.key, s.signer, &types.DynamicFeeTx{
+		ChainID:   big.NewInt(1337),
+		Nonce:     s.nonce,
+		GasTipCap: big.NewInt(params.GWei),
+		GasFeeCap: new(big.Int).Add(head.BaseFee, big.NewInt(params.GWei*2)),
+		Gas:       200000,
+		To:        to,
+		Value:     value,
+		Data:      data,
+	})
+	require.NoError(t, err)
+	require.NoError(t, s.SendTransaction(context.Background(), tx))
+
+	s.nonce++
+
+	return tx
+}
+
+func transferData(to common.Address, amount int64) []byte {
+	data := hexutil.MustDecode(`0xa9059cbb`)
+	data = append(data, common.LeftPadBytes(to.Bytes(), 32)...)
+
+	return append(data, common.LeftPadBytes(big.NewInt(amount).Bytes(), 32)...)
+}
+
+func TestERC20Parser_Parse(t *testing.T) {
+	backend := newSimulated(t)
+	ctx := context.Background()
+
+	deploy := backend.send(t, nil, big.NewInt(0), transferEmitter)
+	backend.Commit()
+
+	receipt, err := backend.TransactionReceipt(ctx, deploy.Hash())
+	require.NoError(t, err)
+
+	contract := receipt.ContractAddress
+	receiver := common.HexToAddress(`0x1111111111111111111111111111111111111111`)
+	other := common.HexToAddress(`0x2222222222222222222222222222222222222222`)
+
+	start := time.Unix(int64(backend.Blockchain().CurrentHeader().Time)+1, 0)
+
+	backend.AdjustTime(time.Second * 10)
+	ethTx := backend.send(t, &receiver, big.NewInt(params.Ether), nil)
+	tokenTx := backend.send(t, &contract, big.NewInt(0), transferData(receiver, 2500000))
+	backend.send(t, &contract, big.NewInt(0), transferData(other, 1))
+	backend.Commit()
+
+	notify := &testNotify{}
+	target := NewERC20Parser(testConcern{receiver.Hex(): true}, backend, big.NewInt(1337), logger, testContract(contract.Hex()), notify)
+	target.IncludeETH(true)
+
+	trades, err := target.Parse(ctx, 2)
+	require.NoError(t, err)
+	require.Len(t, trades, 2, `ETH转账和ERC20转账,未关注的地址忽略`)
+	require.Len(t, notify.details, 2)
+
+	require.Equal(t, cryptocurrency.ETH, trades[0].Token)
+	require.Equal(t, ethTx.Hash().Hex(), trades[0].ID)
+	require.True(t, trades[0].Amount.Equal(decimal.New(1, 0)))
+	require.True(t, trades[0].Fee.IsPositive(), `手续费`)
+
+	require.Equal(t, cryptocurrency.USDT, trades[1].Token)
+	require.Equal(t, tokenTx.Hash().Hex(), trades[1].ID)
+	require.True(t, trades[1].Amount.Equal(decimal.New(25, -1)))
+	require.Equal(t, receiver.Hex(), trades[1].To)
+	require.True(t, strings.EqualFold(crypto.PubkeyToAddress(backend.key.PublicKey).Hex(), trades[1].From))
+
+	service := NewTransactionService(backend, big.NewInt(1337))
+
+	found, err := service.FindByHash(tokenTx.Hash().Hex())
+	require.NoError(t, err)
+	require.Equal(t, contractKindTransfer, found.ContractKind(), `未注册合约的日志忽略`)
+
+	service.(*transactionService).contracts = func() map[string]cryptocurrency.Contract {
+		return map[string]cryptocurrency.Contract{cryptocurrency.USDT: testContract(contract.Hex())}
+	}
+
+	found, err = service.FindByHash(tokenTx.Hash().Hex())
+	require.NoError(t, err)
+	require.True(t, found.Success())
+	require.Equal(t, contractKindERC20, found.ContractKind())
+	require.Equal(t, receiver.Hex(), found.To())
+	require.True(t, found.Token().Equal(decimal.New(25, -1)), `按合约精度换算`)
+
+	found, err = service.FindByStartDate(crypto.PubkeyToAddress(backend.key.PublicKey).Hex(), receiver.Hex(), testContract(contract.Hex()), start)
+	require.NoError(t, err)
+	require.Equal(t, tokenTx.Hash().Hex(), found.Hash())
+	require.True(t, found.Token().Equal(decimal.New(25, -1)))
+
+	found, err = service.FindByStartDate(crypto.PubkeyToAddress(backend.key.PublicKey).Hex(), receiver.Hex(), nil, start)
+	require.NoError(t, err)
+	require.Equal(t, ethTx.Hash().Hex(), found.Hash())
+	require.True(t, found.Value().Equal(decimal.New(1, 0)))
+}
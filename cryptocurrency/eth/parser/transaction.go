@@ -0,0 +1,392 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/fighterlyt/common/cryptocurrency"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	contractKindTransfer = `transfer` // ETH转账
+	contractKindERC20    = `erc20`    // ERC20转账
+	defaultQueryTimeout  = time.Second * 30
+	defaultScanBlocks    = 5000 // FindByStartDate 查询ERC20日志时最多扫描的区块数量
+	defaultScanETHBlocks = 300  // FindByStartDate 查询ETH转账时最多扫描的区块数量,需要逐个获取区块,约一小时
+	defaultBlockTimeout  = time.Second * 5
+)
+
+var (
+	// ErrTransactionNotFound 交易不存在
+	ErrTransactionNotFound = errors.New(`交易不存在`)
+)
+
+// transaction cryptocurrency.Transaction 的以太坊实现
+type transaction struct {
+	contractKind string
+	success      bool
+	hash         string
+	from         string
+	to           string
+	value        decimal.Decimal
+	token        decimal.Decimal
+	gasUsed      decimal.Decimal
+	startDate    time.Time
+}
+
+func (t transaction) ContractKind() string {
+	return t.contractKind
+}
+
+func (t transaction) Success() bool {
+	return t.success
+}
+
+func (t transaction) Hash() string {
+	return t.hash
+}
+
+func (t transaction) From() string {
+	return t.from
+}
+
+func (t transaction) To() string {
+	return t.to
+}
+
+func (t transaction) Value() decimal.Decimal {
+	return t.value
+}
+
+func (t transaction) Token() decimal.Decimal {
+	return t.token
+}
+
+func (t transaction) GasUsed() decimal.Decimal {
+	return t.gasUsed
+}
+
+func (t transaction) StartDate() time.Time {
+	return t.startDate
+}
+
+type transactionService struct {
+	backend      Backend
+	chainID      *big.Int
+	timeout      time.Duration
+	scanBlocks   int64
+	ethBlocks    int64                                     // ETH转账最多扫描的区块数量
+	blockTimeout time.Duration                             // 获取单个区块的超时
+	contracts    func() map[string]cryptocurrency.Contract // 已注册的ERC20合约,用于按日志地址确定精度
+}
+
+/*
+NewTransactionService 以太坊交易查询
+参数:
+*	backend	Backend                          	节点
+*	chainID	*big.Int                         	链ID
+返回值:
+*	service	cryptocurrency.TransactionService	服务
+*/
+func NewTransactionService(backend Backend, chainID *big.Int) cryptocurrency.TransactionService {
+	return &transactionService{
+		backend:      backend,
+		chainID:      chainID,
+		timeout:      defaultQueryTimeout,
+		scanBlocks:   defaultScanBlocks,
+		ethBlocks:    defaultScanETHBlocks,
+		blockTimeout: defaultBlockTimeout,
+		contracts:    registeredContracts,
+	}
+}
+
+// registeredContracts 已注册的ERC20合约,合约在服务创建之后才初始化,每次查询时获取
+func registeredContracts() map[string]cryptocurrency.Contract {
+	if locator := cryptocurrency.Erc20.ContractLocator(); locator != nil {
+		return locator.GetContracts()
+	}
+
+	return nil
+}
+
+/*
+contract 按日志地址查找已注册的合约
+参数:
+*	address 	common.Address         	日志地址
+返回值:
+*	contract	cryptocurrency.Contract	合约,未注册时为nil
+*/
+func (t transactionService) contract(address common.Address) cryptocurrency.Contract {
+	for _, contract := range t.contracts() {
+		if common.HexToAddress(contract.Address()) == address {
+			return contract
+		}
+	}
+
+	return nil
+}
+
+/*
+FindByHash 查询交易,ERC20转账的 Token 为第一条已注册合约的Transfer日志的金额,按合约精度换算,
+未注册合约的日志忽略
+参数:
+*	txHash     	string                    	交易hash
+返回值:
+*	transaction	cryptocurrency.Transaction	交易
+*	err        	error                     	错误
+*/
+func (t transactionService) FindByHash(txHash string) (cryptocurrency.Transaction, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), t.timeout)
+	defer cancel()
+
+	return t.find(ctx, common.HexToHash(txHash), nil)
+}
+
+/*
+find 查询交易
+参数:
+*	ctx        	context.Context        	上下文
+*	hash       	common.Hash            	交易hash
+*	contract   	cryptocurrency.Contract	只解析该合约的Transfer日志,为nil时解析任意已注册合约的日志
+返回值:
+*	result     	*transaction           	交易
+*	err        	error                  	错误
+*/
+func (t transactionService) find(ctx context.Context, hash common.Hash, contract cryptocurrency.Contract) (result *transaction, err error) { //nolint:lll
+	var (
+		tx      *types.Transaction
+		pending bool
+		receipt *types.Receipt
+		header  *types.Header
+		sender  common.Address
+	)
+
+	if tx, pending, err = t.backend.TransactionByHash(ctx, hash); err != nil {
+		if errors.Is(err, ethereum.NotFound) {
+			return nil, ErrTransactionNotFound
+		}
+
+		return nil, errors.Wrap(err, `TransactionByHash`)
+	}
+
+	if pending {
+		return nil, fmt.Errorf(`交易[%s]未上链`, hash.Hex())
+	}
+
+	if receipt, err = t.backend.TransactionReceipt(ctx, hash); err != nil {
+		return nil, errors.Wrap(err, `TransactionReceipt`)
+	}
+
+	if header, err = t.backend.HeaderByNumber(ctx, receipt.BlockNumber); err != nil {
+		return nil, errors.Wrap(err, `HeaderByNumber`)
+	}
+
+	if sender, err = types.Sender(types.NewLondonSigner(t.chainID), tx); err != nil {
+		return nil, errors.Wrap(err, `恢复发送方`)
+	}
+
+	result = &transaction{
+		contractKind: contractKindTransfer,
+		success:      receipt.Status == types.ReceiptStatusSuccessful,
+		hash:         hash.Hex(),
+		from:         sender.Hex(),
+		value:        decimal.NewFromBigInt(tx.Value(), -ethPrecision),
+		token:        decimal.Zero,
+		gasUsed:      decimal.NewFromInt(int64(receipt.GasUsed)),
+		startDate:    time.Unix(int64(header.Time), 0),
+	}
+
+	if tx.To() != nil {
+		result.to = tx.To().Hex()
+	}
+
+	for _, item := range receipt.Logs {
+		if len(item.Topics) != transferTopicSize || item.Topics[0] != transferTopic {
+			continue
+		}
+
+		emitter := contract
+		if emitter == nil {
+			emitter = t.contract(item.Address)
+		}
+
+		// 其他合约也可能发出Transfer日志,精度未知,不能当作代币转账
+		if emitter == nil || common.HexToAddress(emitter.Address()) != item.Address {
+			continue
+		}
+
+		result.contractKind = contractKindERC20
+		result.from = common.BytesToAddress(item.Topics[1].Bytes()).Hex()
+		result.to = common.BytesToAddress(item.Topics[2].Bytes()).Hex()
+		result.token = decimal.NewFromBigInt(new(big.Int).SetBytes(item.Data), -emitter.Precision())
+
+		break
+	}
+
+	return result, nil
+}
+
+/*
+FindByStartDate 查询 startDate 之后第一笔 fromAddress 到 toAddress 的转账,contract 为空时查询ETH转账.
+ERC20转账通过日志查询,最多扫描 defaultScanBlocks 个区块;ETH转账需要逐个获取区块,
+最多扫描 defaultScanETHBlocks 个区块,每个区块单独计算超时,超出范围的转账返回 ErrTransactionNotFound
+参数:
+*	fromAddress	string                    	转出地址
+*	toAddress  	string                    	转入地址
+*	contract   	cryptocurrency.Contract   	合约
+*	startDate  	time.Time                 	开始时间
+返回值:
+*	transaction	cryptocurrency.Transaction	交易
+*	err        	error                     	错误
+*/
+func (t transactionService) FindByStartDate(fromAddress, toAddress string, contract cryptocurrency.Contract, startDate time.Time) (cryptocurrency.Transaction, error) { //nolint:lll
+	ctx, cancel := context.WithTimeout(context.Background(), t.timeout)
+	defer cancel()
+
+	start, end, err := t.blockRange(ctx, startDate)
+	if err != nil {
+		return nil, err
+	}
+
+	from, to := common.HexToAddress(fromAddress), common.HexToAddress(toAddress)
+
+	if contract != nil {
+		return t.findLog(ctx, from, to, contract, start, end)
+	}
+
+	if end > start+t.ethBlocks-1 {
+		end = start + t.ethBlocks - 1
+	}
+
+	for number := start; number <= end; number++ {
+		hash, found, blockErr := t.findInBlock(number, from, to)
+		if blockErr != nil {
+			return nil, blockErr
+		}
+
+		if found {
+			// 扫描区块已经消耗了部分时间,查询交易重新计算超时
+			findCtx, findCancel := context.WithTimeout(context.Background(), t.timeout)
+			defer findCancel()
+
+			return t.find(findCtx, hash, nil)
+		}
+	}
+
+	return nil, ErrTransactionNotFound
+}
+
+/*
+findInBlock 在区块中查找 from 到 to 的ETH转账,每个区块使用单独的超时
+参数:
+*	number	int64         	区块号
+*	from  	common.Address	转出地址
+*	to    	common.Address	转入地址
+返回值:
+*	hash  	common.Hash   	交易hash
+*	found 	bool          	是否找到
+*	err   	error         	错误
+*/
+func (t transactionService) findInBlock(number int64, from, to common.Address) (hash common.Hash, found bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), t.blockTimeout)
+	defer cancel()
+
+	block, err := t.backend.BlockByNumber(ctx, big.NewInt(number))
+	if err != nil {
+		return hash, false, errors.Wrapf(err, `BlockByNumber[%d]`, number)
+	}
+
+	for _, tx := range block.Transactions() {
+		if tx.To() == nil || *tx.To() != to {
+			continue
+		}
+
+		sender, senderErr := types.Sender(types.NewLondonSigner(t.chainID), tx)
+		if senderErr != nil || sender != from {
+			continue
+		}
+
+		return tx.Hash(), true, nil
+	}
+
+	return hash, false, nil
+}
+
+func (t transactionService) findLog(ctx context.Context, from, to common.Address, contract cryptocurrency.Contract, start, end int64) (cryptocurrency.Transaction, error) { //nolint:lll
+	logs, err := t.backend.FilterLogs(ctx, ethereum.FilterQuery{
+		FromBlock: big.NewInt(start),
+		ToBlock:   big.NewInt(end),
+		Addresses: []common.Address{common.HexToAddress(contract.Address())},
+		Topics:    [][]common.Hash{{transferTopic}, {common.BytesToHash(from.Bytes())}, {common.BytesToHash(to.Bytes())}},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, `FilterLogs`)
+	}
+
+	for _, item := range logs {
+		if !item.Removed {
+			return t.find(ctx, item.TxHash, contract)
+		}
+	}
+
+	return nil, ErrTransactionNotFound
+}
+
+/*
+blockRange 二分查找时间不早于 startDate 的第一个区块,扫描范围不超过 scanBlocks
+参数:
+*	ctx      	context.Context	上下文
+*	startDate	time.Time      	开始时间
+返回值:
+*	start    	int64          	开始区块
+*	end      	int64          	结束区块
+*	err      	error          	错误
+*/
+func (t transactionService) blockRange(ctx context.Context, startDate time.Time) (start, end int64, err error) {
+	head, err := t.backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, `获取最新区块`)
+	}
+
+	latest := head.Number.Int64()
+	target := uint64(startDate.Unix())
+
+	var searchErr error
+
+	start = int64(sort.Search(int(latest)+1, func(i int) bool {
+		if searchErr != nil {
+			return true
+		}
+
+		header, headerErr := t.backend.HeaderByNumber(ctx, big.NewInt(int64(i)))
+		if headerErr != nil {
+			searchErr = errors.Wrapf(headerErr, `HeaderByNumber[%d]`, i)
+			return true
+		}
+
+		return header.Time >= target
+	}))
+
+	if searchErr != nil {
+		return 0, 0, searchErr
+	}
+
+	if start > latest {
+		return 0, 0, ErrTransactionNotFound
+	}
+
+	end = start + t.scanBlocks - 1
+	if end > latest {
+		end = latest
+	}
+
+	return start, end, nil
+}
@@ -83,8 +83,28 @@ type UserWallet struct {
 	Mnemonic   string   `gorm:"column:mnemonic;comment:'助记词'"`
 	PrivateKey string   `gorm:"column:private_key;comment:'私钥'"`
 	Address    string   `gorm:"column:address;comment:'地址'"`
+	// 归集状态,广播前先记录交易ID,进程中断后可以根据交易ID继续
+	SweepStatus SweepStatus `gorm:"column:sweep_status;index;default:0;comment:'归集状态'"`
+	SweepTxID   string      `gorm:"column:sweep_tx_id;type:varchar(64);comment:'最近一次归集交易'"`
+	SweepTime   int64       `gorm:"column:sweep_time;comment:'最近一次归集时间,秒'"`
+	SweepReason string      `gorm:"column:sweep_reason;comment:'归集失败原因'"`
+	// 连续失败后退避,避免每轮都重试同一个钱包
+	SweepRetries  int   `gorm:"column:sweep_retries;default:0;comment:'归集连续失败次数'"`
+	SweepNextTime int64 `gorm:"column:sweep_next_time;default:0;comment:'归集失败后下次重试时间,秒'"`
 }
 
+// SweepStatus 归集状态
+type SweepStatus int
+
+const (
+	// SweepIdle 空闲,可以归集
+	SweepIdle SweepStatus = 0
+	// SweepSending 交易已创建,等待上链
+	SweepSending SweepStatus = 1
+	// SweepFailed 交易创建或者上链失败,到 SweepNextTime 后继续归集
+	SweepFailed SweepStatus = 2
+)
+
 func (w *UserWallet) TableName() string {
 	return "user_wallet"
 }
@@ -98,6 +118,12 @@ const (
 	TradeApprove TradeKind = 2
 	// TradeRevokeApprove 撤销授权
 	TradeRevokeApprove TradeKind = 3
+	// TradeInternalTransfer 合约调用中产生的转账,来自事件日志或者内部交易
+	TradeInternalTransfer TradeKind = 4
+	// TradeNFTTransfer NFT转账,金额固定为1
+	TradeNFTTransfer TradeKind = 5
+	// TradeCollect 归集,从用户充值地址转到归集地址
+	TradeCollect TradeKind = 6
 )
 
 // Trade tron交易
@@ -8,11 +8,20 @@ const (
 	Trc20 Protocol = "trc20"
 	// Erc20 协议
 	Erc20 Protocol = "erc20"
+	// Trc10 波场原生代币协议
+	Trc10 Protocol = "trc10"
+	// Trc721 波场NFT协议
+	Trc721 Protocol = "trc721"
 )
 
-// Support 是否支持协议,目前只支持trc20和ERC20
+// Support 是否支持协议
 func (p Protocol) Support() bool {
-	return p == Trc20 || p == Erc20
+	switch p {
+	case Trc20, Erc20, Trc10, Trc721:
+		return true
+	default:
+		return false
+	}
 }
 
 func (p Protocol) ContractLocator() ContractLocator {
@@ -14,15 +14,17 @@ var (
 /*
 CreateRecord 创建记录
 参数:
-*	to    	string         	质押收益地址
-*	txID  	string         	质押交易Hash
-*	amount	decimal.Decimal	质押TRX金额
+*	to       	string         	质押收益地址
+*	txID     	string         	质押交易Hash
+*	amount   	decimal.Decimal	质押TRX金额
+*	bandwidth	bool           	是否质押带宽
 返回值:
-*	record	*FreezeRecord  	记录
-*	err   	error          	错误
+*	record   	*FreezeRecord  	记录
+*	err      	error          	错误
 */
-func (s service) CreateRecord(to, txID string, amount decimal.Decimal) (record *FreezeRecord, err error) {
+func (s service) CreateRecord(to, txID string, amount decimal.Decimal, bandwidth bool) (record *FreezeRecord, err error) {
 	record = NewFreezeRecord(s.from, to, txID, amount, helpers.Now())
+	record.Bandwidth = bandwidth
 
 	db := s.db.Model(modelRecord)
 
@@ -36,22 +38,25 @@ func (s service) CreateRecord(to, txID string, amount decimal.Decimal) (record *
 /*
 UpdateUnfreezeInfo 更新解冻信息
 参数:
+*	to         	    string      	收益地址
 *	txID       	    string      	解冻交易ID
+*	bandwidth  	    bool        	是否解冻带宽
 *	unfreeTime 	    helpers.Time	解冻时间
 *	freezeTimeMax	helpers.Time	最大冻结时间
 返回值:
 *	error      	    error       	错误
 重点:
 
-	由于解冻是批量解冻，所有符合条件(冻结72小时)的都被解冻
+	由于解冻是批量解冻，收益地址所有符合条件(冻结72小时)的同一资源都被解冻
 */
-func (s service) UpdateUnfreezeInfo(txID string, unfreeTime, freezeTimeMax helpers.Time) error {
+func (s service) UpdateUnfreezeInfo(to, txID string, bandwidth bool, unfreeTime, freezeTimeMax helpers.Time) error {
 	db := s.db.Model(modelRecord)
 
-	return db.Where(`unfreeze_tx_id = ? and freeze_time <= ?`, ``, freezeTimeMax).Updates(map[string]interface{}{
-		`unfreeze_tx_id`: txID,
-		`unfreeze_time`:  unfreeTime,
-	}).Error
+	return db.Where("`from` = ? and `to` = ? and bandwidth = ? and unfreeze_tx_id = ? and `time` <= ?", s.from, to, bandwidth, ``, freezeTimeMax).
+		Updates(map[string]interface{}{
+			`unfreeze_tx_id`: txID,
+			`unfreeze_time`:  unfreeTime,
+		}).Error
 }
 
 func (s service) findRecords(filter GetRecordFilter, needAllCount bool) (totalCount int64, records []FreezeRecord, err error) {
@@ -59,7 +64,8 @@ func (s service) findRecords(filter GetRecordFilter, needAllCount bool) (totalCo
 
 	db = filter.ForSQL()(db)
 
-	if err = db.Offset(filter.Start).Limit(filter.Limit).Find(&records).Error; err != nil {
+	// 按质押时间排序,最早到期的在前
+	if err = db.Order("`time`, id").Offset(filter.Start).Limit(filter.Limit).Find(&records).Error; err != nil {
 		return 0, nil, errors.Wrap(err, `查询`)
 	}
 
@@ -0,0 +1,203 @@
+package free
+
+import (
+	"fmt"
+
+	"github.com/fighterlyt/gotron-sdk/pkg/proto/api"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	transferMethod       = `transfer(address,uint256)`
+	balanceOfMethod      = `balanceOf(address)`
+	transferBandwidth    = 345   // TRC20 transfer 交易大小,单位字节
+	transferEnergy       = 31895 // 收款方持有代币时 transfer 消耗的能量,模拟结果不可用时使用
+	transferEnergyToZero = 64895 // 收款方没有代币时 transfer 消耗的能量,需要新建存储
+	energyUsedField      = 5     // TransactionExtention.energy_used,当前 gotron-sdk 的 proto 中没有该字段
+)
+
+// ResourceClient 估算需要的波场接口,*client.GrpcClient 实现了该接口
+type ResourceClient interface {
+	GetAccountResource(addr string) (*api.AccountResourceMessage, error)
+	TriggerConstantContract(from, contractAddress, method, jsonString string) (*api.TransactionExtention, error)
+}
+
+// Estimate 转账需要的资源
+type Estimate struct {
+	Energy             int64           // 需要的能量
+	Bandwidth          int64           // 需要的带宽
+	AvailableEnergy    int64           // 已有的能量
+	AvailableBandwidth int64           // 已有的带宽,包含免费带宽
+	MissingEnergy      int64           // 缺少的能量
+	MissingBandwidth   int64           // 缺少的带宽
+	EnergyTRX          decimal.Decimal // 补足能量需要质押的TRX
+	BandwidthTRX       decimal.Decimal // 补足带宽需要质押的TRX
+}
+
+// Estimator 资源估算
+type Estimator interface {
+	// Estimate 估算 owner 向 receiver 转账 amount 个代币需要的资源
+	Estimate(owner, receiver string, amount decimal.Decimal) (*Estimate, error)
+}
+
+type estimator struct {
+	client    ResourceClient
+	contract  string
+	precision int32
+}
+
+/*
+NewEstimator 新建TRC20转账资源估算
+参数:
+*	client   	ResourceClient	波场客户端
+*	contract 	string        	TRC20合约地址
+*	precision	int32         	代币精度
+返回值:
+*	Estimator	Estimator     	估算
+*/
+func NewEstimator(client ResourceClient, contract string, precision int32) Estimator {
+	return &estimator{
+		client:    client,
+		contract:  contract,
+		precision: precision,
+	}
+}
+
+/*
+Estimate 读取 owner 的资源并模拟转账,计算缺少的能量和带宽
+参数:
+*	owner   	string         	转出地址,也就是质押的收益方
+*	receiver	string         	收款地址,为空时不模拟,按收款方没有代币估算
+*	amount  	decimal.Decimal	转账金额
+返回值:
+*	estimate	*Estimate      	估算结果
+*	err     	error          	错误
+*/
+func (e estimator) Estimate(owner, receiver string, amount decimal.Decimal) (estimate *Estimate, err error) {
+	var (
+		resource *api.AccountResourceMessage
+	)
+
+	if resource, err = e.client.GetAccountResource(owner); err != nil {
+		return nil, errors.Wrap(err, `获取账户资源`)
+	}
+
+	estimate = &Estimate{
+		Bandwidth:          transferBandwidth,
+		AvailableEnergy:    positive(resource.GetEnergyLimit() - resource.GetEnergyUsed()),
+		AvailableBandwidth: positive(resource.GetFreeNetLimit()-resource.GetFreeNetUsed()) + positive(resource.GetNetLimit()-resource.GetNetUsed()),
+	}
+
+	if estimate.Energy, err = e.energy(owner, receiver, amount); err != nil {
+		return nil, err
+	}
+
+	estimate.MissingEnergy = positive(estimate.Energy - estimate.AvailableEnergy)
+	estimate.EnergyTRX = stakeTRX(estimate.MissingEnergy, resource.GetTotalEnergyWeight(), resource.GetTotalEnergyLimit())
+
+	// 带宽不足时,只要免费带宽或质押带宽中有一个足够即可,这里按合计计算
+	estimate.MissingBandwidth = positive(estimate.Bandwidth - estimate.AvailableBandwidth)
+	estimate.BandwidthTRX = stakeTRX(estimate.MissingBandwidth, resource.GetTotalNetWeight(), resource.GetTotalNetLimit())
+
+	return estimate, nil
+}
+
+/*
+energy 模拟转账得到消耗的能量,节点不返回时按收款方是否持有代币使用经验值
+参数:
+*	owner   	string         	转出地址
+*	receiver	string         	收款地址
+*	amount  	decimal.Decimal	转账金额
+返回值:
+*	energy  	int64          	能量
+*	err     	error          	错误
+*/
+func (e estimator) energy(owner, receiver string, amount decimal.Decimal) (energy int64, err error) {
+	var (
+		result *api.TransactionExtention
+	)
+
+	if receiver == `` {
+		return transferEnergyToZero, nil
+	}
+
+	param := fmt.Sprintf(`[{"address":"%s"},{"uint256":"%s"}]`, receiver, amount.Shift(e.precision).Truncate(0).String())
+
+	if result, err = e.client.TriggerConstantContract(owner, e.contract, transferMethod, param); err != nil {
+		return 0, errors.Wrap(err, `模拟转账`)
+	}
+
+	if !result.GetResult().GetResult() {
+		return 0, fmt.Errorf(`模拟转账失败:%s`, string(result.GetResult().GetMessage()))
+	}
+
+	if energy = energyUsed(result); energy > 0 {
+		return energy, nil
+	}
+
+	param = fmt.Sprintf(`[{"address":"%s"}]`, receiver)
+
+	if result, err = e.client.TriggerConstantContract(owner, e.contract, balanceOfMethod, param); err != nil {
+		return 0, errors.Wrap(err, `查询收款方余额`)
+	}
+
+	for _, data := range result.GetConstantResult() {
+		for _, b := range data {
+			if b != 0 {
+				return transferEnergy, nil
+			}
+		}
+	}
+
+	return transferEnergyToZero, nil
+}
+
+// energyUsed 从未知字段中读取 energy_used
+func energyUsed(result *api.TransactionExtention) int64 {
+	unknown := result.ProtoReflect().GetUnknown()
+
+	for len(unknown) > 0 {
+		number, kind, n := protowire.ConsumeTag(unknown)
+		if n < 0 {
+			return 0
+		}
+
+		unknown = unknown[n:]
+
+		if number == energyUsedField && kind == protowire.VarintType {
+			value, m := protowire.ConsumeVarint(unknown)
+			if m < 0 {
+				return 0
+			}
+
+			return int64(value)
+		}
+
+		if n = protowire.ConsumeFieldValue(number, kind, unknown); n < 0 {
+			return 0
+		}
+
+		unknown = unknown[n:]
+	}
+
+	return 0
+}
+
+// stakeTRX 获取 resource 个资源需要质押的TRX,向上取整,质押获得的资源 = 质押TRX * totalLimit / totalWeight
+func stakeTRX(resource, totalWeight, totalLimit int64) decimal.Decimal {
+	if resource <= 0 || totalLimit <= 0 {
+		return decimal.Zero
+	}
+
+	return decimal.NewFromInt(resource).Mul(decimal.NewFromInt(totalWeight)).Div(decimal.NewFromInt(totalLimit)).Ceil()
+}
+
+func positive(value int64) int64 {
+	if value < 0 {
+		return 0
+	}
+
+	return value
+}
@@ -0,0 +1,97 @@
+package free
+
+import (
+	"testing"
+
+	"github.com/fighterlyt/gotron-sdk/pkg/proto/api"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+type mockResourceClient struct {
+	resource   *api.AccountResourceMessage
+	energyUsed int64
+	balance    byte
+	methods    []string
+}
+
+func (m *mockResourceClient) GetAccountResource(_ string) (*api.AccountResourceMessage, error) {
+	return m.resource, nil
+}
+
+func (m *mockResourceClient) TriggerConstantContract(_, _, method, _ string) (*api.TransactionExtention, error) {
+	m.methods = append(m.methods, method)
+
+	result := &api.TransactionExtention{
+		Result:         &api.Return{Result: true},
+		ConstantResult: [][]byte{{m.balance}},
+	}
+
+	if m.energyUsed > 0 {
+		unknown := protowire.AppendTag(nil, 5, protowire.VarintType)
+		result.ProtoReflect().SetUnknown(protowire.AppendVarint(unknown, uint64(m.energyUsed)))
+	}
+
+	return result, nil
+}
+
+func TestEstimator_Estimate(t *testing.T) {
+	client := &mockResourceClient{
+		resource: &api.AccountResourceMessage{
+			FreeNetLimit:      600,
+			FreeNetUsed:       500,
+			EnergyLimit:       10000,
+			EnergyUsed:        2000,
+			TotalEnergyLimit:  90000000000,
+			TotalEnergyWeight: 9000000000,
+			TotalNetLimit:     43200000000,
+			TotalNetWeight:    43200000000,
+		},
+		energyUsed: 31895,
+	}
+
+	estimator := NewEstimator(client, `TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t`, 6)
+
+	estimate, err := estimator.Estimate(`owner`, `receiver`, decimal.New(1, 0))
+	require.NoError(t, err)
+	require.EqualValues(t, 31895, estimate.Energy, `使用模拟结果`)
+	require.EqualValues(t, 8000, estimate.AvailableEnergy)
+	require.EqualValues(t, 23895, estimate.MissingEnergy)
+	require.Equal(t, `2390`, estimate.EnergyTRX.String(), `向上取整`)
+	require.EqualValues(t, 245, estimate.MissingBandwidth)
+	require.Equal(t, `245`, estimate.BandwidthTRX.String())
+
+	client.energyUsed = 0
+	client.methods = nil
+
+	estimate, err = estimator.Estimate(`owner`, `receiver`, decimal.New(1, 0))
+	require.NoError(t, err)
+	require.EqualValues(t, 64895, estimate.Energy, `收款方没有余额`)
+	require.Equal(t, []string{`transfer(address,uint256)`, `balanceOf(address)`}, client.methods)
+
+	client.balance = 1
+	client.resource.EnergyLimit = 100000
+
+	estimate, err = estimator.Estimate(`owner`, `receiver`, decimal.New(1, 0))
+	require.NoError(t, err)
+	require.EqualValues(t, 31895, estimate.Energy, `收款方有余额`)
+	require.EqualValues(t, 0, estimate.MissingEnergy)
+	require.True(t, estimate.EnergyTRX.IsZero(), `能量足够不需要质押`)
+}
+
+func TestEstimator_EstimateWithoutReceiver(t *testing.T) {
+	client := &mockResourceClient{
+		resource: &api.AccountResourceMessage{
+			TotalEnergyLimit:  90000000000,
+			TotalEnergyWeight: 9000000000,
+		},
+		energyUsed: 31895,
+	}
+
+	estimate, err := NewEstimator(client, `TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t`, 6).Estimate(`owner`, ``, decimal.Zero)
+	require.NoError(t, err)
+	require.EqualValues(t, 64895, estimate.Energy, `没有收款方按收款方没有代币估算`)
+	require.Empty(t, client.methods, `不模拟转账`)
+	require.Equal(t, `6490`, estimate.EnergyTRX.String())
+}
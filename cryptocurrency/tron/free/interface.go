@@ -1,6 +1,7 @@
 package free
 
 import (
+	"github.com/fighterlyt/common/helpers"
 	"github.com/shopspring/decimal"
 )
 
@@ -10,10 +11,20 @@ type Service interface {
 	SetUp(from, privateKey string) error
 	// Freeze 质押，to 收益地址 trxAmount  质押TRX 金额
 	Freeze(to string, trxAmount decimal.Decimal) error
-	// FreezeForTransfer 质押用于转账，会自动计算需要的TRX
+	// FreezeForTransfer 质押用于转账，设置了资源估算时只质押缺少的能量和带宽，否则质押固定的 TRXForSingleEnergy
 	FreezeForTransfer(to string) error
+	// SetEstimator 设置资源估算
+	SetEstimator(estimator Estimator)
+	// FreezeForTRC20Transfer 估算 to 向 receiver 转账 amount 缺少的能量,只质押缺少的部分,能量足够时不质押
+	FreezeForTRC20Transfer(to, receiver string, amount decimal.Decimal) (estimate *Estimate, err error)
 	// UnFreeze 解冻/解除质押
 	UnFreeze(to string) error
+	// Reclaim 解冻 to 在 before 之前的质押,reclaimed 表示是否实际解冻,没有到期记录时为false
+	Reclaim(to string, before helpers.Time) (reclaimed bool, err error)
+	// From 质押来源地址
+	From() string
+	// IsStakeV2 是否为 Stake 2.0 代理
+	IsStakeV2() bool
 	// GetRecords 获取全部记录，filter 是记录,needAllCount 是否需要全部计数， totalCount 总数量,records 总记录 err 错误
 	GetRecords(filter GetRecordFilter, needAllCount bool) (totalCount int64, records []FreezeRecord, err error)
 	Hooks
@@ -2,6 +2,7 @@ package free
 
 import (
 	"github.com/fighterlyt/common/helpers"
+	"github.com/fighterlyt/gotron-sdk/pkg/proto/core"
 	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
@@ -16,6 +17,8 @@ type FreezeRecord struct {
 	Amount       decimal.Decimal `gorm:"column:amount;type:decimal(20,6);comment:质押的TRX"`
 	Time         helpers.Time    `gorm:"column:time;type:int(10);comment:质押时间"`
 	UnFreezeTime helpers.Time    `gorm:"column:unfreeze_time;type:int(10);comment:解冻时间"`
+	StakeV2      bool            `gorm:"column:stake_v2;comment:是否为Stake 2.0代理"`        // Stake 2.0 时记录的是代理,解冻是取消代理
+	Bandwidth    bool            `gorm:"column:bandwidth;default:false;comment:是否质押带宽"` // 默认质押能量
 }
 
 func NewFreezeRecord(from, to, freezeTxID string, amount decimal.Decimal, time helpers.Time) *FreezeRecord {
@@ -28,6 +31,19 @@ func NewFreezeRecord(from, to, freezeTxID string, amount decimal.Decimal, time h
 	}
 }
 
+// resource 质押的资源类型
+func (f FreezeRecord) resource() core.ResourceCode {
+	return resourceCode(f.Bandwidth)
+}
+
+func resourceCode(bandwidth bool) core.ResourceCode {
+	if bandwidth {
+		return core.ResourceCode_BANDWIDTH
+	}
+
+	return core.ResourceCode_ENERGY
+}
+
 func (FreezeRecord) TableName() string {
 	return `trx_freeze_record`
 }
@@ -39,20 +55,30 @@ type GetRecordsConditionFilter struct {
 	Amount   helpers.DecimalRangeArgument `json:"amount"`   // 金额范围
 	Time     helpers.Range                `json:"time"`     // 时间范围
 	Unfreeze bool                         `json:"unfreeze"` // 是否已经赎回
+	Exclude  []string                     `json:"-"`        // 排除的收益账号,用于跳过退避中的地址
+	StakeV2  *bool                        `json:"-"`        // 是否为 Stake 2.0 代理,为空不过滤
 }
 
 func (g GetRecordsConditionFilter) ForSQL() func(db *gorm.DB) *gorm.DB {
 	return func(db *gorm.DB) *gorm.DB {
 		if !helpers.IsStringEmpty(g.From) {
-			db = db.Where(`from = ?`, g.From)
+			db = db.Where("`from` = ?", g.From)
 		}
 
 		if !helpers.IsStringEmpty(g.To) {
-			db = db.Where(`to = ?`, g.To)
+			db = db.Where("`to` = ?", g.To)
+		}
+
+		if g.StakeV2 != nil {
+			db = db.Where(`stake_v2 = ?`, *g.StakeV2)
+		}
+
+		if len(g.Exclude) > 0 {
+			db = db.Where("`to` not in ?", g.Exclude)
 		}
 
 		db = db.Scopes(g.Amount.Scope(`amount`))
-		db = db.Where(g.Time.Scope(`time`))
+		db = db.Scopes(g.Time.Scope("`time`"))
 
 		if g.Unfreeze {
 			db = db.Where(`unfreeze_tx_id = ?`, ``)
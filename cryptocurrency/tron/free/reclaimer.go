@@ -0,0 +1,241 @@
+package free
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fighterlyt/common/helpers"
+	"github.com/fighterlyt/common/model"
+	"github.com/fighterlyt/log"
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultReclaimInterval = time.Minute     // 默认检查间隔
+	defaultReclaimBatch    = 100             // 默认每次处理的记录数
+	defaultMaxBackoff      = time.Hour       // 默认最大退避时间
+	reclaimerKey           = `freeReclaimer` // 模块标识
+)
+
+// ReclaimerOption 自动解冻选项
+type ReclaimerOption struct {
+	Interval   time.Duration // 检查间隔
+	LockPeriod time.Duration // 质押多久之后解冻,为0使用 FreezeLockPeriod
+	Batch      int           // 每次最多处理的记录数
+	MaxBackoff time.Duration // 失败后重试的最大等待时间
+}
+
+func (o *ReclaimerOption) fill() {
+	if o.Interval <= 0 {
+		o.Interval = defaultReclaimInterval
+	}
+
+	if o.LockPeriod <= 0 {
+		o.LockPeriod = FreezeLockPeriod
+	}
+
+	if o.Batch <= 0 {
+		o.Batch = defaultReclaimBatch
+	}
+
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = defaultMaxBackoff
+	}
+}
+
+// Reclaimer 自动解冻到期的质押
+type Reclaimer interface {
+	model.Module
+	// Start 开始定时检查
+	Start()
+	// RunOnce 执行一次检查
+	RunOnce() (reclaimed int, err error)
+}
+
+// backoff 失败的收益地址,在 next 之前不再重试
+type backoff struct {
+	attempts int
+	next     time.Time
+}
+
+type reclaimer struct {
+	service  Service
+	option   ReclaimerOption
+	logger   log.Logger
+	lock     *sync.Mutex
+	backoffs map[string]*backoff
+	shutdown model.Shutdown
+	once     *sync.Once
+	exit     chan struct{}
+	now      func() time.Time
+}
+
+/*
+NewReclaimer 新建自动解冻,解冻使用 Service.UnFreeze,加锁和失败记录由服务完成
+参数:
+*	service  	Service        	质押服务,v1 或者 Stake 2.0
+*	option   	ReclaimerOption	选项
+*	logger   	log.Logger     	日志器
+返回值:
+*	Reclaimer	Reclaimer      	自动解冻
+*/
+func NewReclaimer(service Service, option ReclaimerOption, logger log.Logger) Reclaimer {
+	option.fill()
+
+	return &reclaimer{
+		service:  service,
+		option:   option,
+		logger:   logger.Derive(`自动解冻`),
+		lock:     &sync.Mutex{},
+		backoffs: make(map[string]*backoff),
+		shutdown: model.NewShutdown(),
+		once:     &sync.Once{},
+		exit:     make(chan struct{}),
+		now:      time.Now,
+	}
+}
+
+func (r *reclaimer) Start() {
+	helpers.EnsureGo(r.logger, func() {
+		ticker := time.NewTicker(r.option.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if reclaimed, err := r.RunOnce(); err != nil {
+					r.logger.Warn(`自动解冻失败`, zap.Int(`成功`, reclaimed), helpers.ZapError(err))
+				}
+			case <-r.exit:
+				return
+			}
+		}
+	})
+}
+
+/*
+RunOnce 按质押时间查询到期未解冻的记录,按收益地址解冻,失败的地址按指数退避重试,退避中的地址不查询
+参数:
+返回值:
+*	reclaimed	int  	实际解冻的收益地址数量
+*	err      	error	错误
+*/
+func (r *reclaimer) RunOnce() (reclaimed int, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.shutdown.IsClosed() {
+		return 0, nil
+	}
+
+	now := r.now()
+	before := now.Add(-r.option.LockPeriod).Unix()
+	stakeV2 := r.service.IsStakeV2()
+
+	// 只查询当前服务的质押来源和质押方式,其他记录当前服务无法解冻
+	filter := GetRecordFilter{
+		GetRecordsConditionFilter: GetRecordsConditionFilter{
+			From:     r.service.From(),
+			Time:     helpers.Range{Max: before},
+			Unfreeze: true, // 未解冻的记录
+			Exclude:  r.backedOff(now),
+			StakeV2:  &stakeV2,
+		},
+		Limit: r.option.Batch,
+	}
+
+	_, records, err := r.service.GetRecords(filter, false)
+	if err != nil {
+		return 0, errors.Wrap(err, `查询到期记录`)
+	}
+
+	handled := make(map[string]struct{}, len(records))
+
+	for _, record := range records {
+		if _, exist := handled[record.To]; exist {
+			continue
+		}
+
+		handled[record.To] = struct{}{}
+
+		done, unfreezeErr := r.service.Reclaim(record.To, helpers.Time(before))
+		if unfreezeErr != nil {
+			next := r.fail(record.To, now)
+
+			r.logger.Warn(`解冻失败,稍后重试`, zap.String(`收益地址`, record.To), zap.Time(`重试时间`, next), helpers.ZapError(unfreezeErr))
+
+			err = multierr.Append(err, errors.Wrapf(unfreezeErr, `解冻[%s]`, record.To))
+
+			continue
+		}
+
+		delete(r.backoffs, record.To)
+
+		if done {
+			reclaimed++
+		}
+	}
+
+	return reclaimed, err
+}
+
+// backedOff 退避中的收益地址,调用方持有锁
+func (r *reclaimer) backedOff(now time.Time) []string {
+	addresses := make([]string, 0, len(r.backoffs))
+
+	for to, state := range r.backoffs {
+		if now.Before(state.next) {
+			addresses = append(addresses, to)
+		}
+	}
+
+	sort.Strings(addresses)
+
+	return addresses
+}
+
+// fail 记录失败并计算下次重试时间,调用方持有锁
+func (r *reclaimer) fail(to string, now time.Time) time.Time {
+	state, exist := r.backoffs[to]
+	if !exist {
+		state = &backoff{}
+		r.backoffs[to] = state
+	}
+
+	state.attempts++
+
+	wait := r.option.Interval << uint(state.attempts-1)
+	if wait <= 0 || wait > r.option.MaxBackoff {
+		wait = r.option.MaxBackoff
+	}
+
+	state.next = now.Add(wait)
+
+	return state.next
+}
+
+func (r *reclaimer) Close() {
+	r.once.Do(func() {
+		r.shutdown.Close()
+		close(r.exit)
+
+		// 等待正在执行的解冻完成
+		r.lock.Lock()
+		r.lock.Unlock() //nolint:staticcheck
+	})
+}
+
+func (r *reclaimer) IsClosed() bool {
+	return r.shutdown.IsClosed()
+}
+
+func (r *reclaimer) Key() string {
+	return reclaimerKey
+}
+
+func (r *reclaimer) Name() string {
+	return `质押自动解冻`
+}
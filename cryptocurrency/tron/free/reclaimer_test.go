@@ -0,0 +1,135 @@
+package free
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fighterlyt/common/helpers"
+	"github.com/fighterlyt/log"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+type mockFreeService struct {
+	Hooks
+	records  []FreezeRecord
+	fail     map[string]bool
+	unfrozen map[string]int
+	excluded []string
+}
+
+func (m *mockFreeService) SetUp(_, _ string) error { return nil }
+
+func (m *mockFreeService) Freeze(_ string, _ decimal.Decimal) error { return nil }
+
+func (m *mockFreeService) FreezeForTransfer(_ string) error { return nil }
+
+func (m *mockFreeService) SetEstimator(_ Estimator) {}
+
+func (m *mockFreeService) FreezeForTRC20Transfer(_, _ string, _ decimal.Decimal) (*Estimate, error) {
+	return nil, nil
+}
+
+func (m *mockFreeService) UnFreeze(to string) error {
+	_, err := m.Reclaim(to, helpers.Now())
+
+	return err
+}
+
+// Reclaim 解冻当前服务 to 的到期记录,解冻后的记录不再查询
+func (m *mockFreeService) Reclaim(to string, before helpers.Time) (bool, error) {
+	if m.fail[to] {
+		return false, errors.New(`mock`)
+	}
+
+	records := make([]FreezeRecord, 0, len(m.records))
+
+	for _, record := range m.records {
+		if record.To != to || record.From != m.From() || record.StakeV2 != m.IsStakeV2() || record.Time > before {
+			records = append(records, record)
+		}
+	}
+
+	if len(records) == len(m.records) {
+		return false, nil
+	}
+
+	m.records = records
+	m.unfrozen[to]++
+
+	return true, nil
+}
+
+func (m *mockFreeService) From() string { return `from` }
+
+func (m *mockFreeService) IsStakeV2() bool { return true }
+
+func (m *mockFreeService) GetRecords(filter GetRecordFilter, _ bool) (int64, []FreezeRecord, error) {
+	if !filter.Unfreeze || filter.Time.Max == 0 {
+		return 0, nil, errors.New(`应该只查询到期未解冻的记录`)
+	}
+
+	m.excluded = filter.Exclude
+	excluded := make(map[string]bool, len(filter.Exclude))
+
+	for _, to := range filter.Exclude {
+		excluded[to] = true
+	}
+
+	records := make([]FreezeRecord, 0, len(m.records))
+
+	for _, record := range m.records {
+		if excluded[record.To] || (filter.From != `` && record.From != filter.From) ||
+			(filter.StakeV2 != nil && record.StakeV2 != *filter.StakeV2) {
+			continue
+		}
+
+		records = append(records, record)
+
+		if filter.Limit > 0 && len(records) == filter.Limit {
+			break
+		}
+	}
+
+	return 0, records, nil
+}
+
+func TestReclaimer_RunOnce(t *testing.T) {
+	logger, err := log.NewEasyLogger(true, false, ``, `质押`)
+	require.NoError(t, err)
+
+	service := &mockFreeService{
+		records: []FreezeRecord{
+			{To: `c`, From: `other`, StakeV2: true}, // 其他钱包的记录
+			{To: `d`, From: `from`},                 // Stake 1.0 的记录
+			{To: `a`, From: `from`, StakeV2: true},
+			{To: `a`, From: `from`, StakeV2: true},
+			{To: `b`, From: `from`, StakeV2: true},
+		},
+		fail:     map[string]bool{`b`: true},
+		unfrozen: map[string]int{},
+	}
+
+	target := NewReclaimer(service, ReclaimerOption{Interval: time.Hour, Batch: 3}, logger)
+
+	reclaimed, err := target.RunOnce()
+	require.Error(t, err, `b 解冻失败`)
+	require.Equal(t, 1, reclaimed)
+	require.Equal(t, map[string]int{`a`: 1}, service.unfrozen, `同一个收益地址只解冻一次`)
+
+	service.fail[`b`] = false
+
+	reclaimed, err = target.RunOnce()
+	require.NoError(t, err)
+	require.Zero(t, reclaimed, `a 已解冻,b 在退避时间内不重试`)
+	require.Equal(t, 0, service.unfrozen[`b`])
+	require.Equal(t, []string{`b`}, service.excluded, `查询时排除退避中的地址`)
+
+	target.Close()
+	target.Close()
+
+	reclaimed, err = target.RunOnce()
+	require.NoError(t, err)
+	require.Zero(t, reclaimed, `关闭后不再解冻`)
+}
@@ -7,7 +7,6 @@ import (
 	"github.com/fighterlyt/common/helpers"
 	"github.com/fighterlyt/gotron-sdk/pkg/client"
 	"github.com/fighterlyt/gotron-sdk/pkg/free"
-	"github.com/fighterlyt/gotron-sdk/pkg/proto/core"
 	"github.com/fighterlyt/log"
 	"github.com/fighterlyt/redislock"
 	"github.com/pkg/errors"
@@ -20,6 +19,8 @@ const (
 	TRXForSingleEnergy = 1234
 	// TRXToSUN  TRX 和SUN的对应关系
 	TRXToSUN = 1000000
+	// FreezeLockPeriod 质押后需要等待的时间才能解冻
+	FreezeLockPeriod = time.Hour * 72
 )
 
 var (
@@ -33,6 +34,7 @@ type service struct {
 	privateKey string
 	tronClient *client.GrpcClient
 	locker     redislock.Locker
+	estimator  Estimator
 	Hooks
 }
 
@@ -129,11 +131,24 @@ Freeze 冻结
 *	error    	error          	错误
 */
 func (s service) Freeze(to string, trxAmount decimal.Decimal) error {
+	return s.freeze(to, trxAmount, false)
+}
+
+/*
+freeze 冻结能量或者带宽
+参数:
+*	to       	string         	收益方
+*	trxAmount	decimal.Decimal	冻结TRX 金额
+*	bandwidth	bool           	是否冻结带宽,否则冻结能量
+返回值:
+*	error    	error          	错误
+*/
+func (s service) freeze(to string, trxAmount decimal.Decimal, bandwidth bool) error {
 	info := NewFreezeInfo(s.from, to, trxAmount)
 
 	s.EveryBeforeFreeze(info)
 
-	txID, err := free.Freeze(s.tronClient, s.from, s.privateKey, to, core.ResourceCode_ENERGY, trxAmount.IntPart()*TRXToSUN)
+	txID, err := free.Freeze(s.tronClient, s.from, s.privateKey, to, resourceCode(bandwidth), trxAmount.IntPart()*TRXToSUN)
 
 	s.EveryAfterFreeze(info, err)
 
@@ -145,7 +160,7 @@ func (s service) Freeze(to string, trxAmount decimal.Decimal) error {
 		return errors.Wrap(err, `冻结`)
 	}
 
-	if _, err = s.CreateRecord(to, txID, trxAmount); err != nil {
+	if _, err = s.CreateRecord(to, txID, trxAmount, bandwidth); err != nil {
 		return err
 	}
 
@@ -153,14 +168,79 @@ func (s service) Freeze(to string, trxAmount decimal.Decimal) error {
 }
 
 /*
-FreezeForTransfer 用于TRC20的Transfer质押，质押足够的TRX
+FreezeForTransfer 用于TRC20的Transfer质押，设置了资源估算时按收款方没有代币估算,只质押缺少的能量和带宽,否则质押固定的 TRXForSingleEnergy
 参数:
-*	to   	string	参数1
+*	to   	string	收益方,也就是转出地址
 返回值:
-*	error	error 	返回值1
+*	error	error 	错误
 */
 func (s service) FreezeForTransfer(to string) error {
-	return s.Freeze(to, decimal.New(TRXForSingleEnergy, 0))
+	if s.estimator == nil {
+		return s.Freeze(to, decimal.New(TRXForSingleEnergy, 0))
+	}
+
+	_, err := s.freezeByEstimate(to, ``, decimal.Zero, s.freeze)
+
+	return err
+}
+
+/*
+SetEstimator 设置资源估算,用于 FreezeForTRC20Transfer
+参数:
+*	estimator	Estimator	估算,见 NewEstimator
+返回值:
+*/
+func (s *service) SetEstimator(estimator Estimator) {
+	s.estimator = estimator
+}
+
+/*
+FreezeForTRC20Transfer 估算转账缺少的能量,只质押缺少的部分
+参数:
+*	to      	string         	收益方,也就是转出地址
+*	receiver	string         	收款地址
+*	amount  	decimal.Decimal	转账金额
+返回值:
+*	estimate	*Estimate      	估算结果
+*	err     	error          	错误
+*/
+func (s service) FreezeForTRC20Transfer(to, receiver string, amount decimal.Decimal) (estimate *Estimate, err error) {
+	return s.freezeByEstimate(to, receiver, amount, s.freeze)
+}
+
+/*
+freezeByEstimate 估算转账缺少的资源,分别质押缺少的能量和带宽
+参数:
+*	to      	string         	收益方,也就是转出地址
+*	receiver	string         	收款地址,为空时按收款方没有代币估算
+*	amount  	decimal.Decimal	转账金额
+*	freeze  	func           	质押,见 service.freeze
+返回值:
+*	estimate	*Estimate      	估算结果
+*	err     	error          	错误
+*/
+func (s service) freezeByEstimate(to, receiver string, amount decimal.Decimal, freeze func(to string, trxAmount decimal.Decimal, bandwidth bool) error) (estimate *Estimate, err error) { //nolint:lll
+	if s.estimator == nil {
+		return nil, errors.New(`未设置资源估算`)
+	}
+
+	if estimate, err = s.estimator.Estimate(to, receiver, amount); err != nil {
+		return nil, errors.Wrap(err, `估算资源`)
+	}
+
+	if estimate.EnergyTRX.IsPositive() {
+		if err = freeze(to, estimate.EnergyTRX, false); err != nil {
+			return estimate, err
+		}
+	}
+
+	if estimate.BandwidthTRX.IsPositive() {
+		if err = freeze(to, estimate.BandwidthTRX, true); err != nil {
+			return estimate, errors.Wrap(err, `质押带宽`)
+		}
+	}
+
+	return estimate, nil
 }
 
 /*
@@ -171,27 +251,84 @@ UnFreeze 解冻
 *	error	error 	错误
 */
 func (s service) UnFreeze(to string) error {
+	_, err := s.reclaim(to, helpers.Now(), true)
+
+	return err
+}
+
+/*
+Reclaim 解冻收益方在 before 之前的质押,质押不满 FreezeLockPeriod 的不解冻
+参数:
+*	to       	string      	收益地址
+*	before   	helpers.Time	质押时间上限
+返回值:
+*	reclaimed	bool        	是否实际解冻,没有到期记录时为false
+*	err      	error       	错误
+*/
+func (s service) Reclaim(to string, before helpers.Time) (reclaimed bool, err error) {
+	return s.reclaim(to, before, false)
+}
+
+/*
+reclaim 按资源解冻到期的质押
+参数:
+*	to       	string      	收益地址
+*	before   	helpers.Time	质押时间上限
+*	force    	bool        	没有能量记录时也解冻能量,保持 UnFreeze 原有行为
+返回值:
+*	reclaimed	bool        	是否实际解冻
+*	err      	error       	错误
+*/
+func (s service) reclaim(to string, before helpers.Time, force bool) (reclaimed bool, err error) {
 	now := helpers.Now()
 
 	var (
 		mutex redislock.Mutex
-		err   error
-		txID  string
 	)
 	// 加锁，需要是解锁时，是一次性解锁所有已经到期的质押
 	if mutex, err = redislock.GetAndLock(s.locker, to, lockTimeout); err != nil {
-		return errors.Wrap(err, `加锁`)
+		return false, errors.Wrap(err, `加锁`)
 	}
 
 	defer func() {
 		_ = mutex.UnLock()
 	}()
 
+	freezeTimeMax := helpers.Time(time.Unix(now.Unix(), 0).Add(-FreezeLockPeriod).Unix())
+	if before < freezeTimeMax {
+		freezeTimeMax = before
+	}
+
+	// 带宽只在估算后按需质押,有到期记录的资源才解冻
+	for _, bandwidth := range []bool{false, true} {
+		var count int64
+
+		if err = s.db.Model(modelRecord).
+			Where("`from` = ? and `to` = ? and stake_v2 = ? and bandwidth = ? and unfreeze_tx_id = ? and `time` <= ?", s.from, to, false, bandwidth, ``, freezeTimeMax).
+			Count(&count).Error; err != nil {
+			return reclaimed, errors.Wrap(err, `查询质押记录`)
+		}
+
+		if count == 0 && (bandwidth || !force) {
+			continue
+		}
+
+		if err = s.unfreeze(to, bandwidth, now, freezeTimeMax); err != nil {
+			return reclaimed, err
+		}
+
+		reclaimed = true
+	}
+
+	return reclaimed, nil
+}
+
+func (s service) unfreeze(to string, bandwidth bool, now, freezeTimeMax helpers.Time) error {
 	info := NewFreezeInfo(s.from, to, decimal.Zero)
 
 	s.EveryBeforeUnfreeze(info)
 
-	txID, err = free.UnFreeze(s.tronClient, s.from, s.privateKey, to, core.ResourceCode_ENERGY)
+	txID, err := free.UnFreeze(s.tronClient, s.from, s.privateKey, to, resourceCode(bandwidth))
 
 	s.EveryAfterUnfreeze(info, err)
 
@@ -203,7 +340,15 @@ func (s service) UnFreeze(to string) error {
 		return errors.Wrap(err, `解冻`)
 	}
 
-	return s.UpdateUnfreezeInfo(txID, now, helpers.Time(time.Unix(now.Unix(), 0).Add(time.Hour*-72).Unix()))
+	return s.UpdateUnfreezeInfo(to, txID, bandwidth, now, freezeTimeMax)
+}
+
+func (s service) From() string {
+	return s.from
+}
+
+func (s service) IsStakeV2() bool {
+	return false
 }
 
 /*
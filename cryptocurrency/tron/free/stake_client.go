@@ -0,0 +1,230 @@
+package free
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/fighterlyt/gotron-sdk/pkg/proto/core"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultStakeTimeout = time.Second * 10
+)
+
+// StakeV2Client Stake 2.0 接口,当前使用的 gotron-sdk 不支持 Stake 2.0,通过全节点HTTP接口实现
+type StakeV2Client interface {
+	// FreezeBalanceV2 质押,amount 单位SUN
+	FreezeBalanceV2(owner, privateKey string, resource core.ResourceCode, amount int64) (txID string, err error)
+	// DelegateResource 代理资源给 receiver,amount 单位SUN
+	DelegateResource(owner, privateKey, receiver string, resource core.ResourceCode, amount int64) (txID string, err error)
+	// UnDelegateResource 取消代理,amount 单位SUN
+	UnDelegateResource(owner, privateKey, receiver string, resource core.ResourceCode, amount int64) (txID string, err error)
+	// CanDelegatedMaxSize 已质押且可以代理的最大数量,单位SUN
+	CanDelegatedMaxSize(owner string, resource core.ResourceCode) (amount int64, err error)
+}
+
+// httpStakeClient 基于全节点HTTP接口的 StakeV2Client
+type httpStakeClient struct {
+	endpoint string
+	client   *http.Client
+}
+
+/*
+NewHTTPStakeClient 新建 Stake 2.0 客户端
+参数:
+*	endpoint     	string       	全节点HTTP地址,例如 https://api.trongrid.io
+*	timeout      	time.Duration	请求超时,为0使用默认值
+返回值:
+*	StakeV2Client	StakeV2Client	客户端
+*/
+func NewHTTPStakeClient(endpoint string, timeout time.Duration) StakeV2Client {
+	if timeout <= 0 {
+		timeout = defaultStakeTimeout
+	}
+
+	return &httpStakeClient{
+		endpoint: strings.TrimSuffix(endpoint, `/`),
+		client:   &http.Client{Timeout: timeout},
+	}
+}
+
+func (h httpStakeClient) FreezeBalanceV2(owner, privateKey string, resource core.ResourceCode, amount int64) (txID string, err error) {
+	return h.execute(`/wallet/freezebalancev2`, privateKey, map[string]interface{}{
+		`owner_address`:  owner,
+		`frozen_balance`: amount,
+		`resource`:       resource.String(),
+		`visible`:        true,
+	})
+}
+
+func (h httpStakeClient) DelegateResource(owner, privateKey, receiver string, resource core.ResourceCode, amount int64) (txID string, err error) { //nolint:lll
+	return h.execute(`/wallet/delegateresource`, privateKey, map[string]interface{}{
+		`owner_address`:    owner,
+		`receiver_address`: receiver,
+		`balance`:          amount,
+		`resource`:         resource.String(),
+		`lock`:             false,
+		`visible`:          true,
+	})
+}
+
+func (h httpStakeClient) UnDelegateResource(owner, privateKey, receiver string, resource core.ResourceCode, amount int64) (txID string, err error) { //nolint:lll
+	return h.execute(`/wallet/undelegateresource`, privateKey, map[string]interface{}{
+		`owner_address`:    owner,
+		`receiver_address`: receiver,
+		`balance`:          amount,
+		`resource`:         resource.String(),
+		`visible`:          true,
+	})
+}
+
+func (h httpStakeClient) CanDelegatedMaxSize(owner string, resource core.ResourceCode) (amount int64, err error) {
+	result := struct {
+		MaxSize int64 `json:"max_size"`
+	}{}
+
+	if err = h.post(`/wallet/getcandelegatedmaxsize`, map[string]interface{}{
+		`owner_address`: owner,
+		`type`:          int32(resource),
+		`visible`:       true,
+	}, &result); err != nil {
+		return 0, err
+	}
+
+	return result.MaxSize, nil
+}
+
+/*
+execute 创建交易,本地签名后广播
+参数:
+*	path      	string                	创建交易的接口
+*	privateKey	string                	私钥
+*	request   	map[string]interface{}	参数
+返回值:
+*	txID      	string                	交易ID
+*	err       	error                 	错误
+*/
+func (h httpStakeClient) execute(path, privateKey string, request map[string]interface{}) (txID string, err error) {
+	tx := map[string]json.RawMessage{}
+
+	if err = h.post(path, request, &tx); err != nil {
+		return ``, err
+	}
+
+	if txID, err = signTransaction(tx, privateKey); err != nil {
+		return ``, errors.Wrap(err, `签名`)
+	}
+
+	result := struct {
+		Result  bool   `json:"result"`
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}{}
+
+	if err = h.post(`/wallet/broadcasttransaction`, tx, &result); err != nil {
+		return ``, errors.Wrap(err, `广播`)
+	}
+
+	if !result.Result {
+		message, _ := hex.DecodeString(result.Message)
+
+		return ``, fmt.Errorf(`广播失败[%s]:%s`, result.Code, string(message))
+	}
+
+	return txID, nil
+}
+
+/*
+signTransaction 签名,验证节点返回的txID和raw_data_hex一致后再签名
+参数:
+*	tx        	map[string]json.RawMessage	交易
+*	privateKey	string                    	私钥
+返回值:
+*	txID      	string                    	交易ID
+*	err       	error                     	错误
+*/
+func signTransaction(tx map[string]json.RawMessage, privateKey string) (txID string, err error) {
+	var (
+		rawHex string
+		raw    []byte
+	)
+
+	if err = json.Unmarshal(tx[`raw_data_hex`], &rawHex); err != nil {
+		return ``, errors.Wrap(err, `raw_data_hex`)
+	}
+
+	if err = json.Unmarshal(tx[`txID`], &txID); err != nil {
+		return ``, errors.Wrap(err, `txID`)
+	}
+
+	if raw, err = hex.DecodeString(rawHex); err != nil {
+		return ``, errors.Wrap(err, `解码raw_data_hex`)
+	}
+
+	hash := sha256.Sum256(raw)
+
+	if hex.EncodeToString(hash[:]) != txID {
+		return ``, fmt.Errorf(`txID[%s]和交易内容不一致`, txID)
+	}
+
+	key, err := crypto.HexToECDSA(privateKey)
+	if err != nil {
+		return ``, errors.Wrap(err, `解析私钥错误`)
+	}
+
+	signature, err := crypto.Sign(hash[:], key)
+	if err != nil {
+		return ``, errors.Wrap(err, `签名`)
+	}
+
+	if tx[`signature`], err = json.Marshal([]string{hex.EncodeToString(signature)}); err != nil {
+		return ``, errors.Wrap(err, `序列化签名`)
+	}
+
+	return txID, nil
+}
+
+func (h httpStakeClient) post(path string, request, response interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return errors.Wrap(err, `序列化请求`)
+	}
+
+	resp, err := h.client.Post(h.endpoint+path, `application/json`, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, path)
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrapf(err, `%s 读取响应`, path)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf(`%s 状态码[%d]:%s`, path, resp.StatusCode, string(data))
+	}
+
+	// 节点的业务错误通过 Error 字段返回
+	failure := struct {
+		Error string `json:"Error"`
+	}{}
+
+	if json.Unmarshal(data, &failure) == nil && failure.Error != `` {
+		return fmt.Errorf(`%s:%s`, path, failure.Error)
+	}
+
+	return errors.Wrapf(json.Unmarshal(data, response), `%s 解析响应`, path)
+}
@@ -0,0 +1,64 @@
+package free
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/fighterlyt/gotron-sdk/pkg/proto/core"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPStakeClient_DelegateResource(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	raw := []byte(`raw data`)
+	hash := sha256.Sum256(raw)
+	txID := hex.EncodeToString(hash[:])
+
+	var broadcast map[string]interface{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case `/wallet/delegateresource`:
+			request := map[string]interface{}{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+			require.Equal(t, `ENERGY`, request[`resource`])
+			require.EqualValues(t, 1000000, request[`balance`])
+
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{`txID`: txID, `raw_data_hex`: hex.EncodeToString(raw), `raw_data`: map[string]interface{}{}})
+		case `/wallet/broadcasttransaction`:
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&broadcast))
+
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{`result`: true, `txid`: txID})
+		default:
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{`Error`: `unknown`})
+		}
+	}))
+	defer server.Close()
+
+	client := NewHTTPStakeClient(server.URL, 0)
+
+	result, err := client.DelegateResource(`owner`, hex.EncodeToString(crypto.FromECDSA(key)), `receiver`, core.ResourceCode_ENERGY, 1000000)
+	require.NoError(t, err)
+	require.Equal(t, txID, result)
+
+	signatures, ok := broadcast[`signature`].([]interface{})
+	require.True(t, ok, `广播的交易包含签名`)
+	require.Len(t, signatures, 1)
+
+	signature, err := hex.DecodeString(signatures[0].(string))
+	require.NoError(t, err)
+
+	pub, err := crypto.SigToPub(hash[:], signature)
+	require.NoError(t, err)
+	require.Equal(t, crypto.PubkeyToAddress(key.PublicKey), crypto.PubkeyToAddress(*pub), `签名可以恢复出私钥对应的地址`)
+
+	_, err = client.FreezeBalanceV2(`owner`, hex.EncodeToString(crypto.FromECDSA(key)), core.ResourceCode_ENERGY, 1)
+	require.Error(t, err, `节点返回的错误`)
+}
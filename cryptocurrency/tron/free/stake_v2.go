@@ -0,0 +1,335 @@
+package free
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fighterlyt/common/helpers"
+	"github.com/fighterlyt/gotron-sdk/pkg/proto/core"
+	"github.com/fighterlyt/log"
+	"github.com/fighterlyt/redislock"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	stakeV2LockKey     = `stakeV2:`       // 质押池的锁,质押和代理需要串行
+	defaultFreezeWait  = time.Second * 30 // 补充质押后等待上链的最长时间
+	freezePollInterval = time.Second * 3  // 等待质押上链的查询间隔,约一个区块
+	// stakeV2LockTimeout 质押池锁的过期时间,覆盖补充质押、等待上链和代理三步,锁在持有期间也会自动续期
+	stakeV2LockTimeout = defaultStakeTimeout*2 + defaultFreezeWait
+)
+
+// stakeV2Service Stake 2.0 实现,质押到自己的质押池,再把能量代理给收益方,解冻只取消代理,TRX 留在池中复用
+type stakeV2Service struct {
+	*service
+	stake       StakeV2Client
+	freezeWait  time.Duration
+	freezePoll  time.Duration
+	freezeSleep func(time.Duration)
+}
+
+/*
+NewStakeV2Service 新建 Stake 2.0 质押服务
+参数:
+*	db     	*gorm.DB        	数据库
+*	logger 	log.Logger      	日志器
+*	stake  	StakeV2Client   	Stake 2.0 客户端,见 NewHTTPStakeClient
+*	locker 	redislock.Locker	分布式锁
+返回值:
+*	s      	Service         	服务
+*	err    	error           	错误
+*/
+func NewStakeV2Service(db *gorm.DB, logger log.Logger, stake StakeV2Client, locker redislock.Locker) (s Service, err error) {
+	if stake == nil {
+		return nil, errors.New(`Stake 2.0 客户端不能为空`)
+	}
+
+	target := &stakeV2Service{
+		service: &service{
+			db:     db,
+			logger: logger,
+			locker: locker,
+		},
+		stake:       stake,
+		freezeWait:  defaultFreezeWait,
+		freezePoll:  freezePollInterval,
+		freezeSleep: time.Sleep,
+	}
+
+	if err = target.validateV2(); err != nil {
+		return nil, err
+	}
+
+	target.Hooks = NewHooks(logger.Derive(`hooks`))
+
+	if err = target.init(); err != nil {
+		return nil, err
+	}
+
+	return target, nil
+}
+
+func (s stakeV2Service) validateV2() error {
+	if s.db == nil {
+		return errors.New(`db不能为空`)
+	}
+
+	if s.logger == nil {
+		return errors.New(`日志器不能为空`)
+	}
+
+	if s.locker == nil {
+		return errors.New(`redis分布式锁不能为空`)
+	}
+
+	return nil
+}
+
+/*
+Freeze 代理能量给收益方,质押池中可代理的TRX不足时先补充质押
+参数:
+*	to       	string          收益方
+*	trxAmount	decimal.Decimal	代理的TRX 金额
+返回值:
+*	error    	error          	错误
+*/
+func (s stakeV2Service) Freeze(to string, trxAmount decimal.Decimal) error {
+	return s.freeze(to, trxAmount, false)
+}
+
+/*
+freeze 代理能量或者带宽
+参数:
+*	to       	string         	收益方
+*	trxAmount	decimal.Decimal	代理的TRX 金额
+*	bandwidth	bool           	是否代理带宽,否则代理能量
+返回值:
+*	error    	error          	错误
+*/
+func (s stakeV2Service) freeze(to string, trxAmount decimal.Decimal, bandwidth bool) error {
+	info := NewFreezeInfo(s.from, to, trxAmount)
+
+	s.EveryBeforeFreeze(info)
+
+	txID, err := s.delegate(to, trxAmount.IntPart()*TRXToSUN, resourceCode(bandwidth))
+
+	s.EveryAfterFreeze(info, err)
+
+	if err != nil {
+		if _, saveErr := s.CreateFailRecord(to, err.Error(), trxAmount, true); saveErr != nil {
+			s.logger.Error(`创建操作失败记录错误`, helpers.ZapError(err))
+		}
+
+		return errors.Wrap(err, `代理`)
+	}
+
+	record := NewFreezeRecord(s.from, to, txID, trxAmount, helpers.Now())
+	record.StakeV2 = true
+	record.Bandwidth = bandwidth
+
+	if err = s.db.Model(modelRecord).Create(record).Error; err != nil {
+		return errors.Wrap(err, `保存`)
+	}
+
+	return nil
+}
+
+func (s stakeV2Service) delegate(to string, amount int64, resource core.ResourceCode) (txID string, err error) {
+	var (
+		mutex     redislock.Mutex
+		available int64
+	)
+
+	if mutex, err = redislock.GetAndLock(s.locker, stakeV2LockKey+s.from, stakeV2LockTimeout); err != nil {
+		return ``, errors.Wrap(err, `加锁`)
+	}
+
+	defer func() {
+		_ = mutex.UnLock()
+	}()
+
+	if available, err = s.stake.CanDelegatedMaxSize(s.from, resource); err != nil {
+		return ``, errors.Wrap(err, `获取可代理数量`)
+	}
+
+	if available < amount {
+		s.logger.Info(`可代理TRX不足,补充质押`, zap.Int64(`可代理`, available), zap.Int64(`需要`, amount))
+
+		if _, err = s.stake.FreezeBalanceV2(s.from, s.privateKey, resource, amount-available); err != nil {
+			return ``, errors.Wrap(err, `质押`)
+		}
+
+		// 质押上链前节点会以可代理数量不足拒绝代理
+		if err = s.waitFrozen(resource, amount); err != nil {
+			return ``, err
+		}
+
+		if valid, validErr := mutex.Valid(); validErr != nil || !valid {
+			return ``, errors.New(`等待质押上链时锁已失效`)
+		}
+	}
+
+	return s.stake.DelegateResource(s.from, s.privateKey, to, resource, amount)
+}
+
+/*
+waitFrozen 等待新的质押上链,直到可代理数量不小于 amount
+参数:
+*	resource	core.ResourceCode	资源类型
+*	amount  	int64            	需要代理的数量,单位SUN
+返回值:
+*	error   	error            	错误,超过 freezeWait 仍不足时返回
+*/
+func (s stakeV2Service) waitFrozen(resource core.ResourceCode, amount int64) error {
+	var (
+		available int64
+		err       error
+	)
+
+	for waited := time.Duration(0); waited < s.freezeWait; waited += s.freezePoll {
+		s.freezeSleep(s.freezePoll)
+
+		if available, err = s.stake.CanDelegatedMaxSize(s.from, resource); err != nil {
+			s.logger.Warn(`查询可代理数量失败,继续等待`, helpers.ZapError(err))
+			continue
+		}
+
+		if available >= amount {
+			return nil
+		}
+	}
+
+	return fmt.Errorf(`等待质押上链超时,可代理[%d]需要[%d]`, available, amount)
+}
+
+/*
+UnFreeze 取消对收益方的全部代理,TRX 仍然质押在池中
+参数:
+*	to   	string	收益地址
+返回值:
+*	error	error 	错误
+*/
+func (s stakeV2Service) UnFreeze(to string) error {
+	_, err := s.Reclaim(to, helpers.Now())
+
+	return err
+}
+
+/*
+Reclaim 取消对收益方在 before 之前的代理
+参数:
+*	to       	string      	收益地址
+*	before   	helpers.Time	代理时间上限
+返回值:
+*	reclaimed	bool        	是否实际取消代理,没有到期记录时为false
+*	err      	error       	错误
+*/
+func (s stakeV2Service) Reclaim(to string, before helpers.Time) (reclaimed bool, err error) {
+	var (
+		mutex   redislock.Mutex
+		records []FreezeRecord
+	)
+
+	if mutex, err = redislock.GetAndLock(s.locker, to, lockTimeout); err != nil {
+		return false, errors.Wrap(err, `加锁`)
+	}
+
+	defer func() {
+		_ = mutex.UnLock()
+	}()
+
+	if err = s.db.Model(modelRecord).Where("`from` = ? and `to` = ? and stake_v2 = ? and unfreeze_tx_id = ? and `time` <= ?", s.from, to, true, ``, before).
+		Find(&records).Error; err != nil {
+		return false, errors.Wrap(err, `查询代理记录`)
+	}
+
+	// 能量和带宽需要分别取消代理
+	grouped := make(map[core.ResourceCode][]FreezeRecord, 2)
+
+	for _, record := range records {
+		grouped[record.resource()] = append(grouped[record.resource()], record)
+	}
+
+	for _, resource := range []core.ResourceCode{core.ResourceCode_ENERGY, core.ResourceCode_BANDWIDTH} {
+		if len(grouped[resource]) == 0 {
+			continue
+		}
+
+		if err = s.undelegate(to, resource, grouped[resource]); err != nil {
+			return reclaimed, err
+		}
+
+		reclaimed = true
+	}
+
+	return reclaimed, nil
+}
+
+func (s stakeV2Service) IsStakeV2() bool {
+	return true
+}
+
+func (s stakeV2Service) undelegate(to string, resource core.ResourceCode, records []FreezeRecord) error {
+	amount := decimal.Zero
+	ids := make([]int64, 0, len(records))
+
+	for _, record := range records {
+		amount = amount.Add(record.Amount)
+		ids = append(ids, record.ID)
+	}
+
+	info := NewFreezeInfo(s.from, to, amount)
+
+	s.EveryBeforeUnfreeze(info)
+
+	txID, err := s.stake.UnDelegateResource(s.from, s.privateKey, to, resource, amount.IntPart()*TRXToSUN)
+
+	s.EveryAfterUnfreeze(info, err)
+
+	if err != nil {
+		if _, saveErr := s.CreateFailRecord(to, err.Error(), amount, false); saveErr != nil {
+			s.logger.Error(`创建操作失败记录错误`, helpers.ZapError(err))
+		}
+
+		return errors.Wrap(err, `取消代理`)
+	}
+
+	return s.db.Model(modelRecord).Where(`id in ?`, ids).Updates(map[string]interface{}{
+		`unfreeze_tx_id`: txID,
+		`unfreeze_time`:  helpers.Now(),
+	}).Error
+}
+
+/*
+FreezeForTransfer 用于TRC20的Transfer,设置了资源估算时只代理缺少的能量和带宽,否则代理固定的 TRXForSingleEnergy
+参数:
+*	to   	string	收益方
+返回值:
+*	error	error 	错误
+*/
+func (s stakeV2Service) FreezeForTransfer(to string) error {
+	if s.estimator == nil {
+		return s.Freeze(to, decimal.New(TRXForSingleEnergy, 0))
+	}
+
+	_, err := s.freezeByEstimate(to, ``, decimal.Zero, s.freeze)
+
+	return err
+}
+
+/*
+FreezeForTRC20Transfer 估算转账缺少的能量,只代理缺少的部分
+参数:
+*	to      	string         	收益方,也就是转出地址
+*	receiver	string         	收款地址
+*	amount  	decimal.Decimal	转账金额
+返回值:
+*	estimate	*Estimate      	估算结果
+*	err     	error          	错误
+*/
+func (s stakeV2Service) FreezeForTRC20Transfer(to, receiver string, amount decimal.Decimal) (estimate *Estimate, err error) {
+	return s.freezeByEstimate(to, receiver, amount, s.freeze)
+}
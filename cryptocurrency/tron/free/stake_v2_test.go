@@ -0,0 +1,106 @@
+package free
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fighterlyt/gotron-sdk/pkg/proto/core"
+	"github.com/fighterlyt/log"
+	"github.com/fighterlyt/redislock"
+	"github.com/go-redsync/redsync/v4"
+	"github.com/stretchr/testify/require"
+)
+
+var errInsufficient = errors.New(`可代理数量不足`)
+
+type mockMutex struct{}
+
+func (m mockMutex) Lock() error { return nil }
+
+func (m mockMutex) UnLock() error { return nil }
+
+func (m mockMutex) Valid() (bool, error) { return true, nil }
+
+type mockLocker struct {
+	expire time.Duration
+}
+
+func (m *mockLocker) GetMutex(_ string, expire time.Duration, _ ...redsync.Option) (redislock.Mutex, error) {
+	m.expire = expire
+
+	return mockMutex{}, nil
+}
+
+// mockStakeClient 质押在 pending 次查询后才上链
+type mockStakeClient struct {
+	available int64
+	frozen    int64
+	pending   int
+	delegated int64
+}
+
+func (m *mockStakeClient) FreezeBalanceV2(_, _ string, _ core.ResourceCode, amount int64) (string, error) {
+	m.frozen = amount
+
+	return `freeze`, nil
+}
+
+func (m *mockStakeClient) DelegateResource(_, _, _ string, _ core.ResourceCode, amount int64) (string, error) {
+	if amount > m.available {
+		return ``, errInsufficient
+	}
+
+	m.delegated = amount
+
+	return `delegate`, nil
+}
+
+func (m *mockStakeClient) UnDelegateResource(_, _, _ string, _ core.ResourceCode, _ int64) (string, error) {
+	return `undelegate`, nil
+}
+
+func (m *mockStakeClient) CanDelegatedMaxSize(_ string, _ core.ResourceCode) (int64, error) {
+	if m.frozen > 0 {
+		if m.pending == 0 {
+			m.available += m.frozen
+			m.frozen = 0
+		} else {
+			m.pending--
+		}
+	}
+
+	return m.available, nil
+}
+
+func newTestStakeV2(t *testing.T, stake *mockStakeClient, locker *mockLocker) *stakeV2Service {
+	logger, err := log.NewEasyLogger(true, false, ``, `质押`)
+	require.NoError(t, err)
+
+	return &stakeV2Service{
+		service:     &service{logger: logger, locker: locker, from: `from`},
+		stake:       stake,
+		freezeWait:  time.Second * 10,
+		freezePoll:  time.Second,
+		freezeSleep: func(time.Duration) {},
+	}
+}
+
+func TestStakeV2Service_delegate(t *testing.T) {
+	stake := &mockStakeClient{available: 10, pending: 2}
+	locker := &mockLocker{}
+	target := newTestStakeV2(t, stake, locker)
+
+	txID, err := target.delegate(`to`, 30, core.ResourceCode_ENERGY)
+	require.NoError(t, err, `等待质押上链后代理`)
+	require.Equal(t, `delegate`, txID)
+	require.EqualValues(t, 30, stake.delegated)
+	require.Greater(t, locker.expire, defaultStakeTimeout*2, `锁覆盖质押和代理`)
+
+	stake = &mockStakeClient{available: 10, pending: 100}
+	target = newTestStakeV2(t, stake, locker)
+
+	_, err = target.delegate(`to`, 30, core.ResourceCode_ENERGY)
+	require.Error(t, err, `质押一直未上链`)
+	require.Zero(t, stake.delegated, `超时不代理`)
+}
@@ -0,0 +1,196 @@
+package parser
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/fighterlyt/common/localdb"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Progress 扫描进度
+type Progress struct {
+	Key        string  `gorm:"column:key;primaryKey;type:varchar(64);comment:扫描器标识" json:"key"` // 扫描器标识
+	Height     int64   `gorm:"column:height;type:bigint;comment:已经扫描的高度" json:"height"`         // 已经扫描的高度
+	Content    string  `gorm:"column:failed;type:text;comment:待重试的区块,JSON" json:"-"`            // 待重试的区块,JSON
+	UpdateTime int64   `gorm:"column:updateTime;type:bigint;comment:更新时间" json:"updateTime"`    // 更新时间
+	Failed     []int64 `gorm:"-" json:"failed"`                                                 // 待重试的区块
+}
+
+/*
+NewProgress 新建扫描进度
+参数:
+*	key      	string   	扫描器标识
+*	height   	int64    	已经扫描的高度
+*	failed   	[]int64  	待重试的区块
+返回值:
+*	*Progress	*Progress	进度
+*/
+func NewProgress(key string, height int64, failed []int64) *Progress {
+	return &Progress{
+		Key:        key,
+		Height:     height,
+		Failed:     failed,
+		UpdateTime: time.Now().Unix(),
+	}
+}
+
+/*
+BeforeSave gorm hook,序列化待重试的区块
+参数:
+*	tx   	*gorm.DB	数据库
+返回值:
+*	error	error   	错误
+*/
+func (p *Progress) BeforeSave(_ *gorm.DB) error {
+	data, err := json.Marshal(p.Failed)
+	if err != nil {
+		return errors.Wrap(err, `序列化待重试区块`)
+	}
+
+	p.Content = string(data)
+
+	return nil
+}
+
+/*
+AfterFind gorm hook,反序列化待重试的区块
+参数:
+*	tx   	*gorm.DB	数据库
+返回值:
+*	error	error   	错误
+*/
+func (p *Progress) AfterFind(_ *gorm.DB) error {
+	if p.Content == `` {
+		return nil
+	}
+
+	return errors.Wrap(json.Unmarshal([]byte(p.Content), &p.Failed), `反序列化待重试区块`)
+}
+
+/*
+TableName mysql表名
+参数:
+返回值:
+*	string	string	表名
+*/
+func (Progress) TableName() string {
+	return `tron_scanner_progress`
+}
+
+// localKey 本地存储使用的key
+func (p Progress) localKey() []byte {
+	return []byte(`tron_scanner_progress:` + p.Key)
+}
+
+// Checkpoint 扫描进度的持久化
+type Checkpoint interface {
+	// Load 读取进度,不存在时返回nil
+	Load(key string) (*Progress, error)
+	// Save 保存进度
+	Save(progress *Progress) error
+}
+
+// mysqlCheckpoint 使用MYSQL保存进度
+type mysqlCheckpoint struct {
+	db *gorm.DB
+}
+
+/*
+NewMySQLCheckpoint 新建MYSQL进度存储,会自动建表
+参数:
+*	db        	*gorm.DB  	数据库
+返回值:
+*	Checkpoint	Checkpoint	进度存储
+*	error     	error     	错误
+*/
+func NewMySQLCheckpoint(db *gorm.DB) (Checkpoint, error) {
+	if db == nil {
+		return nil, errors.New(`db不能为空`)
+	}
+
+	if err := db.AutoMigrate(&Progress{}); err != nil {
+		return nil, errors.Wrap(err, `创建表`)
+	}
+
+	return mysqlCheckpoint{db: db}, nil
+}
+
+func (m mysqlCheckpoint) Load(key string) (*Progress, error) {
+	progress := &Progress{}
+
+	if err := m.db.Where("`key` = ?", key).First(progress).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+
+		return nil, errors.Wrapf(err, `获取进度[%s]`, key)
+	}
+
+	return progress, nil
+}
+
+func (m mysqlCheckpoint) Save(progress *Progress) error {
+	if err := m.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(progress).Error; err != nil {
+		return errors.Wrapf(err, `保存进度[%s]`, progress.Key)
+	}
+
+	return nil
+}
+
+// localItem 将进度适配为 localdb.Item
+type localItem struct {
+	*Progress
+}
+
+func (l localItem) Key() []byte {
+	return l.localKey()
+}
+
+func (l localItem) Encode() ([]byte, error) {
+	return json.Marshal(l.Progress)
+}
+
+func (l localItem) Decode(data []byte) error {
+	return json.Unmarshal(data, l.Progress)
+}
+
+// localCheckpoint 使用本地存储保存进度
+type localCheckpoint struct {
+	service localdb.Service
+}
+
+/*
+NewLocalCheckpoint 新建本地进度存储
+参数:
+*	service   	localdb.Service	本地存储
+返回值:
+*	Checkpoint	Checkpoint     	进度存储
+*/
+func NewLocalCheckpoint(service localdb.Service) Checkpoint {
+	return localCheckpoint{service: service}
+}
+
+func (l localCheckpoint) Load(key string) (*Progress, error) {
+	item := localItem{Progress: &Progress{Key: key}}
+
+	if err := l.service.Read(item.Key(), item); err != nil {
+		if l.service.IsNotFound(err) {
+			return nil, nil
+		}
+
+		return nil, errors.Wrapf(err, `获取进度[%s]`, key)
+	}
+
+	return item.Progress, nil
+}
+
+func (l localCheckpoint) Save(progress *Progress) error {
+	if err := l.service.Write(localItem{Progress: progress}); err != nil {
+		return errors.Wrapf(err, `保存进度[%s]`, progress.Key)
+	}
+
+	return nil
+}
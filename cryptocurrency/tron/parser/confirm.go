@@ -0,0 +1,328 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/fighterlyt/common/cryptocurrency"
+	"github.com/fighterlyt/common/helpers"
+	"github.com/fighterlyt/gotron-sdk/pkg/proto/api"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	confirmedCacheExpire = 3 * time.Second // 确认高度的缓存时间,波场出块间隔
+	maxReorgDepth        = 32              // 向前追溯分叉的最大深度
+)
+
+var (
+	solidityBlockReg = regexp.MustCompile(`Num:(\d+)`)
+)
+
+// parsedBlock 已经解析的区块,确认之后保留 maxReorgDepth 个区块用于检查分叉
+type parsedBlock struct {
+	hash      string                              // 区块哈希
+	parent    string                              // 父区块哈希
+	details   []*cryptocurrency.TransactionDetail // 待通知的交易
+	notified  []*cryptocurrency.TransactionDetail // 已经通知的交易,区块被替换时回滚
+	confirmed bool                                // 是否已经确认
+}
+
+// confirmTracker 跟踪解析过的区块,区块确认后才通知交易,已经通知的区块哈希变化时回滚
+type confirmTracker struct {
+	lock           *sync.Mutex
+	blocks         map[int64]*parsedBlock
+	confirmations  int64 // 确认数,为0时使用固化区块高度
+	confirmed      int64 // 缓存的确认高度
+	confirmedAt    time.Time
+	revert         cryptocurrency.TradeRevertNotify
+	confirmedBlock func() (int64, error)
+}
+
+func newConfirmTracker() *confirmTracker {
+	return &confirmTracker{
+		lock:   &sync.Mutex{},
+		blocks: make(map[int64]*parsedBlock),
+	}
+}
+
+/*
+SetConfirmation 设置确认方式和回滚通知
+参数:
+*	confirmations	int64                            	确认数,为0时以固化区块为准
+*	revert       	cryptocurrency.TradeRevertNotify	回滚通知,可以为nil
+返回值:
+*/
+func (g *grpcParser) SetConfirmation(confirmations int64, revert cryptocurrency.TradeRevertNotify) {
+	g.tracker.lock.Lock()
+	defer g.tracker.lock.Unlock()
+
+	g.tracker.confirmations = confirmations
+	g.tracker.revert = revert
+	g.tracker.confirmedAt = time.Time{}
+}
+
+/*
+confirmedHeight 已经确认的最大区块高度,短时间内缓存
+参数:
+返回值:
+*	height	int64	高度
+*	err   	error	错误
+*/
+func (g grpcParser) confirmedHeight() (height int64, err error) {
+	g.tracker.lock.Lock()
+	defer g.tracker.lock.Unlock()
+
+	if time.Since(g.tracker.confirmedAt) < confirmedCacheExpire {
+		return g.tracker.confirmed, nil
+	}
+
+	if g.tracker.confirmedBlock != nil {
+		height, err = g.tracker.confirmedBlock()
+	} else {
+		height, err = g.fetchConfirmedHeight(g.tracker.confirmations)
+	}
+
+	if err != nil {
+		helpers.IgnoreError(g.logger, "重启波场grpc客户端", func() error {
+			return g.grpcClient.Reconnect(g.grpcClient.Address)
+		})
+
+		return 0, err
+	}
+
+	g.tracker.confirmed, g.tracker.confirmedAt = height, time.Now()
+
+	return height, nil
+}
+
+func (g grpcParser) fetchConfirmedHeight(confirmations int64) (int64, error) {
+	if confirmations > 0 {
+		block, err := g.grpcClient.GetNowBlock()
+		if err != nil {
+			return 0, errors.Wrap(err, `GetNowBlock`)
+		}
+
+		return block.GetBlockHeader().GetRawData().GetNumber() - confirmations, nil
+	}
+
+	info, err := g.grpcClient.GetNodeInfo()
+	if err != nil {
+		return 0, errors.Wrap(err, `GetNodeInfo`)
+	}
+
+	matched := solidityBlockReg.FindStringSubmatch(info.GetSolidityBlock())
+	if len(matched) != 2 {
+		return 0, fmt.Errorf(`无法解析固化区块[%s]`, info.GetSolidityBlock())
+	}
+
+	return strconv.ParseInt(matched[1], 10, 64)
+}
+
+/*
+IsBlockConfirmed 区块是否被确认
+参数:
+*	ctx        	context.Context		上下文
+*	blockNumber	int64				区块号
+返回值:
+*	confirmed	bool			    是否被确认
+*	err      	error			    错误信息
+*/
+func (g grpcParser) IsBlockConfirmed(_ context.Context, blockNumber int64) (confirmed bool, err error) {
+	height, err := g.confirmedHeight()
+	if err != nil {
+		return false, errors.Wrap(err, `获取确认高度`)
+	}
+
+	return blockNumber <= height, nil
+}
+
+func blockHashes(block *api.BlockExtention) (hash, parent string) {
+	return hexutil.Encode(block.GetBlockid()), hexutil.Encode(block.GetBlockHeader().GetRawData().GetParentHash())
+}
+
+/*
+track 记录解析结果并检查分叉,返回需要重新解析的区块.
+同一高度哈希变化,或者父区块哈希和记录不一致时,认为记录的区块已经被替换:
+未通知的交易直接丢弃,已经通知的交易回滚.
+同一区块重复解析(例如重试)时,已经通知过的交易不再通知
+参数:
+*	blockNumber	int64                               	区块号
+*	block      	*api.BlockExtention                 	区块
+*	details    	[]*cryptocurrency.TransactionDetail	待通知的交易
+返回值:
+*	reparse    	[]int64                             	需要重新解析的区块
+*/
+func (g grpcParser) track(blockNumber int64, block *api.BlockExtention, details []*cryptocurrency.TransactionDetail) (reparse []int64) { //nolint:lll
+	hash, parent := blockHashes(block)
+
+	g.tracker.lock.Lock()
+	defer g.tracker.lock.Unlock()
+
+	current := &parsedBlock{hash: hash, parent: parent, details: details}
+
+	if old, exist := g.tracker.blocks[blockNumber]; exist {
+		if old.hash == hash {
+			current.details = unnotified(details, old.notified)
+			current.notified, current.confirmed = old.notified, old.confirmed
+		} else {
+			g.replaceLocked(blockNumber, old)
+		}
+	}
+
+	g.tracker.blocks[blockNumber] = current
+
+	// 沿父区块向前检查,直到哈希一致或者没有记录
+	for number, expect := blockNumber-1, parent; number > blockNumber-maxReorgDepth; number-- {
+		old, exist := g.tracker.blocks[number]
+		if !exist || old.hash == expect {
+			break
+		}
+
+		g.replaceLocked(number, old)
+		delete(g.tracker.blocks, number)
+
+		reparse = append(reparse, number)
+		expect = old.parent
+	}
+
+	return reparse
+}
+
+// unnotified 过滤已经通知过的交易
+func unnotified(details, notified []*cryptocurrency.TransactionDetail) []*cryptocurrency.TransactionDetail {
+	if len(notified) == 0 {
+		return details
+	}
+
+	ids := make(map[string]struct{}, len(notified))
+	for _, detail := range notified {
+		ids[detail.TxID] = struct{}{}
+	}
+
+	result := make([]*cryptocurrency.TransactionDetail, 0, len(details))
+
+	for _, detail := range details {
+		if _, exist := ids[detail.TxID]; !exist {
+			result = append(result, detail)
+		}
+	}
+
+	return result
+}
+
+// replaceLocked 区块被替换,只回滚已经通知的交易,调用方持有锁
+func (g grpcParser) replaceLocked(blockNumber int64, old *parsedBlock) {
+	g.logger.Warn(`区块被替换`, zap.Int64(`区块号`, blockNumber), zap.String(`原哈希`, old.hash), zap.Int(`丢弃交易数量`, len(old.details)), zap.Int(`回滚交易数量`, len(old.notified))) //nolint:lll
+
+	if len(old.notified) == 0 || g.tracker.revert == nil {
+		return
+	}
+
+	for _, group := range groupByProtocol(old.notified) {
+		group := group
+
+		helpers.IgnoreError(g.logger, "回滚交易", func() error {
+			return g.tracker.revert.Revert(group.protocol, blockNumber, group.details)
+		})
+	}
+}
+
+// protocolDetails 同一协议的交易
+type protocolDetails struct {
+	protocol cryptocurrency.Protocol
+	details  []*cryptocurrency.TransactionDetail
+}
+
+// groupByProtocol 按照协议分组,保持首次出现的顺序,TRC10和TRC721不能按照TRC20通知
+func groupByProtocol(details []*cryptocurrency.TransactionDetail) []protocolDetails {
+	var (
+		groups []protocolDetails
+		index  = make(map[cryptocurrency.Protocol]int)
+	)
+
+	for _, detail := range details {
+		i, exist := index[detail.Protocol]
+		if !exist {
+			i = len(groups)
+			index[detail.Protocol] = i
+			groups = append(groups, protocolDetails{protocol: detail.Protocol})
+		}
+
+		groups[i].details = append(groups[i].details, detail)
+	}
+
+	return groups
+}
+
+/*
+release 通知已经确认的区块中的交易,清除超过分叉检查深度的记录
+参数:
+返回值:
+*/
+func (g grpcParser) release() {
+	height, err := g.confirmedHeight()
+	if err != nil {
+		g.logger.Warn(`获取确认高度失败,延后通知`, zap.String(`错误`, err.Error()))
+
+		return
+	}
+
+	var (
+		details []*cryptocurrency.TransactionDetail
+	)
+
+	g.tracker.lock.Lock()
+	for number, block := range g.tracker.blocks {
+		if number > height {
+			continue
+		}
+
+		details = append(details, block.details...)
+		block.notified = append(block.notified, block.details...)
+		block.details, block.confirmed = nil, true
+
+		if number <= height-maxReorgDepth {
+			delete(g.tracker.blocks, number)
+		}
+	}
+	g.tracker.lock.Unlock()
+
+	if g.notify == nil {
+		return
+	}
+
+	for _, group := range groupByProtocol(details) {
+		group := group
+
+		helpers.IgnoreError(g.logger, "通知交易", func() error {
+			return g.notify.Notify(group.protocol, group.details)
+		})
+	}
+}
+
+/*
+LowestUnconfirmed 最小的未确认区块,扫描器只把进度保存到它之前,保证重启后未通知的交易能重新解析
+参数:
+返回值:
+*	blockNumber	int64	区块号
+*	exist      	bool 	是否存在未确认区块
+*/
+func (g grpcParser) LowestUnconfirmed() (blockNumber int64, exist bool) {
+	g.tracker.lock.Lock()
+	defer g.tracker.lock.Unlock()
+
+	for number, block := range g.tracker.blocks {
+		if !block.confirmed && (!exist || number < blockNumber) {
+			blockNumber, exist = number, true
+		}
+	}
+
+	return blockNumber, exist
+}
@@ -0,0 +1,254 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/fighterlyt/common/cryptocurrency"
+	"github.com/fighterlyt/common/helpers"
+	"github.com/fighterlyt/common/model"
+	"github.com/fighterlyt/gotron-sdk/pkg/common"
+	"github.com/fighterlyt/gotron-sdk/pkg/proto/api"
+	"github.com/fighterlyt/gotron-sdk/pkg/proto/core"
+	"github.com/fighterlyt/log"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+)
+
+const (
+	tronAddressPrefix = 0x41 // 波场地址前缀
+	evmAddressLength  = 20   // 日志中的地址长度,不包含前缀
+	trc20TopicCount   = 3    // Transfer(address indexed,address indexed,uint256)
+	trc721TopicCount  = 4    // Transfer(address indexed,address indexed,uint256 indexed)
+)
+
+var (
+	// transferTopic keccak256("Transfer(address,address,uint256)"),TRC20和TRC721相同
+	transferTopic = hexutil.MustDecode(`0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef`)
+)
+
+/*
+IncludeEvents 是否解析交易日志和内部交易,用于获取通过合约调用产生的转账
+参数:
+*	include	bool	是否包含
+返回值:
+*/
+func (g *grpcParser) IncludeEvents(include bool) {
+	g.includeEvents = include
+}
+
+/*
+WatchTRC721 解析指定NFT合约的转账,需要同时开启 IncludeEvents
+参数:
+*	contracts	...string	NFT合约地址
+返回值:
+*/
+func (g *grpcParser) WatchTRC721(contracts ...string) {
+	g.trc721 = make(map[string]struct{}, len(contracts))
+
+	for _, contract := range contracts {
+		g.trc721[contract] = struct{}{}
+	}
+}
+
+// eventTrade 从日志或者内部交易中得到的转账
+type eventTrade struct {
+	protocol cryptocurrency.Protocol
+	from     string
+	to       string
+	amount   decimal.Decimal
+	token    string
+	kind     cryptocurrency.TradeKind
+}
+
+// logAddress 日志中的地址不包含41前缀
+func logAddress(data []byte) string {
+	if len(data) > evmAddressLength {
+		data = data[len(data)-evmAddressLength:]
+	}
+
+	return common.EncodeCheck(append([]byte{tronAddressPrefix}, data...))
+}
+
+/*
+parseBlockInfo 解析区块中全部交易的日志和内部交易
+参数:
+*	blockNumber	int64                               	区块号
+*	logger     	log.Logger                          	日志器
+返回值:
+*	trades     	[]*cryptocurrency.Trade             	交易
+*	details    	[]*cryptocurrency.TransactionDetail	通知
+*	err        	error                               	错误
+*/
+func (g grpcParser) parseBlockInfo(blockNumber int64, logger log.Logger) (trades []*cryptocurrency.Trade, details []*cryptocurrency.TransactionDetail, err error) { //nolint:lll
+	var (
+		infos *api.TransactionInfoList
+	)
+
+	if infos, err = g.grpcClient.GetBlockInfoByNum(blockNumber); err != nil {
+		helpers.IgnoreError(g.logger, "重启波场grpc客户端", func() error {
+			return g.grpcClient.Reconnect(g.grpcClient.Address)
+		})
+
+		return nil, nil, errors.Wrap(err, "GetBlockInfoByNum")
+	}
+
+	for _, info := range infos.GetTransactionInfo() {
+		if info.GetResult() != core.TransactionInfo_SUCESS {
+			continue
+		}
+
+		events, parseErr := g.parseInfo(info)
+		if parseErr != nil {
+			err = multierr.Append(err, parseErr)
+			continue
+		}
+
+		txID := hexutil.Encode(info.GetId())[2:]
+		fee := decimal.New(info.GetFee(), -6)
+		tradeTime := info.GetBlockTimeStamp() / int64(kilo)
+
+		for i, event := range events {
+			matched, _, matchErr := g.concern.FilterConcernedAccounts(event.from, event.to, event.amount)
+			if matchErr != nil {
+				err = multierr.Append(err, errors.Wrapf(matchErr, `判断关注交易错误,转出[%s]转入[%s],金额[%s]`, event.from, event.to, event.amount.String()))
+				continue
+			}
+
+			if !matched {
+				continue
+			}
+
+			logger.Info(`合约内转账匹配`, zap.String(`交易`, txID), zap.Strings(`from/to/amount`, []string{event.from, event.to, event.amount.String()}))
+
+			// 同一个交易可能有多笔转账,使用序号区分
+			id := fmt.Sprintf(`%s_%d`, txID, i)
+
+			trades = append(trades, cryptocurrency.NewTrade(event.protocol, event.from, event.to, event.amount, event.token, id, tradeTime, blockNumber, fee, event.kind))                   // nolint:golint,lll
+			details = append(details, cryptocurrency.NewFullTransactionDetail(event.amount, event.protocol, event.token, event.from, event.to, blockNumber, id, fee, tradeTime, event.kind)) //nolint:lll
+		}
+	}
+
+	return trades, details, err
+}
+
+/*
+parseInfo 解析单个交易的Transfer日志和内部交易.
+直接调用关注合约的 transfer/transferFrom 已经由 parseTrc20 处理,对应日志跳过,避免重复
+参数:
+*	info  	*core.TransactionInfo	交易信息
+返回值:
+*	events	[]eventTrade         	转账
+*	err   	error                	错误
+*/
+func (g grpcParser) parseInfo(info *core.TransactionInfo) (events []eventTrade, err error) {
+	called := ``
+	if len(info.GetContractAddress()) != 0 {
+		called = common.EncodeCheck(info.GetContractAddress())
+	}
+
+	for _, item := range info.GetLog() {
+		topics := item.GetTopics()
+
+		if len(topics) == 0 || !bytes.Equal(topics[0], transferTopic) {
+			continue
+		}
+
+		address := logAddress(item.GetAddress())
+
+		switch {
+		case len(topics) == trc20TopicCount && address == g.contract.Address():
+			if called == address {
+				continue
+			}
+
+			events = append(events, eventTrade{
+				protocol: cryptocurrency.Trc20,
+				from:     logAddress(topics[1]),
+				to:       logAddress(topics[2]),
+				amount:   decimal.NewFromBigInt(new(big.Int).SetBytes(item.GetData()), -g.contract.Precision()),
+				token:    g.contract.Token(),
+				kind:     cryptocurrency.TradeInternalTransfer,
+			})
+		case len(topics) == trc721TopicCount:
+			if _, watched := g.trc721[address]; !watched {
+				continue
+			}
+
+			events = append(events, eventTrade{
+				protocol: cryptocurrency.Trc721,
+				from:     logAddress(topics[1]),
+				to:       logAddress(topics[2]),
+				amount:   decimal.New(1, 0),
+				token:    fmt.Sprintf(`%s#%s`, address, new(big.Int).SetBytes(topics[3]).String()),
+				kind:     cryptocurrency.TradeNFTTransfer,
+			})
+		}
+	}
+
+	for _, internal := range info.GetInternalTransactions() {
+		if internal.GetRejected() {
+			continue
+		}
+
+		internalEvents, internalErr := g.parseInternal(internal)
+		if internalErr != nil {
+			return nil, internalErr
+		}
+
+		events = append(events, internalEvents...)
+	}
+
+	return events, nil
+}
+
+/*
+parseInternal 解析内部交易中的TRX和TRC10转账
+参数:
+*	internal	*core.InternalTransaction	内部交易
+返回值:
+*	events  	[]eventTrade             	转账
+*	err     	error                    	错误
+*/
+func (g grpcParser) parseInternal(internal *core.InternalTransaction) (events []eventTrade, err error) {
+	from := common.EncodeCheck(internal.GetCallerAddress())
+	to := common.EncodeCheck(internal.GetTransferToAddress())
+
+	for _, value := range internal.GetCallValueInfo() {
+		if value.GetCallValue() <= 0 {
+			continue
+		}
+
+		switch {
+		case value.GetTokenId() == `` && g.includeTRX:
+			events = append(events, eventTrade{
+				protocol: cryptocurrency.Trc20,
+				from:     from,
+				to:       to,
+				amount:   decimal.New(value.GetCallValue(), -6),
+				token:    model.TRX,
+				kind:     cryptocurrency.TradeInternalTransfer,
+			})
+		case value.GetTokenId() != `` && g.includeTRC10:
+			precision, precisionErr := g.assetPrecision(value.GetTokenId())
+			if precisionErr != nil {
+				return nil, errors.Wrap(precisionErr, `获取代币精度`)
+			}
+
+			events = append(events, eventTrade{
+				protocol: cryptocurrency.Trc10,
+				from:     from,
+				to:       to,
+				amount:   decimal.New(value.GetCallValue(), -precision),
+				token:    value.GetTokenId(),
+				kind:     cryptocurrency.TradeInternalTransfer,
+			})
+		}
+	}
+
+	return events, nil
+}
@@ -31,12 +31,17 @@ const (
 )
 
 type grpcParser struct {
-	grpcClient *client.GrpcClient         // grpc客户端
-	logger     log.Logger                 // 日志器
-	concern    cryptocurrency.Concern     // concern 对地址的关注
-	contract   cryptocurrency.Contract    // 关注的智能合约
-	notify     cryptocurrency.TradeNotify // 交易通知
-	includeTRX bool                       // 是否包含TRX
+	grpcClient    *client.GrpcClient         // grpc客户端
+	logger        log.Logger                 // 日志器
+	concern       cryptocurrency.Concern     // concern 对地址的关注
+	contract      cryptocurrency.Contract    // 关注的智能合约
+	notify        cryptocurrency.TradeNotify // 交易通知
+	includeTRX    bool                       // 是否包含TRX
+	includeTRC10  bool                       // 是否包含TRC10
+	includeEvents bool                       // 是否解析日志和内部交易
+	trc721        map[string]struct{}        // 关注的NFT合约
+	assets        *assetPrecisions           // TRC10代币精度
+	tracker       *confirmTracker            // 区块确认和分叉跟踪
 }
 
 /*
@@ -57,6 +62,8 @@ func NewGRPCTronScanParser(concern cryptocurrency.Concern, grpClient *client.Grp
 		logger:     logger,
 		contract:   contract,
 		notify:     notify,
+		tracker:    newConfirmTracker(),
+		assets:     newAssetPrecisions(),
 	}
 }
 
@@ -65,20 +72,7 @@ func (g *grpcParser) IncludeTRX(include bool) {
 }
 
 /*
-IsBlockConfirmed 区块是否被确认
-参数:
-*	ctx        	context.Context		上下文
-*	blockNumber	int64				区块号
-返回值:
-*	confirmed	bool			    是否被确认
-*	err      	error			    错误信息
-*/
-func (g grpcParser) IsBlockConfirmed(_ context.Context, _ int64) (confirmed bool, err error) {
-	return true, nil
-}
-
-/*
-Parse 区块解析
+Parse 区块解析,交易在区块确认后才通知,发现分叉时回滚并重新解析被替换的区块
 参数:
 *	ctx        	context.Context		上下文
 *	blockNumber	int64				区块号
@@ -117,14 +111,30 @@ func (g grpcParser) Parse(ctx context.Context, blockNumber int64) (trades []*cry
 		}
 	}
 
-	logger.Info(`准备通知交易`, zap.Int(`交易数量`, len(notifyDetails)), zap.Bool(`是否有通知项`, g.notify != nil))
+	if g.includeEvents && g.concern != nil {
+		eventTrades, eventDetails, eventErr := g.parseBlockInfo(blockNumber, logger)
+		if eventErr != nil {
+			err = multierr.Append(err, eventErr)
+			logger.Error(`解析日志错误`, zap.String(`错误`, eventErr.Error()))
+		}
 
-	if len(notifyDetails) > 0 && g.notify != nil {
-		helpers.IgnoreError(g.logger, "通知交易", func() error {
-			return g.notify.Notify(cryptocurrency.Trc20, notifyDetails)
-		})
+		trades = append(trades, eventTrades...)
+		notifyDetails = append(notifyDetails, eventDetails...)
 	}
 
+	logger.Info(`等待确认后通知交易`, zap.Int(`交易数量`, len(notifyDetails)), zap.Bool(`是否有通知项`, g.notify != nil))
+
+	for _, number := range g.track(blockNumber, blockExtension, notifyDetails) {
+		reparsed, reparseErr := g.Parse(ctx, number)
+		if reparseErr != nil {
+			err = multierr.Append(err, errors.Wrapf(reparseErr, `重新解析区块[%d]`, number))
+		}
+
+		trades = append(trades, reparsed...)
+	}
+
+	g.release()
+
 	return trades, err
 }
 
@@ -170,6 +180,12 @@ func (g grpcParser) ParseTX(_ context.Context, tx *api.TransactionExtention, log
 		}
 
 		return g.parseTRx(contract, tx, logger, blockNumber, txID)
+	case core.Transaction_Contract_TransferAssetContract:
+		if !g.includeTRC10 {
+			return nil, nil, nil
+		}
+
+		return g.parseTRC10(contract, tx, logger, blockNumber, txID)
 	default:
 		return nil, nil, nil
 	}
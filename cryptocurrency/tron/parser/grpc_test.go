@@ -4,6 +4,7 @@ import (
 	"context"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/fighterlyt/common/cryptocurrency"
@@ -303,3 +304,148 @@ func parseTrc20(contract *core.Transaction_Contract, g *grpcParser, tx *api.Tran
 
 	return trade, detail, nil
 }
+
+type mockNotify struct {
+	notified map[int64]int
+	reverted map[int64]int
+}
+
+func (m mockNotify) Notify(_ cryptocurrency.Protocol, details []*cryptocurrency.TransactionDetail) error {
+	for _, detail := range details {
+		m.notified[detail.BlockNumber]++
+	}
+
+	return nil
+}
+
+func (m mockNotify) Revert(_ cryptocurrency.Protocol, blockNumber int64, details []*cryptocurrency.TransactionDetail) error {
+	m.reverted[blockNumber] += len(details)
+	return nil
+}
+
+func mockBlock(number int64, hash, parent string) *api.BlockExtention {
+	return &api.BlockExtention{
+		Blockid: []byte(hash),
+		BlockHeader: &core.BlockHeader{
+			RawData: &core.BlockHeaderRaw{Number: number, ParentHash: []byte(parent)},
+		},
+	}
+}
+
+func TestGrpcParser_track(t *testing.T) {
+	notify := mockNotify{notified: map[int64]int{}, reverted: map[int64]int{}}
+	confirmed := int64(0)
+
+	target := NewGRPCTronScanParser(mockConcern{}, nil, logger, cryptocurrency.ContractTRC20USDT, notify).(*grpcParser)
+	target.SetConfirmation(0, notify)
+	target.tracker.confirmedBlock = func() (int64, error) {
+		return confirmed, nil
+	}
+
+	detail := func(number int64) []*cryptocurrency.TransactionDetail {
+		return []*cryptocurrency.TransactionDetail{{BlockNumber: number}}
+	}
+
+	require.Empty(t, target.track(1, mockBlock(1, `a1`, `a0`), detail(1)))
+	require.Empty(t, target.track(2, mockBlock(2, `a2`, `a1`), detail(2)))
+
+	target.release()
+	require.Empty(t, notify.notified, `未确认不通知`)
+
+	// 区块2被替换,区块3的父区块不一致,未通知的交易直接丢弃
+	require.Equal(t, []int64{2}, target.track(3, mockBlock(3, `b3`, `b2`), detail(3)), `需要重新解析区块2`)
+	require.Empty(t, notify.reverted, `未通知的区块不回滚`)
+
+	require.Empty(t, target.track(2, mockBlock(2, `b2`, `a1`), detail(2)))
+
+	confirmed = 3
+	target.tracker.confirmedAt = time.Time{}
+	target.release()
+
+	require.Equal(t, map[int64]int{1: 1, 2: 1, 3: 1}, notify.notified, `确认后每个区块通知一次`)
+
+	// 重复解析已经通知的区块不再通知
+	require.Empty(t, target.track(3, mockBlock(3, `b3`, `b2`), detail(3)))
+	target.release()
+	require.Equal(t, 1, notify.notified[3], `不重复通知`)
+
+	// 已经通知的区块被替换,回滚后重新通知
+	require.Empty(t, target.track(3, mockBlock(3, `c3`, `b2`), detail(3)))
+	require.Equal(t, map[int64]int{3: 1}, notify.reverted, `回滚已经通知的区块3`)
+
+	target.release()
+	require.Equal(t, 2, notify.notified[3], `新的区块3确认后通知`)
+
+	_, exist := target.LowestUnconfirmed()
+	require.False(t, exist, `全部确认`)
+}
+
+func TestGroupByProtocol(t *testing.T) {
+	details := []*cryptocurrency.TransactionDetail{
+		{TxID: `1`, Protocol: cryptocurrency.Trc20},
+		{TxID: `2`, Protocol: cryptocurrency.Trc721},
+		{TxID: `3`, Protocol: cryptocurrency.Trc20},
+		{TxID: `4`, Protocol: cryptocurrency.Trc10},
+	}
+
+	groups := groupByProtocol(details)
+	require.Len(t, groups, 3)
+
+	require.Equal(t, cryptocurrency.Trc20, groups[0].protocol)
+	require.Equal(t, []*cryptocurrency.TransactionDetail{details[0], details[2]}, groups[0].details)
+	require.Equal(t, cryptocurrency.Trc721, groups[1].protocol)
+	require.Equal(t, cryptocurrency.Trc10, groups[2].protocol)
+}
+
+func TestGrpcParser_parseInfo(t *testing.T) {
+	target := NewGRPCTronScanParser(mockConcern{}, nil, logger, cryptocurrency.ContractTRC20USDT, nil).(*grpcParser)
+	target.IncludeTRX(true)
+	target.WatchTRC721(`TRZTJyNpKVevp959982XBbkjm7qrLxYTWi`)
+
+	address := func(base58 string) []byte {
+		data, decodeErr := common.DecodeCheck(base58)
+		require.NoError(t, decodeErr)
+
+		return data[1:]
+	}
+	topic := func(data []byte) []byte {
+		return append(make([]byte, 32-len(data)), data...)
+	}
+
+	usdt := address(cryptocurrency.TronUSDTContractAddress)
+	from, to := address(`TQn9Y2khEsLJW1ChVWFMSMeRDow5KcbLSE`), address(`TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t`)
+
+	info := &core.TransactionInfo{
+		ContractAddress: append([]byte{tronAddressPrefix}, from...),
+		Log: []*core.TransactionInfo_Log{
+			{Address: usdt, Topics: [][]byte{transferTopic, topic(from), topic(to)}, Data: topic([]byte{0x0f, 0x42, 0x40})},
+			{Address: address(`TRZTJyNpKVevp959982XBbkjm7qrLxYTWi`), Topics: [][]byte{transferTopic, topic(from), topic(to), topic([]byte{7})}},
+			{Address: address(`TQn9Y2khEsLJW1ChVWFMSMeRDow5KcbLSE`), Topics: [][]byte{transferTopic, topic(from), topic(to), topic([]byte{8})}},
+		},
+		InternalTransactions: []*core.InternalTransaction{
+			{CallerAddress: append([]byte{tronAddressPrefix}, from...), TransferToAddress: append([]byte{tronAddressPrefix}, to...), CallValueInfo: []*core.InternalTransaction_CallValueInfo{{CallValue: 2000000}}},
+			{Rejected: true, CallValueInfo: []*core.InternalTransaction_CallValueInfo{{CallValue: 1}}},
+		},
+	}
+
+	events, err := target.parseInfo(info)
+	require.NoError(t, err)
+	require.Len(t, events, 3, `TRC20日志,关注的NFT和内部交易`)
+
+	require.Equal(t, cryptocurrency.Trc20, events[0].protocol)
+	require.Equal(t, `1`, events[0].amount.String(), `USDT精度6`)
+	require.Equal(t, `TQn9Y2khEsLJW1ChVWFMSMeRDow5KcbLSE`, events[0].from)
+	require.Equal(t, cryptocurrency.TradeInternalTransfer, events[0].kind)
+
+	require.Equal(t, cryptocurrency.Trc721, events[1].protocol)
+	require.Equal(t, `TRZTJyNpKVevp959982XBbkjm7qrLxYTWi#7`, events[1].token)
+
+	require.Equal(t, model.TRX, events[2].token)
+	require.Equal(t, `2`, events[2].amount.String())
+
+	// 直接调用USDT合约的日志已经由 parseTrc20 处理
+	info.ContractAddress = append([]byte{tronAddressPrefix}, usdt...)
+	events, err = target.parseInfo(info)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+}
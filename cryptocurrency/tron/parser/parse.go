@@ -10,4 +10,12 @@ import (
 type TronParser interface {
 	Parse(ctx context.Context, blockNumber int64) (trades []*cryptocurrency.Trade, err error)
 	IncludeTRX(include bool)
+	// IncludeTRC10 是否解析TRC10转账
+	IncludeTRC10(include bool)
+	// IncludeEvents 是否解析日志和内部交易中的转账
+	IncludeEvents(include bool)
+	// WatchTRC721 解析指定NFT合约的转账
+	WatchTRC721(contracts ...string)
+	// SetConfirmation 设置确认数和回滚通知,确认数为0时以固化区块为准
+	SetConfirmation(confirmations int64, revert cryptocurrency.TradeRevertNotify)
 }
@@ -0,0 +1,465 @@
+package parser
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fighterlyt/common/cryptocurrency"
+	"github.com/fighterlyt/common/helpers"
+	"github.com/fighterlyt/common/model"
+	"github.com/fighterlyt/gotron-sdk/pkg/client"
+	"github.com/fighterlyt/log"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultScanInterval    = 3 * time.Second // 默认轮询间隔,波场出块间隔
+	defaultScanConcurrency = 4               // 默认并发数
+	defaultScanBatch       = 20              // 默认每轮最多扫描的区块数
+	defaultParseTimeout    = 30 * time.Second
+	defaultMaxRetries      = 10               // 默认最多重试次数
+	maxRetryBackoff        = 10 * time.Minute // 重试间隔上限
+)
+
+// ScanHandler 区块解析成功后的回调,返回错误时区块进入重试队列.重试或者重启后同一区块可能再次回调,需要按交易ID幂等
+type ScanHandler func(ctx context.Context, blockNumber int64, trades []*cryptocurrency.Trade) error
+
+// unconfirmedTracker 解析器缓存了未确认区块的交易,进度不能超过最小的未确认区块
+type unconfirmedTracker interface {
+	LowestUnconfirmed() (blockNumber int64, exist bool)
+}
+
+// ScannerOption 扫描器选项
+type ScannerOption struct {
+	Key          string        // 扫描器标识,用于保存进度,不同业务使用不同的标识
+	Start        int64         // 没有进度时开始扫描的区块,为0表示从最新区块开始
+	Concurrency  int           // 并发解析的区块数
+	Batch        int           // 每轮最多扫描的区块数,落后较多时分多轮追赶
+	Interval     time.Duration // 轮询间隔
+	ParseTimeout time.Duration // 单个区块的解析超时
+	MaxRetries   int           // 失败区块按指数退避重试的次数,超过后每次失败记录错误日志,按最大间隔继续重试
+	RetryBackoff time.Duration // 第一次重试的间隔,之后每次翻倍,默认等于轮询间隔
+}
+
+func (o *ScannerOption) fill() {
+	if o.Concurrency <= 0 {
+		o.Concurrency = defaultScanConcurrency
+	}
+
+	if o.Batch <= 0 {
+		o.Batch = defaultScanBatch
+	}
+
+	if o.Interval <= 0 {
+		o.Interval = defaultScanInterval
+	}
+
+	if o.ParseTimeout <= 0 {
+		o.ParseTimeout = defaultParseTimeout
+	}
+
+	if o.MaxRetries <= 0 {
+		o.MaxRetries = defaultMaxRetries
+	}
+
+	if o.RetryBackoff <= 0 {
+		o.RetryBackoff = o.Interval
+	}
+}
+
+// retryState 失败区块的重试状态,只保存在内存中,重启后重新计数
+type retryState struct {
+	attempts int       // 已经失败的次数
+	next     time.Time // 下次重试时间
+}
+
+// Scanner 持续扫描波场区块,保存进度并重试失败的区块
+type Scanner interface {
+	model.Module
+	// Start 读取进度并开始扫描
+	Start() error
+	// Progress 当前进度
+	Progress() Progress
+}
+
+type scanner struct {
+	parser     TronParser
+	grpcClient *client.GrpcClient
+	checkpoint Checkpoint
+	handler    ScanHandler
+	option     ScannerOption
+	logger     log.Logger
+	lock       *sync.RWMutex
+	height     int64              // 已经扫描的高度
+	failed     map[int64]struct{} // 待重试的区块
+	retries    map[int64]*retryState
+	shutdown   model.Shutdown
+	ctx        context.Context
+	cancel     context.CancelFunc
+	done       chan struct{}
+	started    bool
+	latest     func() (int64, error) // 获取最新区块高度
+	now        func() time.Time
+}
+
+/*
+NewScanner 新建扫描器
+参数:
+*	parser    	TronParser        	区块解析器
+*	grpcClient	*client.GrpcClient	波场grpc客户端,用于获取最新区块
+*	checkpoint	Checkpoint        	进度存储,见 NewMySQLCheckpoint 和 NewLocalCheckpoint
+*	handler   	ScanHandler       	解析成功后的回调,可以为nil
+*	option    	ScannerOption     	选项
+*	logger    	log.Logger        	日志器
+返回值:
+*	Scanner   	Scanner           	扫描器
+*	error     	error             	错误
+*/
+func NewScanner(parser TronParser, grpcClient *client.GrpcClient, checkpoint Checkpoint, handler ScanHandler, option ScannerOption, logger log.Logger) (Scanner, error) { //nolint:lll
+	if parser == nil || grpcClient == nil || checkpoint == nil {
+		return nil, errors.New(`解析器,grpc客户端和进度存储不能为空`)
+	}
+
+	if option.Key == `` {
+		return nil, errors.New(`扫描器标识不能为空`)
+	}
+
+	option.fill()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	result := &scanner{
+		parser:     parser,
+		grpcClient: grpcClient,
+		checkpoint: checkpoint,
+		handler:    handler,
+		option:     option,
+		logger:     logger.Derive(`扫描器`).With(zap.String(`标识`, option.Key)),
+		lock:       &sync.RWMutex{},
+		failed:     make(map[int64]struct{}),
+		retries:    make(map[int64]*retryState),
+		shutdown:   model.NewShutdown(),
+		ctx:        ctx,
+		cancel:     cancel,
+		done:       make(chan struct{}),
+		now:        time.Now,
+	}
+
+	result.latest = result.head
+
+	return result, nil
+}
+
+func (s *scanner) Start() error {
+	progress, err := s.checkpoint.Load(s.option.Key)
+	if err != nil {
+		return errors.Wrap(err, `读取进度`)
+	}
+
+	switch {
+	case progress != nil:
+		s.height = progress.Height
+
+		for _, blockNumber := range progress.Failed {
+			s.failed[blockNumber] = struct{}{}
+		}
+	case s.option.Start > 0:
+		s.height = s.option.Start - 1
+	default:
+		if s.height, err = s.latest(); err != nil {
+			return errors.Wrap(err, `获取最新区块`)
+		}
+	}
+
+	s.started = true
+
+	s.logger.Info(`开始扫描`, zap.Int64(`高度`, s.height), zap.Int(`待重试`, len(s.failed)))
+
+	helpers.EnsureGo(s.logger, func() {
+		defer close(s.done)
+
+		ticker := time.NewTicker(s.option.Interval)
+		defer ticker.Stop()
+
+		for {
+			s.round()
+
+			select {
+			case <-ticker.C:
+			case <-s.ctx.Done():
+				return
+			}
+		}
+	})
+
+	return nil
+}
+
+func (s *scanner) head() (int64, error) {
+	block, err := s.grpcClient.GetNowBlock()
+	if err != nil {
+		helpers.IgnoreError(s.logger, "重启波场grpc客户端", func() error {
+			return s.grpcClient.Reconnect(s.grpcClient.Address)
+		})
+
+		return 0, errors.Wrap(err, `GetNowBlock`)
+	}
+
+	return block.GetBlockHeader().GetRawData().GetNumber(), nil
+}
+
+/*
+round 一轮扫描,先重试失败的区块,再扫描新区块,最后保存进度
+参数:
+返回值:
+*/
+func (s *scanner) round() {
+	s.retry()
+
+	head, err := s.latest()
+	if err != nil {
+		s.logger.Warn(`获取最新区块失败`, zap.String(`错误`, err.Error()))
+
+		return
+	}
+
+	for s.height < head && s.ctx.Err() == nil {
+		from := s.height + 1
+		to := from + int64(s.option.Batch) - 1
+
+		if to > head {
+			to = head
+		}
+
+		blocks := make([]int64, 0, to-from+1)
+		for blockNumber := from; blockNumber <= to; blockNumber++ {
+			blocks = append(blocks, blockNumber)
+		}
+
+		failed := s.parseAll(blocks)
+
+		s.lock.Lock()
+		s.height = to
+
+		for _, blockNumber := range failed {
+			s.failLocked(blockNumber)
+		}
+		s.lock.Unlock()
+
+		s.save()
+	}
+}
+
+/*
+retry 重试到期的失败区块
+参数:
+返回值:
+*/
+func (s *scanner) retry() {
+	now := s.now()
+
+	s.lock.RLock()
+	blocks := make([]int64, 0, len(s.failed))
+
+	for blockNumber := range s.failed {
+		if state, exist := s.retries[blockNumber]; !exist || !now.Before(state.next) {
+			blocks = append(blocks, blockNumber)
+		}
+	}
+	s.lock.RUnlock()
+
+	if len(blocks) == 0 {
+		return
+	}
+
+	sort.Slice(blocks, func(i, j int) bool {
+		return blocks[i] < blocks[j]
+	})
+
+	failed := make(map[int64]struct{}, len(blocks))
+	for _, blockNumber := range s.parseAll(blocks) {
+		failed[blockNumber] = struct{}{}
+	}
+
+	s.lock.Lock()
+	for _, blockNumber := range blocks {
+		if _, exist := failed[blockNumber]; exist {
+			s.failLocked(blockNumber)
+		} else {
+			delete(s.failed, blockNumber)
+			delete(s.retries, blockNumber)
+		}
+	}
+	s.lock.Unlock()
+
+	s.save()
+}
+
+/*
+failLocked 记录区块失败,按照指数退避计算下次重试时间,超过最大次数后按最大间隔重试,
+区块一直保留在失败列表中,不会因为进度前进而丢失交易,调用方持有锁
+参数:
+*	blockNumber	int64	区块号
+返回值:
+*/
+func (s *scanner) failLocked(blockNumber int64) {
+	state, exist := s.retries[blockNumber]
+	if !exist {
+		state = &retryState{}
+		s.retries[blockNumber] = state
+	}
+
+	state.attempts++
+
+	backoff := maxRetryBackoff
+
+	if state.attempts > s.option.MaxRetries {
+		s.logger.Error(`区块重试次数超过上限,需要人工处理`, zap.Int64(`区块`, blockNumber), zap.Int(`次数`, state.attempts))
+	} else if backoff = s.option.RetryBackoff << (state.attempts - 1); backoff <= 0 || backoff > maxRetryBackoff {
+		backoff = maxRetryBackoff
+	}
+
+	state.next = s.now().Add(backoff)
+	s.failed[blockNumber] = struct{}{}
+}
+
+/*
+parseAll 并发解析区块
+参数:
+*	blocks	[]int64	区块号
+返回值:
+*	failed	[]int64	失败的区块号
+*/
+func (s *scanner) parseAll(blocks []int64) (failed []int64) {
+	var (
+		wg   = &sync.WaitGroup{}
+		lock = &sync.Mutex{}
+		sem  = make(chan struct{}, s.option.Concurrency)
+	)
+
+	for _, blockNumber := range blocks {
+		if s.ctx.Err() != nil {
+			lock.Lock()
+			failed = append(failed, blockNumber)
+			lock.Unlock()
+
+			continue
+		}
+
+		blockNumber := blockNumber
+		sem <- struct{}{}
+
+		wg.Add(1)
+
+		helpers.EnsureGo(s.logger, func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			if err := s.parseOne(blockNumber); err != nil {
+				s.logger.Warn(`解析区块失败,进入重试队列`, zap.Int64(`区块`, blockNumber), zap.String(`错误`, err.Error()))
+
+				lock.Lock()
+				failed = append(failed, blockNumber)
+				lock.Unlock()
+			}
+		})
+	}
+
+	wg.Wait()
+
+	return failed
+}
+
+func (s *scanner) parseOne(blockNumber int64) error {
+	ctx, cancel := context.WithTimeout(s.ctx, s.option.ParseTimeout)
+	defer cancel()
+
+	trades, err := s.parser.Parse(ctx, blockNumber)
+	if err != nil {
+		return errors.Wrap(err, `解析`)
+	}
+
+	if s.handler == nil {
+		return nil
+	}
+
+	return errors.Wrap(s.handler(ctx, blockNumber, trades), `处理`)
+}
+
+func (s *scanner) save() {
+	progress := s.Progress()
+
+	if err := s.checkpoint.Save(&progress); err != nil {
+		s.logger.Error(`保存进度失败`, zap.Int64(`高度`, progress.Height), zap.String(`错误`, err.Error()))
+	}
+}
+
+/*
+Progress 当前进度.解析器还有未确认的区块时,高度只到最小的未确认区块之前,
+这些区块的交易只在内存中等待确认,重启之后需要重新解析才能通知
+参数:
+返回值:
+*	Progress	Progress	进度
+*/
+func (s *scanner) Progress() Progress {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	height := s.height
+
+	if tracker, ok := s.parser.(unconfirmedTracker); ok {
+		if blockNumber, exist := tracker.LowestUnconfirmed(); exist && blockNumber <= height {
+			height = blockNumber - 1
+		}
+	}
+
+	failed := make([]int64, 0, len(s.failed))
+	for blockNumber := range s.failed {
+		failed = append(failed, blockNumber)
+	}
+
+	sort.Slice(failed, func(i, j int) bool {
+		return failed[i] < failed[j]
+	})
+
+	return *NewProgress(s.option.Key, height, failed)
+}
+
+/*
+Close 停止扫描,等待当前一轮结束,未完成的区块进入重试队列
+参数:
+返回值:
+*/
+func (s *scanner) Close() {
+	if s.shutdown.IsClosed() {
+		return
+	}
+
+	s.shutdown.Close()
+	s.cancel()
+
+	if !s.started {
+		return
+	}
+
+	<-s.done
+
+	s.save()
+}
+
+func (s *scanner) IsClosed() bool {
+	return s.shutdown.IsClosed()
+}
+
+func (s *scanner) Key() string {
+	return `tronScanner:` + s.option.Key
+}
+
+func (s *scanner) Name() string {
+	return `波场区块扫描器` + s.option.Key
+}
@@ -0,0 +1,160 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fighterlyt/common/cryptocurrency"
+	"github.com/stretchr/testify/require"
+)
+
+type mockParser struct {
+	lock   *sync.Mutex
+	fail   map[int64]int // 区块剩余的失败次数
+	parsed map[int64]int // 区块成功解析的次数
+}
+
+func (m mockParser) Parse(_ context.Context, blockNumber int64) ([]*cryptocurrency.Trade, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.fail[blockNumber] > 0 {
+		m.fail[blockNumber]--
+		return nil, errors.New(`mock`)
+	}
+
+	m.parsed[blockNumber]++
+
+	return nil, nil
+}
+
+func (m mockParser) IncludeTRX(_ bool) {}
+
+func (m mockParser) IncludeTRC10(_ bool) {}
+
+func (m mockParser) IncludeEvents(_ bool) {}
+
+func (m mockParser) WatchTRC721(_ ...string) {}
+
+func (m mockParser) SetConfirmation(_ int64, _ cryptocurrency.TradeRevertNotify) {}
+
+type memoryCheckpoint map[string]Progress
+
+func (m memoryCheckpoint) Load(key string) (*Progress, error) {
+	if progress, ok := m[key]; ok {
+		return &progress, nil
+	}
+
+	return nil, nil
+}
+
+func (m memoryCheckpoint) Save(progress *Progress) error {
+	m[progress.Key] = *progress
+	return nil
+}
+
+func TestScanner_round(t *testing.T) {
+	parser := mockParser{lock: &sync.Mutex{}, fail: map[int64]int{3: 1}, parsed: map[int64]int{}}
+	checkpoint := memoryCheckpoint{}
+
+	target, err := NewScanner(parser, nil, checkpoint, nil, ScannerOption{Key: `test`, Start: 1, Batch: 2}, logger)
+	require.Error(t, err, `grpc客户端不能为空`)
+	require.Nil(t, target)
+
+	s := newTestScanner(parser, checkpoint)
+
+	head := int64(5)
+	s.latest = func() (int64, error) { return head, nil }
+
+	s.round()
+	require.EqualValues(t, 5, checkpoint[`test`].Height, `扫描到最新区块`)
+	require.Equal(t, []int64{3}, checkpoint[`test`].Failed, `失败区块进入重试队列`)
+
+	head = 6
+	s.round()
+	require.Equal(t, []int64{3}, checkpoint[`test`].Failed, `未到重试时间`)
+
+	s.now = func() time.Time { return time.Now().Add(s.option.RetryBackoff) }
+	s.round()
+	require.EqualValues(t, 6, checkpoint[`test`].Height)
+	require.Empty(t, checkpoint[`test`].Failed, `重试成功`)
+
+	for blockNumber := int64(1); blockNumber <= 6; blockNumber++ {
+		require.Equal(t, 1, parser.parsed[blockNumber], `每个区块只成功解析一次`)
+	}
+}
+
+type unconfirmedParser struct {
+	mockParser
+	lowest int64
+}
+
+func (u *unconfirmedParser) LowestUnconfirmed() (int64, bool) {
+	return u.lowest, u.lowest != 0
+}
+
+func TestScanner_unconfirmed(t *testing.T) {
+	parser := &unconfirmedParser{mockParser: mockParser{lock: &sync.Mutex{}, fail: map[int64]int{}, parsed: map[int64]int{}}}
+	checkpoint := memoryCheckpoint{}
+
+	s := newTestScanner(parser, checkpoint)
+	s.latest = func() (int64, error) { return 5, nil }
+
+	parser.lowest = 4
+	s.round()
+	require.EqualValues(t, 3, checkpoint[`test`].Height, `进度不超过未确认区块`)
+
+	parser.lowest = 0
+	s.save()
+	require.EqualValues(t, 5, checkpoint[`test`].Height, `全部确认后保存实际高度`)
+}
+
+func TestScanner_retryLimit(t *testing.T) {
+	parser := mockParser{lock: &sync.Mutex{}, fail: map[int64]int{1: 100}, parsed: map[int64]int{}}
+	checkpoint := memoryCheckpoint{}
+
+	s := newTestScanner(parser, checkpoint)
+	s.option.MaxRetries = 2
+	s.latest = func() (int64, error) { return 1, nil }
+
+	clock := time.Now()
+	s.now = func() time.Time { return clock }
+
+	s.round()
+	require.Equal(t, []int64{1}, checkpoint[`test`].Failed)
+	require.Equal(t, clock.Add(s.option.RetryBackoff), s.retries[1].next)
+
+	clock = clock.Add(s.option.RetryBackoff)
+	s.round()
+	require.Equal(t, clock.Add(2*s.option.RetryBackoff), s.retries[1].next, `间隔翻倍`)
+
+	clock = clock.Add(2 * s.option.RetryBackoff)
+	s.round()
+	require.Equal(t, []int64{1}, checkpoint[`test`].Failed, `超过重试次数后不放弃`)
+	require.Equal(t, clock.Add(maxRetryBackoff), s.retries[1].next, `按最大间隔重试`)
+	require.Equal(t, 97, parser.fail[1], `初次解析加两次重试`)
+}
+
+func newTestScanner(parser TronParser, checkpoint Checkpoint) *scanner {
+	option := ScannerOption{Key: `test`, Batch: 2}
+	option.fill()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &scanner{
+		parser:     parser,
+		checkpoint: checkpoint,
+		option:     option,
+		logger:     logger,
+		lock:       &sync.RWMutex{},
+		failed:     make(map[int64]struct{}),
+		retries:    make(map[int64]*retryState),
+		ctx:        ctx,
+		cancel:     cancel,
+		done:       make(chan struct{}),
+		now:        time.Now,
+	}
+}
@@ -0,0 +1,118 @@
+package parser
+
+import (
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/fighterlyt/common/cryptocurrency"
+	"github.com/fighterlyt/gotron-sdk/pkg/common"
+	"github.com/fighterlyt/gotron-sdk/pkg/proto/api"
+	"github.com/fighterlyt/gotron-sdk/pkg/proto/core"
+	"github.com/fighterlyt/log"
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+// assetPrecisions TRC10代币精度缓存,代币精度发行后不会变化
+type assetPrecisions struct {
+	lock *sync.RWMutex
+	data map[string]int32
+}
+
+func newAssetPrecisions() *assetPrecisions {
+	return &assetPrecisions{
+		lock: &sync.RWMutex{},
+		data: make(map[string]int32),
+	}
+}
+
+func (g *grpcParser) IncludeTRC10(include bool) {
+	g.includeTRC10 = include
+}
+
+/*
+assetPrecision 获取TRC10代币精度
+参数:
+*	assetID  	string	代币ID
+返回值:
+*	precision	int32 	精度
+*	err      	error 	错误
+*/
+func (g grpcParser) assetPrecision(assetID string) (precision int32, err error) {
+	g.assets.lock.RLock()
+	precision, exist := g.assets.data[assetID]
+	g.assets.lock.RUnlock()
+
+	if exist {
+		return precision, nil
+	}
+
+	asset, err := g.grpcClient.GetAssetIssueByID(assetID)
+	if err != nil {
+		return 0, errors.Wrapf(err, `GetAssetIssueByID[%s]`, assetID)
+	}
+
+	g.assets.lock.Lock()
+	g.assets.data[assetID] = asset.GetPrecision()
+	g.assets.lock.Unlock()
+
+	return asset.GetPrecision(), nil
+}
+
+func (g grpcParser) parseTRC10(contract *core.Transaction_Contract, tx *api.TransactionExtention, logger log.Logger, blockNumber int64, txID string) (trade *cryptocurrency.Trade, detail *cryptocurrency.TransactionDetail, err error) { //nolint:lll
+	var (
+		matched   bool
+		precision int32
+		info      *core.TransactionInfo
+	)
+
+	transaction := &core.TransferAssetContract{}
+
+	if err = proto.Unmarshal(contract.Parameter.GetValue(), transaction); err != nil {
+		return nil, nil, errors.Wrap(err, `解析value`)
+	}
+
+	ret := tx.Transaction.GetRet()
+
+	if ret == nil || !tx.GetResult().Result || core.Transaction_ResultContractResult_name[int32(ret[0].ContractRet)] != success { // nolint:golint,lll
+		return nil, nil, nil
+	}
+
+	if g.concern == nil {
+		return nil, nil, nil
+	}
+
+	assetID := string(transaction.AssetName)
+
+	if precision, err = g.assetPrecision(assetID); err != nil {
+		return nil, nil, errors.Wrap(err, `获取代币精度`)
+	}
+
+	amount := decimal.New(transaction.Amount, -precision)
+	ownerAddress := common.EncodeCheck(transaction.OwnerAddress)
+	toAddress := common.EncodeCheck(transaction.ToAddress)
+
+	if matched, _, err = g.concern.FilterConcernedAccounts(ownerAddress, toAddress, amount); err != nil {
+		return nil, nil, errors.Wrapf(err, `判断关注交易错误,转出[%s]转入[%s],金额[%s]`, ownerAddress, toAddress, amount.String())
+	}
+
+	if !matched {
+		logger.Info(`不匹配`, zap.Strings(`ownAddress/toAddress/amount`, []string{ownerAddress, toAddress, amount.String()}))
+		return nil, nil, nil
+	}
+
+	if info, err = g.grpcClient.GetTransactionInfoByID(hexutil.Encode(tx.Txid)); err != nil {
+		return nil, nil, errors.Wrap(err, "GetTransactionInfoByID")
+	}
+
+	fee := decimal.New(info.GetFee(), -6)
+	tradeTime := info.BlockTimeStamp / int64(kilo)
+
+	trade = cryptocurrency.NewTrade(cryptocurrency.Trc10, ownerAddress, toAddress, amount, assetID, txID, tradeTime, blockNumber, fee, cryptocurrency.TradeTransfer) // nolint:golint,lll
+
+	detail = cryptocurrency.NewFullTransactionDetail(amount, cryptocurrency.Trc10, assetID, ownerAddress, toAddress, blockNumber, txID, fee, tradeTime, cryptocurrency.TradeTransfer) //nolint:lll
+
+	return trade, detail, nil
+}
@@ -0,0 +1,30 @@
+package sweep
+
+import (
+	"os"
+	"testing"
+
+	"github.com/fighterlyt/log"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+var (
+	logger log.Logger
+	err    error
+	db     *gorm.DB
+)
+
+func TestMain(m *testing.M) {
+	if logger, err = log.NewEasyLogger(true, false, ``, `归集`); err != nil {
+		panic(`构建日志器` + err.Error())
+	}
+
+	dsn := "root:dubaihell@tcp(127.0.0.1:3306)/first?charset=utf8mb4&parseTime=True&loc=Local"
+
+	if db, err = gorm.Open(mysql.Open(dsn), &gorm.Config{}); err != nil {
+		panic(`连接mysql错误` + err.Error())
+	}
+
+	os.Exit(m.Run())
+}
@@ -0,0 +1,416 @@
+package sweep
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fighterlyt/common/cryptocurrency"
+	"github.com/fighterlyt/common/cryptocurrency/tron/free"
+	"github.com/fighterlyt/common/helpers"
+	"github.com/fighterlyt/common/model"
+	"github.com/fighterlyt/common/tronbalance"
+	"github.com/fighterlyt/gotron-sdk/pkg/proto/core"
+	"github.com/fighterlyt/log"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultBatch    = 100             // 默认每批处理的钱包数量
+	defaultExpire   = time.Minute * 2 // 交易过期时间,波场交易默认60秒过期,留出余量
+	defaultBackoff  = time.Minute     // 默认失败后的首次重试间隔
+	maxRetryBackoff = time.Hour       // 失败后的最大重试间隔
+)
+
+var (
+	modelWallet = &cryptocurrency.UserWallet{}
+	modelTrade  = &cryptocurrency.Trade{}
+	errSkipped  = errors.New(`钱包状态已变更`)
+)
+
+// Option 归集选项
+type Option struct {
+	Collect   string          // 归集地址
+	Token     string          // 归集的代币,model.TRX 或者TRC20代币名称
+	Threshold decimal.Decimal // 余额大于阈值才归集
+	Reserve   decimal.Decimal // 归集TRX时保留的金额,用于支付带宽
+	Batch     int             // 每批处理的钱包数量
+	Expire    time.Duration   // 交易创建后多久未上链视为过期,可以重新归集
+	Backoff   time.Duration   // 失败后的首次重试间隔,之后每次失败翻倍,最大 maxRetryBackoff
+}
+
+func (o *Option) fill() {
+	if o.Batch <= 0 {
+		o.Batch = defaultBatch
+	}
+
+	if o.Expire <= 0 {
+		o.Expire = defaultExpire
+	}
+
+	if o.Backoff <= 0 {
+		o.Backoff = defaultBackoff
+	}
+}
+
+// Result 一轮归集的结果
+type Result struct {
+	Checked   int             // 检查的钱包数量
+	Sent      int             // 发出的归集交易数量
+	Confirmed int             // 确认成功的归集交易数量
+	Failed    int             // 失败的归集交易数量
+	Amount    decimal.Decimal // 本轮发出的金额
+}
+
+// Service 归集服务
+type Service interface {
+	// Run 执行一轮归集,先处理上次未完成的交易,再归集余额超过阈值的钱包
+	Run() (result *Result, err error)
+	// Resume 处理已发出但未确认的归集交易,用于进程重启后继续
+	Resume() (result *Result, err error)
+}
+
+type service struct {
+	db         *gorm.DB
+	logger     log.Logger
+	reader     tronbalance.BalanceReader
+	transferor Transferor
+	freezer    free.Service
+	option     Option
+	now        func() time.Time
+}
+
+/*
+NewService 新建归集服务
+参数:
+*	db        	*gorm.DB                 	数据库,包含 user_wallet 和 tron_trades
+*	logger    	log.Logger               	日志器
+*	reader    	tronbalance.BalanceReader	余额查询
+*	transferor	Transferor               	转账,见 NewGrpcTransferor
+*	freezer   	free.Service             	能量质押,归集TRC20前补足能量,为空不质押
+*	option    	Option                   	选项
+返回值:
+*	Service   	Service                  	服务
+*	err       	error                    	错误
+*/
+func NewService(db *gorm.DB, logger log.Logger, reader tronbalance.BalanceReader, transferor Transferor, freezer free.Service, option Option) (Service, error) { //nolint:lll
+	option.fill()
+
+	if option.Collect == `` {
+		return nil, errors.New(`归集地址不能为空`)
+	}
+
+	if option.Token == `` {
+		return nil, errors.New(`归集代币不能为空`)
+	}
+
+	target := &service{
+		db:         db,
+		logger:     logger.Derive(`归集`),
+		reader:     reader,
+		transferor: transferor,
+		freezer:    freezer,
+		option:     option,
+		now:        time.Now,
+	}
+
+	if err := db.AutoMigrate(modelWallet, modelTrade); err != nil {
+		return nil, errors.Wrap(err, `建表`)
+	}
+
+	return target, nil
+}
+
+func (s service) Run() (result *Result, err error) {
+	// 未完成的归集失败不影响其他钱包,这些钱包处于发出中,不会重复归集
+	if result, err = s.Resume(); err != nil {
+		err = errors.Wrap(err, `处理未完成的归集`)
+	}
+
+	var (
+		wallets []cryptocurrency.UserWallet
+		lastID  int64
+	)
+
+	for {
+		wallets = wallets[:0]
+
+		if queryErr := s.db.Model(modelWallet).
+			Where(`protocol = ? and id > ?`, cryptocurrency.Trc20, lastID).
+			Where(`sweep_status = ? or (sweep_status = ? and sweep_next_time <= ?)`, cryptocurrency.SweepIdle, cryptocurrency.SweepFailed, s.now().Unix()).
+			Order(`id`).Limit(s.option.Batch).Find(&wallets).Error; queryErr != nil {
+			return result, multierr.Append(err, errors.Wrap(queryErr, `查询钱包`))
+		}
+
+		for i := range wallets {
+			result.Checked++
+
+			amount, sweepErr := s.sweep(&wallets[i])
+			if sweepErr != nil {
+				err = multierr.Append(err, errors.Wrapf(sweepErr, `归集[%s]`, wallets[i].Address))
+				continue
+			}
+
+			if amount.IsPositive() {
+				result.Sent++
+				result.Amount = result.Amount.Add(amount)
+			}
+		}
+
+		if len(wallets) < s.option.Batch {
+			return result, err
+		}
+
+		lastID = wallets[len(wallets)-1].ID
+	}
+}
+
+/*
+sweep 归集单个钱包,先记录交易ID再广播,广播失败的交易由 Resume 根据链上结果处理
+参数:
+*	wallet	*cryptocurrency.UserWallet	钱包
+返回值:
+*	amount	decimal.Decimal           	归集金额,不需要归集时为0
+*	err   	error                     	错误
+*/
+func (s service) sweep(wallet *cryptocurrency.UserWallet) (amount decimal.Decimal, err error) {
+	if wallet.Address == s.option.Collect {
+		return decimal.Zero, nil
+	}
+
+	if amount, err = s.reader.Balance(wallet.Address, s.option.Token); err != nil {
+		return decimal.Zero, errors.Wrap(err, `查询余额`)
+	}
+
+	if !amount.GreaterThan(s.option.Threshold) {
+		return decimal.Zero, nil
+	}
+
+	if s.option.Token == model.TRX {
+		if amount = amount.Sub(s.option.Reserve); !amount.IsPositive() {
+			return decimal.Zero, nil
+		}
+	}
+
+	now := s.now()
+
+	// 先占用钱包再质押能量,避免多个进程重复为同一个钱包质押
+	if err = s.claim(wallet, now); err != nil {
+		if errors.Is(err, errSkipped) {
+			return decimal.Zero, nil
+		}
+
+		return decimal.Zero, err
+	}
+
+	tx, txID, err := s.build(wallet, amount)
+	if err != nil {
+		return decimal.Zero, multierr.Append(err, s.release(wallet, err.Error()))
+	}
+
+	trade := cryptocurrency.NewTrade(cryptocurrency.Trc20, wallet.Address, s.option.Collect, amount, s.option.Token, txID, now.UnixMilli(), 0, decimal.Zero, cryptocurrency.TradeCollect) //nolint:lll
+
+	if err = s.db.Transaction(func(tx *gorm.DB) error {
+		updated := tx.Model(modelWallet).Where(`id = ? and sweep_status = ? and sweep_tx_id = ?`, wallet.ID, cryptocurrency.SweepSending, ``).
+			Update(`sweep_tx_id`, txID)
+
+		if updated.Error != nil {
+			return errors.Wrap(updated.Error, `更新钱包状态`)
+		}
+
+		if updated.RowsAffected == 0 {
+			return errSkipped
+		}
+
+		return errors.Wrap(tx.Model(modelTrade).Create(trade).Error, `保存交易`)
+	}); err != nil {
+		if errors.Is(err, errSkipped) {
+			return decimal.Zero, nil
+		}
+
+		return decimal.Zero, err
+	}
+
+	s.logger.Info(`发出归集交易`, zap.String(`地址`, wallet.Address), zap.String(`交易`, txID), zap.String(`金额`, amount.String()))
+
+	if err = s.transferor.Broadcast(tx); err != nil {
+		// 广播超时交易仍可能上链,由 Resume 根据链上结果处理
+		s.logger.Warn(`广播失败,等待确认`, zap.String(`交易`, txID), helpers.ZapError(err))
+	}
+
+	return amount, nil
+}
+
+/*
+claim 占用钱包,只有状态没有被其他进程修改时才成功,占用期间交易ID为空
+参数:
+*	wallet	*cryptocurrency.UserWallet	钱包
+*	now   	time.Time                 	当前时间
+返回值:
+*	error 	error                     	错误,已被其他进程占用时为 errSkipped
+*/
+func (s service) claim(wallet *cryptocurrency.UserWallet, now time.Time) error {
+	updated := s.db.Model(modelWallet).Where(`id = ? and sweep_status = ?`, wallet.ID, wallet.SweepStatus).Updates(map[string]interface{}{
+		`sweep_status`: cryptocurrency.SweepSending,
+		`sweep_tx_id`:  ``,
+		`sweep_time`:   now.Unix(),
+		`sweep_reason`: ``,
+	})
+
+	if updated.Error != nil {
+		return errors.Wrap(updated.Error, `更新钱包状态`)
+	}
+
+	if updated.RowsAffected == 0 {
+		return errSkipped
+	}
+
+	return nil
+}
+
+// build 补充能量后创建交易
+func (s service) build(wallet *cryptocurrency.UserWallet, amount decimal.Decimal) (tx *core.Transaction, txID string, err error) {
+	if s.option.Token != model.TRX && s.freezer != nil {
+		if _, err = s.freezer.FreezeForTRC20Transfer(wallet.Address, s.option.Collect, amount); err != nil {
+			return nil, ``, errors.Wrap(err, `补充能量`)
+		}
+	}
+
+	return s.transferor.Build(wallet.Address, wallet.PrivateKey, s.option.Collect, s.option.Token, amount)
+}
+
+// release 交易未创建,释放占用的钱包,视为失败,退避后重试
+func (s service) release(wallet *cryptocurrency.UserWallet, reason string) error {
+	return errors.Wrap(s.db.Model(modelWallet).
+		Where(`id = ? and sweep_status = ? and sweep_tx_id = ?`, wallet.ID, cryptocurrency.SweepSending, ``).
+		Updates(s.failed(wallet, reason)).Error, `释放钱包`)
+}
+
+/*
+failed 失败后的钱包状态,连续失败次数加1,重试间隔每次翻倍
+参数:
+*	wallet 	*cryptocurrency.UserWallet	钱包
+*	reason 	string                    	失败原因
+返回值:
+*	updates	map[string]interface{}    	需要更新的字段
+*/
+func (s service) failed(wallet *cryptocurrency.UserWallet, reason string) map[string]interface{} {
+	backoff := s.option.Backoff
+
+	for i := 0; i < wallet.SweepRetries && backoff < maxRetryBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > maxRetryBackoff {
+		backoff = maxRetryBackoff
+	}
+
+	return map[string]interface{}{
+		`sweep_status`:    cryptocurrency.SweepFailed,
+		`sweep_reason`:    reason,
+		`sweep_retries`:   wallet.SweepRetries + 1,
+		`sweep_next_time`: s.now().Add(backoff).Unix(),
+	}
+}
+
+/*
+Resume 查询发出中的归集交易的链上结果,成功后钱包恢复空闲,失败或者过期的可以重新归集
+参数:
+返回值:
+*	result	*Result	结果
+*	err   	error  	错误
+*/
+func (s service) Resume() (result *Result, err error) {
+	result = &Result{Amount: decimal.Zero}
+
+	var (
+		wallets []cryptocurrency.UserWallet
+	)
+
+	if err = s.db.Model(modelWallet).Where(`protocol = ? and sweep_status = ?`, cryptocurrency.Trc20, cryptocurrency.SweepSending).
+		Find(&wallets).Error; err != nil {
+		return result, errors.Wrap(err, `查询发出中的归集`)
+	}
+
+	for i := range wallets {
+		status, checkErr := s.confirm(&wallets[i])
+		if checkErr != nil {
+			err = multierr.Append(err, errors.Wrapf(checkErr, `确认[%s]`, wallets[i].SweepTxID))
+			continue
+		}
+
+		switch status {
+		case TxSuccess:
+			result.Confirmed++
+		case TxFailed:
+			result.Failed++
+		}
+	}
+
+	return result, err
+}
+
+func (s service) confirm(wallet *cryptocurrency.UserWallet) (status TxStatus, err error) {
+	txResult := &TxResult{Status: TxNotFound}
+
+	// 交易ID为空说明占用后进程退出,交易没有创建,过期后释放
+	if wallet.SweepTxID != `` {
+		if txResult, err = s.transferor.Result(wallet.SweepTxID); err != nil {
+			return TxNotFound, err
+		}
+	}
+
+	switch txResult.Status {
+	case TxSuccess:
+		return TxSuccess, s.db.Transaction(func(tx *gorm.DB) error {
+			if err = tx.Model(modelTrade).Where(`ID = ?`, wallet.SweepTxID).Updates(map[string]interface{}{
+				`BLOCK_NUM`: txResult.BlockNumber,
+				`FEE`:       txResult.Fee,
+			}).Error; err != nil {
+				return errors.Wrap(err, `更新交易`)
+			}
+
+			return s.finish(tx, wallet, map[string]interface{}{
+				`sweep_status`:    cryptocurrency.SweepIdle,
+				`sweep_reason`:    ``,
+				`sweep_retries`:   0,
+				`sweep_next_time`: 0,
+			})
+		})
+	case TxFailed:
+		s.logger.Warn(`归集交易失败`, zap.String(`地址`, wallet.Address), zap.String(`交易`, wallet.SweepTxID), zap.String(`原因`, txResult.Reason))
+
+		return TxFailed, s.discard(wallet, s.failed(wallet, txResult.Reason))
+	default:
+		if s.now().Before(time.Unix(wallet.SweepTime, 0).Add(s.option.Expire)) {
+			return TxNotFound, nil
+		}
+
+		s.logger.Warn(`归集交易过期`, zap.String(`地址`, wallet.Address), zap.String(`交易`, wallet.SweepTxID))
+
+		return TxNotFound, s.discard(wallet, map[string]interface{}{
+			`sweep_status`: cryptocurrency.SweepIdle,
+			`sweep_reason`: fmt.Sprintf(`交易[%s]过期`, wallet.SweepTxID),
+		})
+	}
+}
+
+// discard 交易未成功,删除交易记录,钱包可以重新归集
+func (s service) discard(wallet *cryptocurrency.UserWallet, updates map[string]interface{}) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where(`ID = ?`, wallet.SweepTxID).Delete(modelTrade).Error; err != nil {
+			return errors.Wrap(err, `删除交易`)
+		}
+
+		return s.finish(tx, wallet, updates)
+	})
+}
+
+func (s service) finish(tx *gorm.DB, wallet *cryptocurrency.UserWallet, updates map[string]interface{}) error {
+	return errors.Wrap(tx.Model(modelWallet).
+		Where(`id = ? and sweep_status = ? and sweep_tx_id = ?`, wallet.ID, cryptocurrency.SweepSending, wallet.SweepTxID).
+		Updates(updates).Error, `更新钱包状态`)
+}
@@ -0,0 +1,138 @@
+package sweep
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/fighterlyt/common/cryptocurrency"
+	"github.com/fighterlyt/gotron-sdk/pkg/proto/core"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+type mockReader map[string]decimal.Decimal
+
+func (m mockReader) Balance(address, _ string) (decimal.Decimal, error) {
+	return m[address], nil
+}
+
+type mockTransferor struct {
+	built     int
+	broadcast int
+	buildErr  error
+	results   map[string]*TxResult
+}
+
+func (m *mockTransferor) Build(from, _, _, _ string, _ decimal.Decimal) (tx *core.Transaction, txID string, err error) {
+	if m.buildErr != nil {
+		return nil, ``, m.buildErr
+	}
+
+	m.built++
+
+	return &core.Transaction{}, fmt.Sprintf(`%s_%d`, from, m.built), nil
+}
+
+func (m *mockTransferor) Broadcast(_ *core.Transaction) error {
+	m.broadcast++
+
+	return errors.New(`超时`)
+}
+
+func (m *mockTransferor) Result(txID string) (result *TxResult, err error) {
+	if result = m.results[txID]; result == nil {
+		return &TxResult{Status: TxNotFound}, nil
+	}
+
+	return result, nil
+}
+
+func TestService_Run(t *testing.T) {
+	require.NoError(t, db.Migrator().DropTable(modelWallet, modelTrade))
+
+	reader := mockReader{`a`: decimal.New(100, 0), `b`: decimal.New(1, 0), `c`: decimal.New(50, 0)}
+	transferor := &mockTransferor{results: map[string]*TxResult{}}
+
+	target, err := NewService(db, logger, reader, transferor, nil, Option{Collect: `collect`, Token: cryptocurrency.USDT, Threshold: decimal.New(10, 0), Batch: 2})
+	require.NoError(t, err)
+
+	for i, address := range []string{`a`, `b`, `c`} {
+		require.NoError(t, db.Create(&cryptocurrency.UserWallet{UserID: int64(i + 1), Protocol: cryptocurrency.Trc20, Address: address}).Error)
+	}
+
+	result, err := target.Run()
+	require.NoError(t, err, `广播失败等待确认,不返回错误`)
+	require.Equal(t, 3, result.Checked)
+	require.Equal(t, 2, result.Sent, `b 低于阈值`)
+	require.True(t, result.Amount.Equal(decimal.New(150, 0)))
+
+	result, err = target.Run()
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Checked, `发出中的钱包不重复归集`)
+	require.Equal(t, 2, transferor.built)
+
+	transferor.results[`a_1`] = &TxResult{Status: TxSuccess, BlockNumber: 10, Fee: decimal.New(1, 0)}
+	target.(*service).now = func() time.Time {
+		return time.Now().Add(time.Hour)
+	}
+
+	result, err = target.Resume()
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Confirmed)
+
+	wallets := []cryptocurrency.UserWallet{}
+	require.NoError(t, db.Order(`id`).Find(&wallets).Error)
+	require.Equal(t, cryptocurrency.SweepIdle, wallets[0].SweepStatus)
+	require.Equal(t, cryptocurrency.SweepIdle, wallets[2].SweepStatus, `过期后可以重新归集`)
+
+	trades := []cryptocurrency.Trade{}
+	require.NoError(t, db.Find(&trades).Error)
+	require.Len(t, trades, 1, `过期的交易记录删除`)
+	require.EqualValues(t, 10, trades[0].BlockNum)
+	require.Equal(t, cryptocurrency.TradeCollect, trades[0].TradeKind)
+}
+
+func TestService_buildFailed(t *testing.T) {
+	require.NoError(t, db.Migrator().DropTable(modelWallet, modelTrade))
+
+	transferor := &mockTransferor{buildErr: errors.New(`余额不足`), results: map[string]*TxResult{}}
+
+	target, err := NewService(db, logger, mockReader{`a`: decimal.New(100, 0)}, transferor, nil, Option{Collect: `collect`, Token: cryptocurrency.USDT})
+	require.NoError(t, err)
+
+	require.NoError(t, db.Create(&cryptocurrency.UserWallet{UserID: 1, Protocol: cryptocurrency.Trc20, Address: `a`, SweepStatus: cryptocurrency.SweepFailed, SweepRetries: 1}).Error) //nolint:lll
+
+	_, err = target.Run()
+	require.Error(t, err, `创建交易失败`)
+
+	wallet := cryptocurrency.UserWallet{}
+	require.NoError(t, db.First(&wallet).Error)
+	require.Equal(t, cryptocurrency.SweepFailed, wallet.SweepStatus, `释放钱包`)
+	require.Empty(t, wallet.SweepTxID)
+	require.Equal(t, `余额不足`, wallet.SweepReason)
+	require.Equal(t, 2, wallet.SweepRetries)
+
+	result, err := target.Run()
+	require.NoError(t, err)
+	require.Zero(t, result.Checked, `退避期间不重试`)
+
+	now := time.Now()
+	target.(*service).now = func() time.Time {
+		return now.Add(time.Minute * 2)
+	}
+
+	transferor.buildErr = nil
+	result, err = target.Run()
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Sent, `退避后重试`)
+
+	transferor.results[`a_1`] = &TxResult{Status: TxSuccess}
+	_, err = target.Resume()
+	require.NoError(t, err)
+
+	require.NoError(t, db.First(&wallet).Error)
+	require.Equal(t, cryptocurrency.SweepIdle, wallet.SweepStatus)
+	require.Zero(t, wallet.SweepRetries, `成功后重置失败次数`)
+}
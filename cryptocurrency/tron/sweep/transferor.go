@@ -0,0 +1,173 @@
+package sweep
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/fighterlyt/common/cryptocurrency"
+	"github.com/fighterlyt/common/model"
+	"github.com/fighterlyt/gotron-sdk/pkg/client"
+	"github.com/fighterlyt/gotron-sdk/pkg/proto/api"
+	"github.com/fighterlyt/gotron-sdk/pkg/proto/core"
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	defaultFeeLimit = 30 * 1000000 // TRC20转账的手续费上限,单位SUN
+)
+
+// TxStatus 交易链上状态
+type TxStatus int
+
+const (
+	// TxNotFound 链上没有该交易,可能还未打包,也可能已经过期
+	TxNotFound TxStatus = iota
+	// TxSuccess 成功
+	TxSuccess
+	// TxFailed 已上链但执行失败
+	TxFailed
+)
+
+// TxResult 交易链上结果
+type TxResult struct {
+	Status      TxStatus
+	BlockNumber int64
+	Fee         decimal.Decimal // 手续费,TRX
+	Reason      string          // 失败原因
+}
+
+// Transferor 转账,创建和广播分开,方便在广播前记录交易ID
+type Transferor interface {
+	// Build 创建并签名交易,token 为 model.TRX 或者TRC20代币名称
+	Build(from, privateKey, to, token string, amount decimal.Decimal) (tx *core.Transaction, txID string, err error)
+	// Broadcast 广播交易
+	Broadcast(tx *core.Transaction) error
+	// Result 查询交易结果
+	Result(txID string) (result *TxResult, err error)
+}
+
+type grpcTransferor struct {
+	tronClient *client.GrpcClient
+	feeLimit   int64
+}
+
+/*
+NewGrpcTransferor 基于grpc的转账
+参数:
+*	tronClient	*client.GrpcClient	tron客户端
+*	feeLimit  	int64             	TRC20转账的手续费上限,单位SUN,为0使用默认值
+返回值:
+*	Transferor	Transferor        	转账
+*/
+func NewGrpcTransferor(tronClient *client.GrpcClient, feeLimit int64) Transferor {
+	if feeLimit <= 0 {
+		feeLimit = defaultFeeLimit
+	}
+
+	return &grpcTransferor{
+		tronClient: tronClient,
+		feeLimit:   feeLimit,
+	}
+}
+
+func (g grpcTransferor) Build(from, privateKey, to, token string, amount decimal.Decimal) (tx *core.Transaction, txID string, err error) {
+	var (
+		extension *api.TransactionExtention
+	)
+
+	if token == model.TRX {
+		// 这个接口的数值单位是sun， 1 TRX = 1,000,000 SUN
+		extension, err = g.tronClient.Transfer(from, to, amount.Shift(6).IntPart())
+	} else {
+		contract, contractErr := cryptocurrency.Trc20.ContractLocator().GetContract(token)
+		if contractErr != nil {
+			return nil, ``, errors.Wrapf(contractErr, `获取合约[%s]`, token)
+		}
+
+		extension, err = g.tronClient.TRC20Send(from, to, contract.Address(), amount.Shift(contract.Precision()).BigInt(), g.feeLimit)
+	}
+
+	if err != nil {
+		return nil, ``, errors.Wrap(err, `构建交易失败`)
+	}
+
+	// 节点拒绝构建时返回空交易,例如余额不足、地址未激活
+	if result := extension.GetResult(); !result.GetResult() {
+		return nil, ``, fmt.Errorf(`构建交易失败[%s][%s]`, result.GetCode().String(), string(result.GetMessage()))
+	}
+
+	if txID, err = sign(extension.GetTransaction(), privateKey); err != nil {
+		return nil, ``, err
+	}
+
+	return extension.GetTransaction(), txID, nil
+}
+
+/*
+sign 签名,交易ID为原始数据的sha256,签名不影响交易ID
+参数:
+*	tx        	*core.Transaction	交易
+*	privateKey	string           	私钥
+返回值:
+*	txID      	string           	交易ID
+*	err       	error            	错误
+*/
+func sign(tx *core.Transaction, privateKey string) (txID string, err error) {
+	raw, err := proto.Marshal(tx.GetRawData())
+	if err != nil {
+		return ``, errors.Wrap(err, `序列化交易`)
+	}
+
+	hash := sha256.Sum256(raw)
+
+	key, err := crypto.HexToECDSA(privateKey)
+	if err != nil {
+		return ``, errors.Wrap(err, "解析私钥错误")
+	}
+
+	signature, err := crypto.Sign(hash[:], key)
+	if err != nil {
+		return ``, errors.Wrap(err, `签名`)
+	}
+
+	tx.Signature = append(tx.Signature, signature)
+
+	return hex.EncodeToString(hash[:]), nil
+}
+
+func (g grpcTransferor) Broadcast(tx *core.Transaction) error {
+	if _, err := g.tronClient.Broadcast(tx); err != nil {
+		return errors.Wrap(err, `广播`)
+	}
+
+	return nil
+}
+
+func (g grpcTransferor) Result(txID string) (result *TxResult, err error) {
+	info, err := g.tronClient.GetTransactionInfoByID(txID)
+	if err != nil {
+		if strings.Contains(err.Error(), `not found`) {
+			return &TxResult{Status: TxNotFound}, nil
+		}
+
+		return nil, errors.Wrap(err, `GetTransactionInfoByID`)
+	}
+
+	result = &TxResult{
+		Status:      TxSuccess,
+		BlockNumber: info.GetBlockNumber(),
+		Fee:         decimal.New(info.GetFee(), -6),
+	}
+
+	if info.GetResult() != core.TransactionInfo_SUCESS || info.GetReceipt().GetResult() > core.Transaction_Result_SUCCESS {
+		result.Status = TxFailed
+		result.Reason = fmt.Sprintf(`%s:%s`, info.GetReceipt().GetResult().String(), string(info.GetResMessage()))
+	}
+
+	return result, nil
+}
@@ -0,0 +1,111 @@
+package withdraw
+
+import (
+	"github.com/fighterlyt/common/cryptocurrency"
+	"github.com/shopspring/decimal"
+)
+
+// Status 提现状态
+type Status int
+
+const (
+	// StatusAuditing 等待动态验证
+	StatusAuditing Status = 1
+	// StatusApproved 审核通过,等待发送
+	StatusApproved Status = 2
+	// StatusSending 交易已签名并记录,等待上链
+	StatusSending Status = 3
+	// StatusSuccess 成功
+	StatusSuccess Status = 4
+	// StatusFailed 失败
+	StatusFailed Status = 5
+	// StatusRejected 拒绝
+	StatusRejected Status = 6
+)
+
+// Final 是否为最终状态
+func (s Status) Final() bool {
+	return s == StatusSuccess || s == StatusFailed || s == StatusRejected
+}
+
+// Withdrawal 提现记录,实现了 cryptocurrency.TransactionRecord
+type Withdrawal struct {
+	ID                               int64  `gorm:"primaryKey"`
+	RequestID                        string `gorm:"column:request_id;type:varchar(64);uniqueIndex;comment:'业务ID,相同ID只提现一次'"`
+	UserID                           int64  `gorm:"column:user_id;index;comment:'用户ID'"`
+	cryptocurrency.TransactionDetail `gorm:"embedded"`
+	Status                           Status `gorm:"column:status;index;comment:'状态'"`
+	RawTx                            []byte `gorm:"column:raw_tx;type:blob;comment:'已签名的交易,未上链时重复广播'"`
+	Expiration                       int64  `gorm:"column:expiration;comment:'交易过期时间,毫秒'"`
+	Retries                          int    `gorm:"column:retries;comment:'交易过期后重新发送的次数'"`
+	CreateTime                       int64  `gorm:"column:create_time;autoCreateTime;comment:'创建时间'"`
+	UpdateTime                       int64  `gorm:"column:update_time;autoUpdateTime;comment:'更新时间'"`
+}
+
+func (w Withdrawal) TableName() string {
+	return `withdrawals`
+}
+
+func newWithdrawal(requestID string, userID int64, protocol cryptocurrency.Protocol, symbol, from, to string, amount decimal.Decimal) *Withdrawal { //nolint:lll
+	detail := cryptocurrency.NewTransactionDetail(amount, protocol, symbol, from, to)
+	detail.TradeKind = cryptocurrency.TradeTransfer
+
+	return &Withdrawal{
+		RequestID:         requestID,
+		UserID:            userID,
+		TransactionDetail: *detail,
+		Status:            StatusAuditing,
+	}
+}
+
+func (w Withdrawal) GetTxID() string {
+	return w.TxID
+}
+
+func (w Withdrawal) GetProtocol() cryptocurrency.Protocol {
+	return w.Protocol
+}
+
+func (w *Withdrawal) GetTransactionDetail() *cryptocurrency.TransactionDetail {
+	return &w.TransactionDetail
+}
+
+func (w *Withdrawal) SetTransactionDetail(detail *cryptocurrency.TransactionDetail) {
+	w.TransactionDetail = *detail
+}
+
+/*
+SetSendResult 记录发送结果,广播失败的交易仍可能上链,状态由检查结果决定
+参数:
+*	txID	string	交易ID
+*	err 	error 	广播错误
+返回值:
+*/
+func (w *Withdrawal) SetSendResult(txID string, err error) {
+	w.TxID = txID
+	w.Reason = ``
+
+	if err != nil {
+		w.Reason = err.Error()
+	}
+}
+
+/*
+SetCheckResult 记录链上结果,reason 为空表示成功
+参数:
+*	blockNumber	int64          	区块号
+*	fee        	decimal.Decimal	手续费
+*	reason     	string         	失败原因
+返回值:
+*/
+func (w *Withdrawal) SetCheckResult(blockNumber int64, fee decimal.Decimal, reason string) {
+	w.BlockNumber = blockNumber
+	w.Fee = fee
+	w.Reason = reason
+
+	if reason == `` {
+		w.Status = StatusSuccess
+	} else {
+		w.Status = StatusFailed
+	}
+}
@@ -0,0 +1,519 @@
+package withdraw
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fighterlyt/common/cryptocurrency"
+	"github.com/fighterlyt/common/cryptocurrency/tron/free"
+	"github.com/fighterlyt/common/cryptocurrency/tron/sweep"
+	"github.com/fighterlyt/common/helpers"
+	"github.com/fighterlyt/common/model"
+	"github.com/fighterlyt/common/twofactor"
+	"github.com/fighterlyt/gotron-sdk/pkg/proto/core"
+	"github.com/fighterlyt/log"
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	withdrawKey         = `withdraw`       // 模块标识
+	defaultInterval     = time.Second * 10 // 默认检查间隔
+	defaultMaxRetries   = 3                // 默认交易过期后重新发送的次数
+	defaultBatch        = 50               // 默认每次处理的数量
+	expirationTolerance = time.Minute      // 过期时间之后再等待一段时间,防止节点时间误差
+)
+
+var (
+	modelWithdrawal = &Withdrawal{}
+	// ErrNotFound 提现记录不存在
+	ErrNotFound = errors.New(`提现记录不存在`)
+	// ErrStatus 状态不允许该操作
+	ErrStatus = errors.New(`提现状态不允许该操作`)
+	// ErrConflict 相同 requestID 的提现参数不一致
+	ErrConflict = errors.New(`相同业务ID的提现参数不一致`)
+)
+
+// Option 提现选项
+type Option struct {
+	From          string        // 提现钱包地址
+	PrivateKey    string        // 提现钱包私钥
+	NotifyUserIDs []int64       // 需要动态验证时通知的用户
+	Interval      time.Duration // 发送和检查间隔
+	MaxRetries    int           // 交易过期后重新发送的次数
+	Batch         int           // 每次处理的数量
+}
+
+func (o *Option) fill() {
+	if o.Interval <= 0 {
+		o.Interval = defaultInterval
+	}
+
+	if o.MaxRetries <= 0 {
+		o.MaxRetries = defaultMaxRetries
+	}
+
+	if o.Batch <= 0 {
+		o.Batch = defaultBatch
+	}
+}
+
+// Service 提现服务
+type Service interface {
+	model.Module
+	// Submit 提交提现,相同 requestID 只会创建一次,参数不一致时返回 ErrConflict,need 表示需要动态验证
+	Submit(requestID string, userID int64, symbol, to string, amount decimal.Decimal) (withdrawal *Withdrawal, need bool, err error)
+	// Approve 动态验证通过后发送
+	Approve(requestID, password string) error
+	// Reject 拒绝
+	Reject(requestID, reason string) error
+	// Get 查询
+	Get(requestID string) (withdrawal *Withdrawal, err error)
+	// Send 发送审核通过的提现
+	Send() (sent int, err error)
+	// Check 检查发送中的提现,更新状态和手续费
+	Check() (finished int, err error)
+	// Start 定时发送和检查
+	Start()
+}
+
+type service struct {
+	db         *gorm.DB
+	logger     log.Logger
+	twoFactor  twofactor.Service
+	transferor sweep.Transferor
+	freezer    free.Service
+	notify     cryptocurrency.TradeNotify
+	option     Option
+	now        func() time.Time
+	shutdown   model.Shutdown
+	exit       chan struct{}
+}
+
+/*
+NewService 新建提现服务
+参数:
+*	db        	*gorm.DB                  	数据库
+*	logger    	log.Logger                	日志器
+*	twoFactor 	twofactor.Service         	审核和动态验证
+*	transferor	sweep.Transferor          	转账
+*	freezer   	free.Service              	能量质押,为空不质押
+*	notify    	cryptocurrency.TradeNotify	提现完成通知,可以为空
+*	option    	Option                    	选项
+返回值:
+*	Service   	Service                   	服务
+*	error     	error                     	错误
+*/
+func NewService(db *gorm.DB, logger log.Logger, twoFactor twofactor.Service, transferor sweep.Transferor, freezer free.Service, notify cryptocurrency.TradeNotify, option Option) (Service, error) { //nolint:lll
+	option.fill()
+
+	matched, err := helpers.IsPrivateKeyMatched(option.From, option.PrivateKey)
+	if err != nil {
+		return nil, errors.Wrap(err, `判断私钥和地址是否符合`)
+	}
+
+	if !matched {
+		return nil, errors.New(`私钥地址不符合`)
+	}
+
+	if twoFactor == nil || transferor == nil {
+		return nil, errors.New(`审核和转账不能为空`)
+	}
+
+	if err = db.AutoMigrate(modelWithdrawal); err != nil {
+		return nil, errors.Wrap(err, `建表`)
+	}
+
+	return &service{
+		db:         db,
+		logger:     logger.Derive(`提现`),
+		twoFactor:  twoFactor,
+		transferor: transferor,
+		freezer:    freezer,
+		notify:     notify,
+		option:     option,
+		now:        time.Now,
+		shutdown:   model.NewShutdown(),
+		exit:       make(chan struct{}),
+	}, nil
+}
+
+/*
+Submit 提交提现,通过 twofactor 审核,不需要动态验证时直接进入待发送
+参数:
+*	requestID 	string         	业务ID
+*	userID    	int64          	用户ID
+*	symbol    	string         	币种,model.TRX 或者TRC20代币名称
+*	to        	string         	收款地址
+*	amount    	decimal.Decimal	金额
+返回值:
+*	withdrawal	*Withdrawal    	提现记录
+*	need      	bool           	是否需要动态验证
+*	err       	error          	错误
+*/
+func (s service) Submit(requestID string, userID int64, symbol, to string, amount decimal.Decimal) (withdrawal *Withdrawal, need bool, err error) { //nolint:lll
+	if !amount.IsPositive() {
+		return nil, false, fmt.Errorf(`提现金额[%s]必须大于0`, amount.String())
+	}
+
+	withdrawal = newWithdrawal(requestID, userID, cryptocurrency.Trc20, symbol, s.option.From, to, amount)
+
+	created := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(withdrawal)
+	if created.Error != nil {
+		return nil, false, errors.Wrap(created.Error, `保存`)
+	}
+
+	// 重复提交,返回已有记录,仍在审核中时重新审核,上次审核或者通知可能失败
+	if created.RowsAffected == 0 {
+		existed, getErr := s.Get(requestID)
+		if getErr != nil {
+			return nil, false, getErr
+		}
+
+		if existed.UserID != userID || existed.Symbol != symbol || existed.To != to || !existed.Amount.Equal(amount) {
+			return existed, false, errors.Wrapf(ErrConflict, `业务ID[%s]`, requestID)
+		}
+
+		if withdrawal = existed; withdrawal.Status != StatusAuditing {
+			return withdrawal, false, nil
+		}
+	}
+
+	message := fmt.Sprintf(`用户[%d]提现 %s %s 到 %s`, userID, amount.String(), symbol, to)
+
+	if need, err = s.twoFactor.Process(requestID, userID, s.option.NotifyUserIDs, cryptocurrency.Trc20.String(), symbol, message, amount); err != nil {
+		return withdrawal, false, errors.Wrap(err, `审核`)
+	}
+
+	if !need {
+		if err = s.transit(withdrawal, StatusAuditing, StatusApproved); err != nil {
+			return withdrawal, false, err
+		}
+	}
+
+	return withdrawal, need, nil
+}
+
+func (s service) Approve(requestID, password string) error {
+	ok, err := s.twoFactor.Auth(password)
+	if err != nil {
+		return errors.Wrap(err, `动态验证`)
+	}
+
+	if !ok {
+		return errors.New(`动态密码错误`)
+	}
+
+	withdrawal, err := s.Get(requestID)
+	if err != nil {
+		return err
+	}
+
+	return s.transit(withdrawal, StatusAuditing, StatusApproved)
+}
+
+func (s service) Reject(requestID, reason string) error {
+	withdrawal, err := s.Get(requestID)
+	if err != nil {
+		return err
+	}
+
+	withdrawal.Reason = reason
+
+	return s.transit(withdrawal, StatusAuditing, StatusRejected)
+}
+
+func (s service) Get(requestID string) (withdrawal *Withdrawal, err error) {
+	withdrawal = &Withdrawal{}
+
+	if err = s.db.Where(`request_id = ?`, requestID).First(withdrawal).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+
+		return nil, errors.Wrap(err, `查询`)
+	}
+
+	return withdrawal, nil
+}
+
+// transit 修改状态,只有当前状态为 from 时才修改,防止并发重复处理
+func (s service) transit(withdrawal *Withdrawal, from, to Status) error {
+	updated := s.db.Model(modelWithdrawal).Where(`id = ? and status = ?`, withdrawal.ID, from).Updates(map[string]interface{}{
+		`status`: to,
+		`reason`: withdrawal.Reason,
+	})
+
+	if updated.Error != nil {
+		return errors.Wrap(updated.Error, `更新状态`)
+	}
+
+	if updated.RowsAffected == 0 {
+		return ErrStatus
+	}
+
+	withdrawal.Status = to
+
+	return nil
+}
+
+func (s service) Send() (sent int, err error) {
+	var (
+		withdrawals []Withdrawal
+	)
+
+	if err = s.db.Where(`status = ?`, StatusApproved).Order(`id`).Limit(s.option.Batch).Find(&withdrawals).Error; err != nil {
+		return 0, errors.Wrap(err, `查询待发送`)
+	}
+
+	for i := range withdrawals {
+		if sendErr := s.send(&withdrawals[i]); sendErr != nil {
+			if errors.Is(sendErr, ErrStatus) {
+				continue
+			}
+
+			err = multierr.Append(err, errors.Wrapf(sendErr, `发送[%s]`, withdrawals[i].RequestID))
+
+			continue
+		}
+
+		sent++
+	}
+
+	return sent, err
+}
+
+/*
+send 签名后先记录交易再广播,记录成功的进程才会广播,之后只会重复广播同一个交易
+参数:
+*	withdrawal	*Withdrawal	提现
+返回值:
+*	error     	error      	错误
+*/
+func (s service) send(withdrawal *Withdrawal) error {
+	if s.freezer != nil && withdrawal.Symbol != model.TRX {
+		if _, err := s.freezer.FreezeForTRC20Transfer(s.option.From, withdrawal.To, withdrawal.Amount); err != nil {
+			return errors.Wrap(err, `补充能量`)
+		}
+	}
+
+	tx, txID, err := s.transferor.Build(s.option.From, s.option.PrivateKey, withdrawal.To, withdrawal.Symbol, withdrawal.Amount)
+	if err != nil {
+		return err
+	}
+
+	raw, err := proto.Marshal(tx)
+	if err != nil {
+		return errors.Wrap(err, `序列化交易`)
+	}
+
+	updated := s.db.Model(modelWithdrawal).Where(`id = ? and status = ?`, withdrawal.ID, StatusApproved).Updates(map[string]interface{}{
+		`status`:     StatusSending,
+		`tx_id`:      txID,
+		`raw_tx`:     raw,
+		`expiration`: tx.GetRawData().GetExpiration(),
+		`timestamp`:  s.now().UnixMilli(),
+	})
+
+	if updated.Error != nil {
+		return errors.Wrap(updated.Error, `记录交易`)
+	}
+
+	if updated.RowsAffected == 0 {
+		return ErrStatus
+	}
+
+	s.logger.Info(`发送提现`, zap.String(`业务ID`, withdrawal.RequestID), zap.String(`交易`, txID), zap.String(`金额`, withdrawal.Amount.String()))
+
+	broadcastErr := s.transferor.Broadcast(tx)
+	withdrawal.SetSendResult(txID, broadcastErr)
+
+	if broadcastErr != nil {
+		// 广播失败的交易仍可能上链,由 Check 重新广播或者在过期后重新发送
+		s.logger.Warn(`广播失败,等待检查`, zap.String(`交易`, txID), helpers.ZapError(broadcastErr))
+
+		helpers.IgnoreError(s.logger, `记录广播错误`, func() error {
+			return s.db.Model(modelWithdrawal).Where(`id = ? and tx_id = ?`, withdrawal.ID, txID).Update(`reason`, withdrawal.Reason).Error
+		})
+	}
+
+	return nil
+}
+
+func (s service) Check() (finished int, err error) {
+	var (
+		withdrawals []Withdrawal
+	)
+
+	if err = s.db.Where(`status = ?`, StatusSending).Order(`id`).Limit(s.option.Batch).Find(&withdrawals).Error; err != nil {
+		return 0, errors.Wrap(err, `查询发送中`)
+	}
+
+	for i := range withdrawals {
+		done, checkErr := s.check(&withdrawals[i])
+		if checkErr != nil {
+			err = multierr.Append(err, errors.Wrapf(checkErr, `检查[%s]`, withdrawals[i].RequestID))
+			continue
+		}
+
+		if done {
+			finished++
+		}
+	}
+
+	return finished, err
+}
+
+/*
+check 检查链上结果.未上链且未过期时重新广播同一个交易,过期后交易不可能再上链,才允许重新签名发送
+参数:
+*	withdrawal	*Withdrawal	提现
+返回值:
+*	done      	bool       	是否已经结束
+*	err       	error      	错误
+*/
+func (s service) check(withdrawal *Withdrawal) (done bool, err error) {
+	result, err := s.transferor.Result(withdrawal.TxID)
+	if err != nil {
+		return false, err
+	}
+
+	switch result.Status {
+	case sweep.TxSuccess:
+		withdrawal.SetCheckResult(result.BlockNumber, result.Fee, ``)
+	case sweep.TxFailed:
+		withdrawal.SetCheckResult(result.BlockNumber, result.Fee, result.Reason)
+	default:
+		return false, s.pending(withdrawal)
+	}
+
+	updated := s.db.Model(modelWithdrawal).Where(`id = ? and status = ? and tx_id = ?`, withdrawal.ID, StatusSending, withdrawal.TxID).
+		Updates(map[string]interface{}{
+			`status`:       withdrawal.Status,
+			`block_number`: withdrawal.BlockNumber,
+			`fee`:          withdrawal.Fee,
+			`reason`:       withdrawal.Reason,
+		})
+
+	if updated.Error != nil {
+		return false, errors.Wrap(updated.Error, `更新结果`)
+	}
+
+	if updated.RowsAffected == 0 {
+		return false, nil
+	}
+
+	s.finish(withdrawal)
+
+	return true, nil
+}
+
+// finish 提现结束,记录日志并通知
+func (s service) finish(withdrawal *Withdrawal) {
+	s.logger.Info(`提现完成`, zap.String(`业务ID`, withdrawal.RequestID), zap.Int(`状态`, int(withdrawal.Status)), zap.String(`原因`, withdrawal.Reason))
+
+	if s.notify != nil {
+		helpers.IgnoreError(s.logger, `提现通知`, func() error {
+			return s.notify.Notify(withdrawal.Protocol, []*cryptocurrency.TransactionDetail{withdrawal.GetTransactionDetail()})
+		})
+	}
+}
+
+// pending 交易未上链
+func (s service) pending(withdrawal *Withdrawal) error {
+	expiration := time.UnixMilli(withdrawal.Expiration).Add(expirationTolerance)
+
+	if s.now().Before(expiration) {
+		tx := &core.Transaction{}
+
+		if err := proto.Unmarshal(withdrawal.RawTx, tx); err != nil {
+			return errors.Wrap(err, `解析交易`)
+		}
+
+		// 重复广播同一个交易不会重复转账
+		helpers.IgnoreError(s.logger, `重新广播`, func() error {
+			return s.transferor.Broadcast(tx)
+		})
+
+		return nil
+	}
+
+	reason := fmt.Sprintf(`交易[%s]过期`, withdrawal.TxID)
+	failed := withdrawal.Retries >= s.option.MaxRetries
+
+	values := map[string]interface{}{
+		`status`:  StatusApproved,
+		`tx_id`:   ``,
+		`raw_tx`:  nil,
+		`retries`: gorm.Expr(`retries + 1`),
+		`reason`:  reason,
+	}
+
+	if failed {
+		values[`status`] = StatusFailed
+	}
+
+	s.logger.Warn(`提现交易过期`, zap.String(`业务ID`, withdrawal.RequestID), zap.String(`交易`, withdrawal.TxID), zap.Int(`重试次数`, withdrawal.Retries))
+
+	updated := s.db.Model(modelWithdrawal).Where(`id = ? and status = ? and tx_id = ?`, withdrawal.ID, StatusSending, withdrawal.TxID).Updates(values)
+	if updated.Error != nil {
+		return errors.Wrap(updated.Error, `更新过期交易`)
+	}
+
+	if failed && updated.RowsAffected == 1 {
+		withdrawal.Status, withdrawal.Reason = StatusFailed, reason
+		withdrawal.Retries++
+
+		s.finish(withdrawal)
+	}
+
+	return nil
+}
+
+func (s *service) Start() {
+	helpers.EnsureGo(s.logger, func() {
+		ticker := time.NewTicker(s.option.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := s.Check(); err != nil {
+					s.logger.Warn(`检查提现失败`, helpers.ZapError(err))
+				}
+
+				if _, err := s.Send(); err != nil {
+					s.logger.Warn(`发送提现失败`, helpers.ZapError(err))
+				}
+			case <-s.exit:
+				return
+			}
+		}
+	})
+}
+
+func (s *service) Close() {
+	if s.shutdown.IsClosed() {
+		return
+	}
+
+	s.shutdown.Close()
+	close(s.exit)
+}
+
+func (s *service) IsClosed() bool {
+	return s.shutdown.IsClosed()
+}
+
+func (s *service) Key() string {
+	return withdrawKey
+}
+
+func (s *service) Name() string {
+	return `提现`
+}
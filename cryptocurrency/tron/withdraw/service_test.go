@@ -0,0 +1,211 @@
+package withdraw
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/fighterlyt/common/cryptocurrency"
+	"github.com/fighterlyt/common/cryptocurrency/tron/sweep"
+	tronAddress "github.com/fighterlyt/gotron-sdk/pkg/address"
+	"github.com/fighterlyt/gotron-sdk/pkg/proto/core"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	password = `123456`
+)
+
+type mockTwoFactor struct {
+	limit decimal.Decimal
+}
+
+func (m mockTwoFactor) Process(_ string, _ int64, _ []int64, _, _, _ string, amount decimal.Decimal) (need bool, err error) {
+	return amount.GreaterThan(m.limit), nil
+}
+
+func (m mockTwoFactor) Auth(input string) (ok bool, err error) {
+	return input == password, nil
+}
+
+func (m mockTwoFactor) QR(_ string) (qrcode string, err error) {
+	return ``, nil
+}
+
+type mockNotify struct {
+	details []*cryptocurrency.TransactionDetail
+}
+
+func (m *mockNotify) Notify(_ cryptocurrency.Protocol, details []*cryptocurrency.TransactionDetail) error {
+	m.details = append(m.details, details...)
+
+	return nil
+}
+
+type mockTransferor struct {
+	built      int
+	broadcast  int
+	expiration int64
+	results    map[string]*sweep.TxResult
+}
+
+func (m *mockTransferor) Build(_, _, to, _ string, _ decimal.Decimal) (tx *core.Transaction, txID string, err error) {
+	m.built++
+
+	return &core.Transaction{RawData: &core.TransactionRaw{Expiration: m.expiration}}, fmt.Sprintf(`%s_%d`, to, m.built), nil
+}
+
+func (m *mockTransferor) Broadcast(_ *core.Transaction) error {
+	m.broadcast++
+
+	return errors.New(`超时`)
+}
+
+func (m *mockTransferor) Result(txID string) (result *sweep.TxResult, err error) {
+	if result = m.results[txID]; result == nil {
+		return &sweep.TxResult{Status: sweep.TxNotFound}, nil
+	}
+
+	return result, nil
+}
+
+func newTestService(t *testing.T, transferor sweep.Transferor) *service {
+	require.NoError(t, db.Migrator().DropTable(modelWithdrawal))
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err, `生成私钥`)
+
+	option := Option{
+		From:       tronAddress.PubkeyToAddress(key.PublicKey).String(),
+		PrivateKey: hex.EncodeToString(crypto.FromECDSA(key)),
+		MaxRetries: 1,
+	}
+
+	target, err := NewService(db, logger, mockTwoFactor{limit: decimal.New(100, 0)}, transferor, nil, nil, option)
+	require.NoError(t, err, `新建服务`)
+
+	return target.(*service)
+}
+
+func TestService_Submit(t *testing.T) {
+	target := newTestService(t, &mockTransferor{})
+
+	withdrawal, need, err := target.Submit(`small`, 1, cryptocurrency.USDT, `to`, decimal.New(10, 0))
+	require.NoError(t, err)
+	require.False(t, need, `小额不需要动态验证`)
+	require.Equal(t, StatusApproved, withdrawal.Status)
+
+	withdrawal, need, err = target.Submit(`large`, 1, cryptocurrency.USDT, `to`, decimal.New(1000, 0))
+	require.NoError(t, err)
+	require.True(t, need, `大额需要动态验证`)
+	require.Equal(t, StatusAuditing, withdrawal.Status)
+
+	again, need, err := target.Submit(`large`, 1, cryptocurrency.USDT, `to`, decimal.New(1000, 0))
+	require.NoError(t, err, `重复提交`)
+	require.True(t, need)
+	require.Equal(t, withdrawal.ID, again.ID, `重复提交返回已有记录`)
+
+	_, _, err = target.Submit(`large`, 1, cryptocurrency.USDT, `to`, decimal.New(999, 0))
+	require.ErrorIs(t, err, ErrConflict, `参数不一致`)
+
+	again, need, err = target.Submit(`small`, 1, cryptocurrency.USDT, `to`, decimal.New(10, 0))
+	require.NoError(t, err)
+	require.False(t, need, `已经通过不需要验证`)
+	require.Equal(t, StatusApproved, again.Status)
+
+	require.Error(t, target.Approve(`large`, `wrong`), `动态密码错误`)
+	require.NoError(t, target.Approve(`large`, password))
+	require.ErrorIs(t, target.Approve(`large`, password), ErrStatus, `重复审核`)
+	require.ErrorIs(t, target.Reject(`small`, `拒绝`), ErrStatus, `已通过不能拒绝`)
+
+	_, err = target.Get(`none`)
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestService_SendAndCheck(t *testing.T) {
+	now := time.Now()
+	transferor := &mockTransferor{expiration: now.Add(time.Minute).UnixMilli(), results: map[string]*sweep.TxResult{}}
+	target := newTestService(t, transferor)
+	target.now = func() time.Time { return now }
+
+	_, _, err := target.Submit(`a`, 1, cryptocurrency.USDT, `to`, decimal.New(10, 0))
+	require.NoError(t, err)
+
+	sent, err := target.Send()
+	require.NoError(t, err, `广播失败不影响发送`)
+	require.Equal(t, 1, sent)
+
+	withdrawal, err := target.Get(`a`)
+	require.NoError(t, err)
+	require.Equal(t, StatusSending, withdrawal.Status)
+	require.Equal(t, `to_1`, withdrawal.TxID)
+	require.NotEmpty(t, withdrawal.RawTx, `记录已签名交易`)
+
+	sent, err = target.Send()
+	require.NoError(t, err)
+	require.Zero(t, sent, `发送中的不会重复发送`)
+
+	// 未过期,重新广播同一个交易
+	finished, err := target.Check()
+	require.NoError(t, err)
+	require.Zero(t, finished)
+	require.Equal(t, 1, transferor.built)
+	require.Equal(t, 2, transferor.broadcast)
+
+	// 过期后重新发送
+	target.now = func() time.Time { return now.Add(time.Hour) }
+	_, err = target.Check()
+	require.NoError(t, err)
+
+	withdrawal, err = target.Get(`a`)
+	require.NoError(t, err)
+	require.Equal(t, StatusApproved, withdrawal.Status)
+	require.Equal(t, 1, withdrawal.Retries)
+
+	transferor.expiration = target.now().Add(time.Minute).UnixMilli()
+	_, err = target.Send()
+	require.NoError(t, err)
+
+	transferor.results[`to_2`] = &sweep.TxResult{Status: sweep.TxSuccess, BlockNumber: 10, Fee: decimal.New(1, 0)}
+	finished, err = target.Check()
+	require.NoError(t, err)
+	require.Equal(t, 1, finished)
+
+	withdrawal, err = target.Get(`a`)
+	require.NoError(t, err)
+	require.Equal(t, StatusSuccess, withdrawal.Status)
+	require.Equal(t, `to_2`, withdrawal.TxID)
+	require.Equal(t, int64(10), withdrawal.BlockNumber)
+	require.True(t, withdrawal.Fee.Equal(decimal.New(1, 0)))
+}
+
+func TestService_Expired(t *testing.T) {
+	now := time.Now()
+	transferor := &mockTransferor{expiration: now.UnixMilli(), results: map[string]*sweep.TxResult{}}
+	target := newTestService(t, transferor)
+	target.now = func() time.Time { return now.Add(time.Hour) }
+
+	notify := &mockNotify{}
+	target.notify = notify
+
+	_, _, err := target.Submit(`a`, 1, cryptocurrency.USDT, `to`, decimal.New(10, 0))
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		_, err = target.Send()
+		require.NoError(t, err)
+
+		_, err = target.Check()
+		require.NoError(t, err)
+	}
+
+	withdrawal, err := target.Get(`a`)
+	require.NoError(t, err)
+	require.Equal(t, StatusFailed, withdrawal.Status, `超过重试次数`)
+	require.Equal(t, 2, transferor.built)
+	require.Len(t, notify.details, 1, `失败时通知`)
+}
@@ -76,3 +76,16 @@ func (g GaugeVec) WithLabelValuesSet(f float64, lvs ...string) {
 		return metricsRedisClient.HSet(ctx, generateRedisKey(g.name), strings.Join(keys, ";"), f).Err()
 	})
 }
+
+func (g GaugeVec) DeleteLabelValues(lvs ...string) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	g.gaugeVec.DeleteLabelValues(lvs...)
+
+	keys := g.lvsToKeys(lvs...)
+
+	helpers.IgnoreError(g.logger, "redis操作失败", func() error {
+		return metricsRedisClient.HDel(ctx, generateRedisKey(g.name), strings.Join(keys, ";")).Err()
+	})
+}
@@ -7,6 +7,7 @@ import (
 
 	es "github.com/elastic/go-elasticsearch/v7"
 	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/fighterlyt/common/helpers"
 	"github.com/fighterlyt/log"
 	"github.com/pkg/errors"
 	"go.uber.org/zap/zapcore"
@@ -66,7 +67,7 @@ func NewClient(argument *ClientArgument) (client *Client, err error) {
 		EnableMetrics:         false,
 		EnableDebugLogger:     false,
 		RetryBackoff:          nil,
-		Transport:             nil,
+		Transport:             helpers.NewTracingTransport(nil),
 		Logger:                newElasticLogger(argument.logger, zapcore.DebugLevel),
 		Selector:              nil,
 		ConnectionPoolFunc:    nil,
@@ -98,7 +99,26 @@ func (c *Client) CreateIndex(name string) error {
 	return nil
 }
 
+/*
+Add 添加文档
+参数:
+*	document	Document	文档
+返回值:
+*	error   	error   	错误
+*/
 func (c *Client) Add(document Document) error {
+	return c.AddContext(bg, document)
+}
+
+/*
+AddContext 添加文档,ctx 中有 span 时链路传递到es
+参数:
+*	ctx     	context.Context	上下文
+*	document	Document       	文档
+返回值:
+*	error   	error          	错误
+*/
+func (c *Client) AddContext(ctx context.Context, document Document) error {
 	var (
 		jsonDocument []byte
 		err          error
@@ -131,7 +151,7 @@ func (c *Client) Add(document Document) error {
 		Header:              nil,
 	}
 
-	resp, err = req.Do(bg, c.Client)
+	resp, err = req.Do(ctx, c.Client)
 
 	if err != nil {
 		return errors.Wrap(err, `创建索引错误`)
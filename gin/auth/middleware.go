@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"context"
+	"strings"
+
+	"github.com/fighterlyt/common/helpers"
+	"github.com/fighterlyt/common/model/invoke"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+const (
+	// RoleAdmin 管理员
+	RoleAdmin = `admin`
+
+	tokenHeader   = `token`
+	authorization = `Authorization`
+	bearer        = `Bearer `
+)
+
+var (
+	// ErrForbidden 角色没有权限
+	ErrForbidden = errors.New(`没有权限`)
+)
+
+/*
+Middleware 验证 token,把 *helpers.JwtCustomClaims 放入 helpers.ClaimsKey,失败时返回 invoke.Unauthorized
+参数:
+*	service        	helpers.JWTService	JWT服务,为空时使用 helpers.GetJWTService
+返回值:
+*	gin.HandlerFunc	gin.HandlerFunc   	中间件
+*/
+func Middleware(service helpers.JWTService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		jwtService := service
+		if jwtService == nil {
+			jwtService = helpers.GetJWTService()
+		}
+
+		token := getToken(ctx)
+		if token == `` {
+			fail(ctx, invoke.Unauthorized, helpers.ErrNoToken)
+			return
+		}
+
+		claims, err := jwtService.ParseToken(token)
+		if err != nil {
+			fail(ctx, invoke.Unauthorized, errors.Wrap(err, `验证token`))
+			return
+		}
+
+		ctx.Set(helpers.ClaimsKey, claims)
+		// 下游只传递 ctx.Request.Context() 时也能通过 helpers.Getclaims 获取
+		ctx.Request = ctx.Request.WithContext(context.WithValue(ctx.Request.Context(), helpers.ClaimsKey, claims)) //nolint:staticcheck
+
+		ctx.Next()
+	}
+}
+
+/*
+RequireRoles 角色守卫,必须在 Middleware 之后使用
+参数:
+*	roles          	...string      	允许的角色
+返回值:
+*	gin.HandlerFunc	gin.HandlerFunc	中间件
+*/
+func RequireRoles(roles ...string) gin.HandlerFunc {
+	allowed := make(map[string]struct{}, len(roles))
+
+	for _, role := range roles {
+		allowed[role] = struct{}{}
+	}
+
+	return func(ctx *gin.Context) {
+		claims, err := helpers.Getclaims(ctx)
+		if err != nil {
+			fail(ctx, invoke.Unauthorized, err)
+			return
+		}
+
+		if _, exist := allowed[claims.Role]; !exist {
+			fail(ctx, invoke.Fail, errors.Wrapf(ErrForbidden, `角色[%s]`, claims.Role))
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+/*
+Guard 返回需要登录并且属于指定角色才能访问的路由,可以传给 parameters.NewService 或者 helpers.BaseResource
+参数:
+*	router     	gin.IRouter       	路由
+*	service    	helpers.JWTService	JWT服务,为空时使用 helpers.GetJWTService
+*	roles      	...string         	允许的角色,为空时只验证登录
+返回值:
+*	gin.IRouter	gin.IRouter       	路由
+*/
+func Guard(router gin.IRouter, service helpers.JWTService, roles ...string) gin.IRouter {
+	handlers := []gin.HandlerFunc{Middleware(service)}
+
+	if len(roles) > 0 {
+		handlers = append(handlers, RequireRoles(roles...))
+	}
+
+	return router.Group(``, handlers...)
+}
+
+// getToken 优先使用 token 头,兼容 Authorization: Bearer
+func getToken(ctx *gin.Context) string {
+	if token := ctx.GetHeader(tokenHeader); token != `` {
+		return token
+	}
+
+	if value := ctx.GetHeader(authorization); strings.HasPrefix(value, bearer) {
+		return strings.TrimPrefix(value, bearer)
+	}
+
+	return ``
+}
+
+func fail(ctx *gin.Context, code invoke.StatCode, err error) {
+	invoke.ReturnFail(ctx, code, err, ``)
+	ctx.Abort()
+}
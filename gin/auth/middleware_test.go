@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fighterlyt/common/helpers"
+	"github.com/fighterlyt/common/model/invoke"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRouter(t *testing.T) (router *gin.Engine, service helpers.JWTService) {
+	gin.SetMode(gin.TestMode)
+
+	service, err := helpers.NewJWTService(helpers.JWTConfig{Keys: []helpers.JWTKey{{Algorithm: helpers.JWTHS256, Secret: `secret`}}}, nil)
+	require.NoError(t, err)
+
+	router = gin.New()
+
+	handler := func(ctx *gin.Context) {
+		userID, getErr := helpers.GetUserID(ctx)
+		if getErr != nil {
+			invoke.ReturnFail(ctx, invoke.Fail, getErr, ``)
+			return
+		}
+
+		claims, getErr := helpers.Getclaims(ctx.Request.Context())
+		if getErr != nil {
+			invoke.ReturnFail(ctx, invoke.Fail, getErr, ``)
+			return
+		}
+
+		invoke.ReturnSuccess(ctx, []int64{userID, claims.UserID})
+	}
+
+	Guard(router.Group(`/user`), service).POST(`/get`, handler)
+	Guard(router.Group(`/admin`), service, RoleAdmin).POST(`/get`, handler)
+
+	return router, service
+}
+
+func call(t *testing.T, router *gin.Engine, path, token string) *invoke.Result {
+	request := httptest.NewRequest(http.MethodPost, path, nil)
+	if token != `` {
+		request.Header.Set(`Authorization`, `Bearer `+token)
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+
+	result := &invoke.Result{}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), result), `解析返回值`)
+
+	return result
+}
+
+func TestGuard(t *testing.T) {
+	router, service := newTestRouter(t)
+
+	require.Equal(t, invoke.Unauthorized, call(t, router, `/user/get`, ``).Code, `没有token`)
+	require.Equal(t, invoke.Unauthorized, call(t, router, `/user/get`, `invalid`).Code, `token无效`)
+
+	userToken, err := service.CreateToken(helpers.JwtCustomClaims{UserID: 1, Role: `user`})
+	require.NoError(t, err)
+
+	adminToken, err := service.CreateToken(helpers.JwtCustomClaims{UserID: 2, Role: RoleAdmin})
+	require.NoError(t, err)
+
+	result := call(t, router, `/user/get`, userToken)
+	require.Equal(t, invoke.Success, result.Code)
+	require.Equal(t, []interface{}{float64(1), float64(1)}, result.Data, `从gin和请求上下文获取claims`)
+
+	require.Equal(t, invoke.Fail, call(t, router, `/admin/get`, userToken).Code, `角色没有权限`)
+	require.Equal(t, invoke.Success, call(t, router, `/admin/get`, adminToken).Code)
+}
@@ -0,0 +1,613 @@
+package helpers
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+)
+
+const (
+	// JWTHS256 HMAC-SHA256
+	JWTHS256 = `HS256`
+	// JWTRS256 RSA-SHA256
+	JWTRS256 = `RS256`
+	// JWTEdDSA Ed25519
+	JWTEdDSA = `EdDSA`
+
+	kidHeader        = `kid`
+	jtiSize          = 16
+	defaultJWTExpire = time.Hour
+	legacyJWTKey     = `acw_login_jwt`
+)
+
+var (
+	// ErrTokenRevoked token 已注销
+	ErrTokenRevoked = errors.New("Token 已注销")
+
+	jwtCtx = context.Background()
+
+	signingMethodEdDSA = &SigningMethodEd25519{}
+
+	defaultJWT     JWTService = mustLegacyJWTService([]byte(legacyJWTKey))
+	defaultJWTLock            = &sync.RWMutex{}
+)
+
+func init() {
+	jwt.RegisterSigningMethod(signingMethodEdDSA.Alg(), func() jwt.SigningMethod {
+		return signingMethodEdDSA
+	})
+}
+
+// JWTKey 签名密钥,通过 kid 区分
+type JWTKey struct {
+	ID         string `json:"id"`         // kid,为空表示旧版没有 kid 的 token
+	Algorithm  string `json:"algorithm"`  // JWTHS256、JWTRS256、JWTEdDSA
+	Secret     string `json:"secret"`     // HS256 密钥
+	PrivateKey string `json:"privateKey"` // PEM 私钥,RS256/EdDSA 签名使用,只用于验证的旧密钥可以为空
+	PublicKey  string `json:"publicKey"`  // PEM 公钥,为空时从私钥中获取
+}
+
+// JWTConfig JWT 配置
+type JWTConfig struct {
+	Issuer        string        `json:"issuer"`        // 签发者,不为空时解析会校验
+	SigningKey    string        `json:"signingKey"`    // 签名使用的 kid
+	Keys          []JWTKey      `json:"keys"`          // 所有有效的密钥,轮换时旧密钥保留到使用它的 token 全部过期
+	Expire        time.Duration `json:"expire"`        // token 有效期,默认1小时
+	RefreshWindow time.Duration `json:"refreshWindow"` // 过期之后仍然可以刷新的时长,默认等于有效期
+}
+
+// JWTService JWT 签发和验证
+type JWTService interface {
+	// CreateToken 创建 token,自动填充签发者、jti 和用户 token 版本,过期时间为空时使用配置的有效期
+	CreateToken(claims JwtCustomClaims) (string, error)
+	// ParseToken 解析并验证 token,包括注销状态
+	ParseToken(tokenString string) (*JwtCustomClaims, error)
+	// RefreshToken 刷新 token,旧 token 随即注销
+	RefreshToken(tokenString string) (string, error)
+	// Revoke 注销单个 token,用于退出登录
+	Revoke(claims *JwtCustomClaims) error
+	// RevokeUser 注销用户所有已签发的 token
+	RevokeUser(userID int64) error
+}
+
+// JWTRevocation token 注销记录
+type JWTRevocation interface {
+	// Revoke 注销 jti,过期时间之后可以删除
+	Revoke(jti string, expire time.Time) error
+	// IsRevoked jti 是否已注销
+	IsRevoked(jti string) (bool, error)
+	// Version 用户当前的 token 版本
+	Version(userID int64) (int64, error)
+	// IncrVersion 增加用户的 token 版本,之前签发的 token 全部失效
+	IncrVersion(userID int64) (int64, error)
+}
+
+type jwtKey struct {
+	method     jwt.SigningMethod
+	signingKey interface{}
+	verifyKey  interface{}
+}
+
+type jwtService struct {
+	config     JWTConfig
+	keys       map[string]*jwtKey
+	signing    *jwtKey
+	revocation JWTRevocation
+	now        func() time.Time
+}
+
+/*
+NewJWTService 根据配置新建 JWT 服务
+参数:
+*	config    	JWTConfig    	配置
+*	revocation	JWTRevocation	注销记录,为空时不支持注销,见 NewRedisJWTRevocation
+返回值:
+*	JWTService	JWTService   	服务
+*	error     	error        	错误
+*/
+func NewJWTService(config JWTConfig, revocation JWTRevocation) (JWTService, error) {
+	return newJWTService(config, revocation)
+}
+
+func newJWTService(config JWTConfig, revocation JWTRevocation) (*jwtService, error) {
+	if config.Expire <= 0 {
+		config.Expire = defaultJWTExpire
+	}
+
+	if config.RefreshWindow <= 0 {
+		config.RefreshWindow = config.Expire
+	}
+
+	service := &jwtService{
+		config:     config,
+		keys:       make(map[string]*jwtKey, len(config.Keys)),
+		revocation: revocation,
+		now:        time.Now,
+	}
+
+	for _, item := range config.Keys {
+		if _, exist := service.keys[item.ID]; exist {
+			return nil, fmt.Errorf(`密钥[%s]重复`, item.ID)
+		}
+
+		key, err := parseJWTKey(item)
+		if err != nil {
+			return nil, errors.Wrapf(err, `密钥[%s]`, item.ID)
+		}
+
+		service.keys[item.ID] = key
+	}
+
+	signing, exist := service.keys[config.SigningKey]
+	if !exist {
+		return nil, fmt.Errorf(`签名密钥[%s]不存在`, config.SigningKey)
+	}
+
+	if signing.signingKey == nil {
+		return nil, fmt.Errorf(`签名密钥[%s]没有私钥`, config.SigningKey)
+	}
+
+	service.signing = signing
+
+	return service, nil
+}
+
+func mustLegacyJWTService(secret []byte) *jwtService {
+	service, err := legacyJWTService(secret)
+	if err != nil {
+		panic(`旧版JWT配置错误` + err.Error())
+	}
+
+	return service
+}
+
+// legacyJWTService 旧版没有 kid 的 HS256 密钥
+func legacyJWTService(secret []byte) (*jwtService, error) {
+	return newJWTService(JWTConfig{Keys: []JWTKey{{Algorithm: JWTHS256, Secret: string(secret)}}}, nil)
+}
+
+/*
+parseJWTKey 解析密钥配置
+参数:
+*	item   	JWTKey 	配置
+返回值:
+*	key    	*jwtKey	密钥
+*	err    	error  	错误
+*/
+func parseJWTKey(item JWTKey) (key *jwtKey, err error) {
+	switch item.Algorithm {
+	case JWTHS256:
+		if item.Secret == `` {
+			return nil, errors.New(`HS256 密钥不能为空`)
+		}
+
+		return &jwtKey{method: jwt.SigningMethodHS256, signingKey: []byte(item.Secret), verifyKey: []byte(item.Secret)}, nil
+	case JWTRS256:
+		key = &jwtKey{method: jwt.SigningMethodRS256}
+
+		if item.PrivateKey != `` {
+			var private *rsa.PrivateKey
+
+			if private, err = jwt.ParseRSAPrivateKeyFromPEM([]byte(item.PrivateKey)); err != nil {
+				return nil, errors.Wrap(err, `解析RSA私钥`)
+			}
+
+			key.signingKey, key.verifyKey = private, &private.PublicKey
+		}
+
+		if item.PublicKey != `` {
+			if key.verifyKey, err = jwt.ParseRSAPublicKeyFromPEM([]byte(item.PublicKey)); err != nil {
+				return nil, errors.Wrap(err, `解析RSA公钥`)
+			}
+		}
+	case JWTEdDSA:
+		key = &jwtKey{method: signingMethodEdDSA}
+
+		if item.PrivateKey != `` {
+			var private ed25519.PrivateKey
+
+			if private, err = parseEd25519PrivateKey(item.PrivateKey); err != nil {
+				return nil, err
+			}
+
+			key.signingKey, key.verifyKey = private, private.Public()
+		}
+
+		if item.PublicKey != `` {
+			if key.verifyKey, err = parseEd25519PublicKey(item.PublicKey); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf(`不支持的算法[%s]`, item.Algorithm)
+	}
+
+	if key.verifyKey == nil {
+		return nil, errors.New(`公钥和私钥不能同时为空`)
+	}
+
+	return key, nil
+}
+
+func parseEd25519PrivateKey(data string) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New(`Ed25519私钥不是PEM格式`)
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, `解析Ed25519私钥`)
+	}
+
+	private, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New(`不是Ed25519私钥`)
+	}
+
+	return private, nil
+}
+
+func parseEd25519PublicKey(data string) (ed25519.PublicKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New(`Ed25519公钥不是PEM格式`)
+	}
+
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, `解析Ed25519公钥`)
+	}
+
+	public, ok := parsed.(ed25519.PublicKey)
+	if !ok {
+		return nil, errors.New(`不是Ed25519公钥`)
+	}
+
+	return public, nil
+}
+
+func (j jwtService) CreateToken(claims JwtCustomClaims) (string, error) {
+	now := j.now()
+
+	claims.IssuedAt = now.Unix()
+
+	if claims.ExpiresAt == 0 {
+		claims.ExpiresAt = now.Add(j.config.Expire).Unix()
+	}
+
+	if claims.Issuer == `` {
+		claims.Issuer = j.config.Issuer
+	}
+
+	if claims.Id == `` {
+		id := make([]byte, jtiSize)
+		if _, err := rand.Read(id); err != nil {
+			return ``, errors.Wrap(err, `生成jti`)
+		}
+
+		claims.Id = hex.EncodeToString(id)
+	}
+
+	if j.revocation != nil {
+		version, err := j.revocation.Version(claims.UserID)
+		if err != nil {
+			return ``, errors.Wrap(err, `获取token版本`)
+		}
+
+		claims.TokenVersion = version
+	}
+
+	token := jwt.NewWithClaims(j.signing.method, claims)
+
+	if j.config.SigningKey != `` {
+		token.Header[kidHeader] = j.config.SigningKey
+	}
+
+	return token.SignedString(j.signing.signingKey)
+}
+
+func (j jwtService) ParseToken(tokenString string) (*JwtCustomClaims, error) {
+	claims, err := j.parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = j.validate(claims, j.now().Unix()); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+/*
+RefreshToken 刷新 token,过期后 RefreshWindow 之内仍然可以刷新,不修改 jwt.TimeFunc
+参数:
+*	tokenString	string	旧 token
+返回值:
+*	string     	string	新 token
+*	error      	error 	错误
+*/
+func (j jwtService) RefreshToken(tokenString string) (string, error) {
+	claims, err := j.parse(tokenString)
+	if err != nil {
+		return ``, err
+	}
+
+	// 只要在刷新窗口内,按照过期时间点校验其他条件
+	now := j.now().Unix()
+	if claims.ExpiresAt != 0 && now > claims.ExpiresAt+int64(j.config.RefreshWindow/time.Second) {
+		return ``, TokenExpired
+	}
+
+	if claims.ExpiresAt != 0 && now > claims.ExpiresAt {
+		now = claims.ExpiresAt
+	}
+
+	if err = j.validate(claims, now); err != nil {
+		return ``, err
+	}
+
+	if err = j.Revoke(claims); err != nil {
+		return ``, errors.Wrap(err, `注销旧token`)
+	}
+
+	claims.Id, claims.ExpiresAt = ``, 0
+
+	return j.CreateToken(*claims)
+}
+
+func (j jwtService) Revoke(claims *JwtCustomClaims) error {
+	if j.revocation == nil || claims.Id == `` {
+		return nil
+	}
+
+	expire := time.Unix(claims.ExpiresAt, 0).Add(j.config.RefreshWindow)
+
+	// 没有过期时间的 token 一直有效,按配置的有效期保留注销记录
+	if claims.ExpiresAt == 0 {
+		expire = j.now().Add(j.config.Expire + j.config.RefreshWindow)
+	}
+
+	return j.revocation.Revoke(claims.Id, expire)
+}
+
+func (j jwtService) RevokeUser(userID int64) error {
+	if j.revocation == nil {
+		return errors.New(`没有配置注销记录`)
+	}
+
+	_, err := j.revocation.IncrVersion(userID)
+
+	return err
+}
+
+// parse 验证签名,不验证时间
+func (j jwtService) parse(tokenString string) (*JwtCustomClaims, error) {
+	parser := &jwt.Parser{SkipClaimsValidation: true}
+
+	token, err := parser.ParseWithClaims(tokenString, &JwtCustomClaims{}, j.keyFunc)
+	if err != nil {
+		if convertedErr := convertErr(err); convertedErr != nil {
+			return nil, convertedErr
+		}
+
+		return nil, TokenInvalid
+	}
+
+	if c, ok := token.Claims.(*JwtCustomClaims); ok && token.Valid {
+		return c, nil
+	}
+
+	return nil, TokenInvalid
+}
+
+// keyFunc 根据 kid 选择密钥,算法必须和密钥一致,防止用公钥作为 HMAC 密钥伪造
+func (j jwtService) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header[kidHeader].(string)
+
+	key, exist := j.keys[kid]
+	if !exist {
+		return nil, fmt.Errorf(`未知的kid[%s]`, kid)
+	}
+
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf(`算法[%s]和密钥[%s]不符合`, token.Method.Alg(), kid)
+	}
+
+	return key.verifyKey, nil
+}
+
+/*
+validate 按照指定时间验证 claims 和注销状态
+参数:
+*	claims	*JwtCustomClaims	claims
+*	now   	int64           	时间戳,秒
+返回值:
+*	error 	error           	错误
+*/
+func (j jwtService) validate(claims *JwtCustomClaims, now int64) error {
+	if !claims.VerifyExpiresAt(now, false) {
+		return TokenExpired
+	}
+
+	if !claims.VerifyNotBefore(now, false) {
+		return TokenNotValidYet
+	}
+
+	if j.config.Issuer != `` && !claims.VerifyIssuer(j.config.Issuer, true) {
+		return TokenInvalid
+	}
+
+	if j.revocation == nil {
+		return nil
+	}
+
+	if claims.Id != `` {
+		revoked, err := j.revocation.IsRevoked(claims.Id)
+		if err != nil {
+			return errors.Wrap(err, `查询注销状态`)
+		}
+
+		if revoked {
+			return ErrTokenRevoked
+		}
+	}
+
+	version, err := j.revocation.Version(claims.UserID)
+	if err != nil {
+		return errors.Wrap(err, `获取token版本`)
+	}
+
+	if claims.TokenVersion < version {
+		return ErrTokenRevoked
+	}
+
+	return nil
+}
+
+// SetJWTService 设置 GetUserID 等函数使用的默认服务
+func SetJWTService(service JWTService) {
+	defaultJWTLock.Lock()
+	defer defaultJWTLock.Unlock()
+
+	defaultJWT = service
+}
+
+// GetJWTService 默认服务,未设置时使用旧版 HS256 密钥
+func GetJWTService() JWTService {
+	defaultJWTLock.RLock()
+	defer defaultJWTLock.RUnlock()
+
+	return defaultJWT
+}
+
+type redisJWTRevocation struct {
+	client *redis.Client
+	prefix string
+}
+
+/*
+NewRedisJWTRevocation 基于redis的注销记录
+参数:
+*	client       	*redis.Client	redis客户端
+*	prefix       	string       	key前缀
+返回值:
+*	JWTRevocation	JWTRevocation	注销记录
+*/
+func NewRedisJWTRevocation(client *redis.Client, prefix string) JWTRevocation {
+	return &redisJWTRevocation{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+func (r redisJWTRevocation) revokedKey(jti string) string {
+	return fmt.Sprintf(`%sjwt_revoked_%s`, r.prefix, jti)
+}
+
+func (r redisJWTRevocation) versionKey(userID int64) string {
+	return fmt.Sprintf(`%sjwt_version_%d`, r.prefix, userID)
+}
+
+func (r redisJWTRevocation) Revoke(jti string, expire time.Time) error {
+	ttl := time.Until(expire)
+	if ttl <= 0 {
+		return nil
+	}
+
+	key := r.revokedKey(jti)
+
+	return errors.Wrapf(r.client.Set(jwtCtx, key, 1, ttl).Err(), `REDIS SET %s`, key)
+}
+
+func (r redisJWTRevocation) IsRevoked(jti string) (bool, error) {
+	key := r.revokedKey(jti)
+
+	count, err := r.client.Exists(jwtCtx, key).Result()
+	if err != nil {
+		return false, errors.Wrapf(err, `REDIS EXISTS %s`, key)
+	}
+
+	return count > 0, nil
+}
+
+func (r redisJWTRevocation) Version(userID int64) (int64, error) {
+	key := r.versionKey(userID)
+
+	value, err := r.client.Get(jwtCtx, key).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return 0, nil
+		}
+
+		return 0, errors.Wrapf(err, `REDIS GET %s`, key)
+	}
+
+	version, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, `解析版本[%s]`, value)
+	}
+
+	return version, nil
+}
+
+func (r redisJWTRevocation) IncrVersion(userID int64) (int64, error) {
+	key := r.versionKey(userID)
+
+	version, err := r.client.Incr(jwtCtx, key).Result()
+
+	return version, errors.Wrapf(err, `REDIS INCR %s`, key)
+}
+
+// SigningMethodEd25519 EdDSA 签名,jwt-go v3 不支持
+type SigningMethodEd25519 struct{}
+
+func (m SigningMethodEd25519) Alg() string {
+	return JWTEdDSA
+}
+
+func (m SigningMethodEd25519) Verify(signingString, signature string, key interface{}) error {
+	public, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return jwt.ErrInvalidKeyType
+	}
+
+	sig, err := jwt.DecodeSegment(signature)
+	if err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(public, []byte(signingString), sig) {
+		return jwt.ErrSignatureInvalid
+	}
+
+	return nil
+}
+
+func (m SigningMethodEd25519) Sign(signingString string, key interface{}) (string, error) {
+	private, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return ``, jwt.ErrInvalidKeyType
+	}
+
+	sig, err := private.Sign(rand.Reader, []byte(signingString), crypto.Hash(0))
+	if err != nil {
+		return ``, err
+	}
+
+	return jwt.EncodeSegment(sig), nil
+}
@@ -0,0 +1,213 @@
+package helpers
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryRevocation struct {
+	revoked  map[string]time.Time
+	versions map[int64]int64
+}
+
+func newMemoryRevocation() *memoryRevocation {
+	return &memoryRevocation{revoked: map[string]time.Time{}, versions: map[int64]int64{}}
+}
+
+func (m *memoryRevocation) Revoke(jti string, expire time.Time) error {
+	m.revoked[jti] = expire
+	return nil
+}
+
+func (m *memoryRevocation) IsRevoked(jti string) (bool, error) {
+	_, exist := m.revoked[jti]
+	return exist, nil
+}
+
+func (m *memoryRevocation) Version(userID int64) (int64, error) {
+	return m.versions[userID], nil
+}
+
+func (m *memoryRevocation) IncrVersion(userID int64) (int64, error) {
+	m.versions[userID]++
+	return m.versions[userID], nil
+}
+
+func ed25519Key(t *testing.T) string {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err, `生成密钥`)
+
+	data, err := x509.MarshalPKCS8PrivateKey(private)
+	require.NoError(t, err, `序列化密钥`)
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: `PRIVATE KEY`, Bytes: data}))
+}
+
+func TestJWTService_Rotation(t *testing.T) {
+	oldKey := JWTKey{ID: `old`, Algorithm: JWTHS256, Secret: `old_secret`}
+	newKey := JWTKey{ID: `new`, Algorithm: JWTEdDSA, PrivateKey: ed25519Key(t)}
+
+	oldService, err := NewJWTService(JWTConfig{Issuer: `test`, SigningKey: `old`, Keys: []JWTKey{oldKey}}, nil)
+	require.NoError(t, err)
+
+	newService, err := NewJWTService(JWTConfig{Issuer: `test`, SigningKey: `new`, Keys: []JWTKey{oldKey, newKey}}, nil)
+	require.NoError(t, err)
+
+	oldToken, err := oldService.CreateToken(JwtCustomClaims{UserID: 1, Role: `admin`})
+	require.NoError(t, err)
+
+	claims, err := newService.ParseToken(oldToken)
+	require.NoError(t, err, `轮换后旧密钥签发的token仍然有效`)
+	require.Equal(t, int64(1), claims.UserID)
+	require.Equal(t, `admin`, claims.Role)
+
+	newToken, err := newService.CreateToken(JwtCustomClaims{UserID: 2})
+	require.NoError(t, err)
+
+	_, err = oldService.ParseToken(newToken)
+	require.ErrorIs(t, err, TokenInvalid, `未知kid`)
+
+	claims, err = newService.ParseToken(newToken)
+	require.NoError(t, err)
+	require.Equal(t, `test`, claims.Issuer)
+
+	otherIssuer, err := NewJWTService(JWTConfig{Issuer: `other`, SigningKey: `old`, Keys: []JWTKey{oldKey}}, nil)
+	require.NoError(t, err)
+
+	_, err = otherIssuer.ParseToken(oldToken)
+	require.ErrorIs(t, err, TokenInvalid, `签发者不符合`)
+}
+
+func TestJWTService_AlgorithmConfusion(t *testing.T) {
+	key := JWTKey{Algorithm: JWTHS256, Secret: `secret`}
+
+	service, err := NewJWTService(JWTConfig{Keys: []JWTKey{key}}, nil)
+	require.NoError(t, err)
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS512, JwtCustomClaims{UserID: 1}).SignedString([]byte(`secret`))
+	require.NoError(t, err)
+
+	_, err = service.ParseToken(token)
+	require.ErrorIs(t, err, TokenInvalid, `算法和密钥不符合`)
+
+	_, err = NewJWTService(JWTConfig{Keys: []JWTKey{{Algorithm: `none`}}}, nil)
+	require.Error(t, err, `不支持的算法`)
+}
+
+func TestJWTService_RefreshAndRevoke(t *testing.T) {
+	revocation := newMemoryRevocation()
+
+	service, err := newJWTService(JWTConfig{Keys: []JWTKey{{Algorithm: JWTHS256, Secret: `secret`}}, Expire: time.Minute}, revocation)
+	require.NoError(t, err)
+
+	now := time.Now()
+	service.now = func() time.Time { return now }
+
+	token, err := service.CreateToken(JwtCustomClaims{UserID: 1})
+	require.NoError(t, err)
+
+	service.now = func() time.Time { return now.Add(time.Minute * 2) }
+
+	_, err = service.ParseToken(token)
+	require.ErrorIs(t, err, TokenExpired)
+
+	refreshed, err := service.RefreshToken(token)
+	require.NoError(t, err, `刷新窗口内可以刷新`)
+	require.WithinDuration(t, time.Now(), jwt.TimeFunc(), time.Second, `不修改jwt.TimeFunc`)
+
+	_, err = service.RefreshToken(token)
+	require.ErrorIs(t, err, ErrTokenRevoked, `旧token刷新后注销`)
+
+	claims, err := service.ParseToken(refreshed)
+	require.NoError(t, err)
+	require.Equal(t, now.Add(time.Minute*3).Unix(), claims.ExpiresAt)
+
+	require.NoError(t, service.Revoke(claims))
+
+	_, err = service.ParseToken(refreshed)
+	require.ErrorIs(t, err, ErrTokenRevoked, `退出登录`)
+
+	service.now = func() time.Time { return now.Add(time.Hour) }
+
+	_, err = service.RefreshToken(refreshed)
+	require.ErrorIs(t, err, TokenExpired, `超过刷新窗口`)
+}
+
+func TestJWTService_RevokeUser(t *testing.T) {
+	revocation := newMemoryRevocation()
+
+	service, err := NewJWTService(JWTConfig{Keys: []JWTKey{{Algorithm: JWTHS256, Secret: `secret`}}}, revocation)
+	require.NoError(t, err)
+
+	tokens := make([]string, 0, 2)
+
+	for i := 0; i < 2; i++ {
+		token, createErr := service.CreateToken(JwtCustomClaims{UserID: 1})
+		require.NoError(t, createErr, fmt.Sprintf(`第%d个`, i))
+
+		tokens = append(tokens, token)
+	}
+
+	require.NoError(t, service.RevokeUser(1))
+
+	for _, token := range tokens {
+		_, err = service.ParseToken(token)
+		require.ErrorIs(t, err, ErrTokenRevoked, `用户所有token注销`)
+	}
+
+	token, err := service.CreateToken(JwtCustomClaims{UserID: 1})
+	require.NoError(t, err)
+
+	_, err = service.ParseToken(token)
+	require.NoError(t, err, `之后签发的token有效`)
+}
+
+func TestJWT_Legacy(t *testing.T) {
+	j := NewJWT()
+
+	token, err := j.CreateToken(JwtCustomClaims{UserID: 3, StandardClaims: jwt.StandardClaims{ExpiresAt: time.Now().Add(time.Minute).Unix()}})
+	require.NoError(t, err)
+
+	claims, err := GetJWTService().ParseToken(token)
+	require.NoError(t, err, `默认服务兼容旧版token`)
+	require.Equal(t, int64(3), claims.UserID)
+
+	refreshed, err := j.RefreshToken(token)
+	require.NoError(t, err)
+
+	_, err = j.ParseToken(refreshed)
+	require.NoError(t, err)
+}
+
+func TestJWTService_RevokeWithoutExpire(t *testing.T) {
+	revocation := newMemoryRevocation()
+
+	service, err := newJWTService(JWTConfig{Keys: []JWTKey{{Algorithm: JWTHS256, Secret: `secret`}}, Expire: time.Minute}, revocation)
+	require.NoError(t, err)
+
+	now := time.Now()
+	service.now = func() time.Time { return now }
+
+	require.NoError(t, service.Revoke(&JwtCustomClaims{StandardClaims: jwt.StandardClaims{Id: `legacy`}}))
+	require.Equal(t, now.Add(time.Minute*2), revocation.revoked[`legacy`], `没有过期时间时按有效期保留`)
+}
+
+func TestJWT_EmptySigningKey(t *testing.T) {
+	target := &JWT{}
+
+	require.NotPanics(t, func() {
+		_, err := target.ParseToken(`token`)
+		require.Error(t, err, `密钥为空`)
+
+		_, err = target.RefreshToken(`token`)
+		require.Error(t, err, `密钥为空`)
+	})
+}
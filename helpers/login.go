@@ -2,7 +2,6 @@ package helpers
 
 import (
 	"context"
-	"time"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
@@ -21,17 +20,19 @@ func CtxError(ctx *gin.Context, err error) {
 	_ = ctx.Error(errors.Wrapf(err, "url:%s", ctx.Request.URL.String()))
 }
 
-// GetUserID 从session登录的用户信息
+// GetUserID 从session登录的用户信息,优先使用认证中间件放入的 claims
 func GetUserID(ctx *gin.Context) (int64, error) {
+	if claims, err := Getclaims(ctx); err == nil {
+		return claims.UserID, nil
+	}
+
 	token := ctx.GetHeader("token")
 
 	if token == `` {
 		return 0, ErrUnknownType
 	}
 
-	j := NewJWT()
-
-	clamis, err := j.ParseToken(token)
+	clamis, err := GetJWTService().ParseToken(token)
 	if err != nil {
 		return 0, errors.Wrap(err, "未获取到token")
 	}
@@ -67,6 +68,8 @@ type JwtCustomClaims struct {
 	Mobile     string // 手机号
 	Role       string // 角色
 	CreateTime int64
+	// TokenVersion 签发时用户的 token 版本,小于当前版本的 token 已注销
+	TokenVersion int64 `json:",omitempty"`
 	jwt.StandardClaims
 }
 
@@ -104,47 +107,22 @@ func convertErr(err error) error {
 	return nil
 }
 
+// ParseToken 解析 token,使用旧版没有 kid 的 HS256 密钥
 func (j *JWT) ParseToken(tokenString string) (*JwtCustomClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &JwtCustomClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return j.SigningKey, nil
-	})
+	service, err := legacyJWTService(j.SigningKey)
 	if err != nil {
-		if convertedErr := convertErr(err); convertedErr != nil {
-			return nil, convertedErr
-		}
-	}
-
-	if token == nil {
-		return nil, TokenInvalid
-	}
-	// 解析到Claims 构造中
-	if c, ok := token.Claims.(*JwtCustomClaims); ok && token.Valid {
-		return c, nil
+		return nil, errors.Wrap(err, `旧版JWT配置错误`)
 	}
 
-	return nil, TokenInvalid
+	return service.ParseToken(tokenString)
 }
 
-// RefreshToken 更新 token
+// RefreshToken 更新 token,不再修改 jwt.TimeFunc
 func (j *JWT) RefreshToken(tokenString string) (string, error) {
-	jwt.TimeFunc = func() time.Time {
-		return time.Unix(0, 0)
-	}
-
-	token, err := jwt.ParseWithClaims(tokenString, &JwtCustomClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return j.SigningKey, nil
-	})
+	service, err := legacyJWTService(j.SigningKey)
 	if err != nil {
-		return "", err
-	}
-
-	if c, ok := token.Claims.(*JwtCustomClaims); ok && token.Valid {
-		jwt.TimeFunc = time.Now
-
-		c.StandardClaims.ExpiresAt = time.Now().Add(1 * time.Hour).Unix()
-
-		return j.CreateToken(*c)
+		return ``, errors.Wrap(err, `旧版JWT配置错误`)
 	}
 
-	return "", TokenInvalid
+	return service.RefreshToken(tokenString)
 }
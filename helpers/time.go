@@ -210,6 +210,52 @@ func GetMonthByTime(t int64) int {
 	return now.Year()*after2Mask + int(now.Month())
 }
 
+/*GetHourByTime 获取时间戳在默认时区的int类型小时
+参数:
+*	t  	int64	时间戳
+返回值:
+*	int	int  	int类型的小时 2006010215的格式
+*/
+func GetHourByTime(t int64) int {
+	now := time.Unix(t, 0).In(defaultLocation)
+
+	return (now.Year()*before4Mask+int(now.Month())*after2Mask+now.Day())*after2Mask + now.Hour()
+}
+
+/*GetWeekByTime 获取时间戳在默认时区所在周(ISO周,周一开始)的周一日期
+参数:
+*	t  	int64	时间戳
+返回值:
+*	int	int  	int类型的周一日期 20060102的格式
+*/
+func GetWeekByTime(t int64) int {
+	monday := BeginningOfMonday(time.Unix(t, 0).In(defaultLocation))
+
+	return monday.Year()*before4Mask + int(monday.Month())*after2Mask + monday.Day()
+}
+
+/*GetQuarterByTime 获取时间戳在默认时区的int类型季度
+参数:
+*	t  	int64	时间戳
+返回值:
+*	int	int  	int类型的季度 20061的格式，4位年1位季度
+*/
+func GetQuarterByTime(t int64) int {
+	now := time.Unix(t, 0).In(defaultLocation)
+
+	return now.Year()*quarterMask + (int(now.Month())-1)/monthsInQuarter + 1
+}
+
+/*GetYearByTime 获取时间戳在默认时区的年
+参数:
+*	t  	int64	时间戳
+返回值:
+*	int	int  	年
+*/
+func GetYearByTime(t int64) int {
+	return time.Unix(t, 0).In(defaultLocation).Year()
+}
+
 /*GetDatesByRange 通过时间戳获取中间的日期
 参数:
 *	from 	int64	开始时间戳,单位秒,结果包括start所在的天
@@ -289,8 +335,10 @@ func (t Time) Unix() int64 {
 }
 
 const (
-	before4Mask = 10000 // 前4位
-	after2Mask  = 100   // 后2位
+	before4Mask     = 10000 // 前4位
+	after2Mask      = 100   // 后2位
+	quarterMask     = 10    // 季度占1位
+	monthsInQuarter = 3     // 每季度月数
 )
 
 /*DataCal 日期增加或者减少天
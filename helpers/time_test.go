@@ -86,3 +86,21 @@ func TestRange_UnMarshal(t *testing.T) {
 	require.EqualValues(t, 4, result.B.Max)
 
 }
+
+func TestGetSlotByTime(t *testing.T) {
+	SetTimeZone(GetBeiJin())
+
+	now := time.Date(2021, 9, 18, 15, 4, 5, 0, GetBeiJin()).Unix() // 周六
+
+	require.EqualValues(t, 2021091815, GetHourByTime(now), `小时`)
+	require.EqualValues(t, 20210913, GetWeekByTime(now), `周一`)
+	require.EqualValues(t, 20213, GetQuarterByTime(now), `季度`)
+	require.EqualValues(t, 2021, GetYearByTime(now), `年`)
+
+	sunday := time.Date(2021, 9, 19, 23, 0, 0, 0, GetBeiJin()).Unix()
+	require.EqualValues(t, 20210913, GetWeekByTime(sunday), `周日属于同一周`)
+
+	january := time.Date(2022, 1, 1, 0, 0, 0, 0, GetBeiJin()).Unix()
+	require.EqualValues(t, 20221, GetQuarterByTime(january), `一季度`)
+	require.EqualValues(t, 20211227, GetWeekByTime(january), `跨年的周`)
+}
@@ -2,6 +2,7 @@ package helpers
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"reflect"
 	"strings"
@@ -10,10 +11,11 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
 	"github.com/pkg/errors"
+	"github.com/uber/jaeger-client-go"
 	jaegercfg "github.com/uber/jaeger-client-go/config"
 	"go.uber.org/zap/buffer"
-	"gorm.io/gorm"
 )
 
 const (
@@ -21,24 +23,99 @@ const (
 	spanGinKey string  = `span-gin`
 )
 
-func loadTracer(serviceName string) (tracer *Tracer, err error) {
+const (
+	// TracerExporterJaeger 通过UDP发送到 jaeger-agent
+	TracerExporterJaeger = `jaeger`
+	// TracerExporterJaegerHTTP 通过HTTP发送 jaeger thrift,接收方可以是 jaeger-collector
+	// 或者 OpenTelemetry Collector 的 jaeger receiver(thrift_http),不是 OTLP
+	TracerExporterJaegerHTTP = `jaegerHTTP`
+	// TracerExporterOTLP 通过 OTLP/HTTP(JSON编码) 发送到 OpenTelemetry Collector,默认地址 http://localhost:4318/v1/traces
+	TracerExporterOTLP = `otlp`
+
+	defaultCollectorEndpoint = `http://localhost:14268/api/traces`
+)
+
+// TracerConfig 链路追踪配置
+type TracerConfig struct {
+	ServiceName string                  `json:"serviceName"` // 服务名
+	Exporter    string                  `json:"exporter"`    // TracerExporterJaeger(默认)、TracerExporterJaegerHTTP 或者 TracerExporterOTLP
+	Endpoint    string                  `json:"endpoint"`    // jaeger-agent 的 host:port 或者 Collector 的地址,为空时使用默认值
+	Headers     map[string]string       `json:"headers"`     // 发送到 Collector 时附加的HTTP头,例如认证
+	Propagation []string                `json:"propagation"` // 传播格式,TracePropagationJaeger、TracePropagationW3C,为空时同时使用
+	Sampler     TraceSampler            `json:"sampler"`     // 默认采样,为空时全部采样
+	Routes      map[string]TraceSampler `json:"routes"`      // 路由前缀->采样,覆盖默认采样,最长前缀优先
+}
+
+/*
+LoadTracer 根据配置初始化 tracer
+参数:
+*	config 	TracerConfig	配置
+返回值:
+*	tracer 	*Tracer     	tracer
+*	err    	error       	错误
+*/
+func LoadTracer(config TracerConfig) (tracer *Tracer, err error) {
 	cfg := jaegercfg.Configuration{
-		ServiceName: serviceName,
-		Sampler: &jaegercfg.SamplerConfig{
-			Type:  "const",
-			Param: 1,
-		},
+		ServiceName: config.ServiceName,
+		Reporter:    &jaegercfg.ReporterConfig{},
+	}
+
+	var options []jaegercfg.Option
+
+	switch config.Exporter {
+	case ``, TracerExporterJaeger:
+		cfg.Reporter.LocalAgentHostPort = config.Endpoint
+	case TracerExporterJaegerHTTP:
+		cfg.Reporter.CollectorEndpoint = config.Endpoint
+		cfg.Reporter.HTTPHeaders = config.Headers
+
+		if cfg.Reporter.CollectorEndpoint == `` {
+			cfg.Reporter.CollectorEndpoint = defaultCollectorEndpoint
+		}
+	case TracerExporterOTLP:
+		options = append(options, jaegercfg.Reporter(newOTLPReporter(config.ServiceName, config.Endpoint, config.Headers)))
+	default:
+		return nil, fmt.Errorf(`不支持的exporter[%s]`, config.Exporter)
+	}
+
+	var (
+		httpPropagator, textPropagator *tracePropagator
+		sampler                        jaeger.Sampler
+	)
+
+	if sampler, err = newTraceSampler(config.Sampler, config.Routes); err != nil {
+		return nil, errors.Wrap(err, `采样`)
+	}
+
+	if httpPropagator, err = newTracePropagator(config.Propagation, true); err != nil {
+		return nil, err
+	}
+
+	if textPropagator, err = newTracePropagator(config.Propagation, false); err != nil {
+		return nil, err
 	}
 
 	tracer = &Tracer{}
 
-	if tracer.Tracer, tracer.Closer, err = cfg.NewTracer(); err != nil {
+	options = append(options,
+		jaegercfg.Sampler(sampler),
+		jaegercfg.Injector(opentracing.HTTPHeaders, httpPropagator),
+		jaegercfg.Extractor(opentracing.HTTPHeaders, httpPropagator),
+		jaegercfg.Injector(opentracing.TextMap, textPropagator),
+		jaegercfg.Extractor(opentracing.TextMap, textPropagator),
+	)
+
+	if tracer.Tracer, tracer.Closer, err = cfg.NewTracer(options...); err != nil {
 		return nil, errors.Wrap(err, `初始化tracer`)
 	}
 
 	return tracer, nil
 }
 
+func loadTracer(serviceName string) (tracer *Tracer, err error) {
+	return LoadTracer(TracerConfig{ServiceName: serviceName})
+}
+
 type Tracer struct {
 	Tracer opentracing.Tracer
 	Closer io.Closer
@@ -169,50 +246,7 @@ func (s *Span) SetTag(key string, value interface{}) {
 	s.span.SetTag(key, value)
 }
 
-// GormTracing gorm 追踪
-type GormTracing struct {
-}
-
-/*BeforeFind gorm查询前钩子设置span
-参数:
-*	tx	*gorm.DB	gorm
-返回值:
-*/
-func (g GormTracing) BeforeFind(tx *gorm.DB) {
-	ctx := tx.Statement.Context
-
-	if ctx == nil {
-		return
-	}
-
-	span := StartChild(GetSpanFromCtx(ctx), `mysql-query`)
-
-	tx.Statement.Context = PutSpanInCtx(span, ctx)
-}
-
-/*AfterFind gorm查询后钩子把生成的sql语句放入tag中
-参数:
-*	tx	*gorm.DB	gorm
-返回值:
-*/
-func (g GormTracing) AfterFind(tx *gorm.DB) {
-	ctx := tx.Statement.Context
-
-	if ctx == nil {
-		return
-	}
-
-	span := GetSpanFromCtx(ctx)
-	if span == nil {
-		return
-	}
-
-	span.SetTag(`stmt`, tx.Dialector.Explain(tx.Statement.SQL.String(), tx.Statement.Vars...))
-
-	span.FinishSpan(tx.Error)
-}
-
-func getBody(ctx *gin.Context) string {
+func getBody(ctx *gin.Context, redactor *traceRedactor) string {
 	data := &buffer.Buffer{}
 
 	if ctx.Request.Body != nil {
@@ -220,29 +254,55 @@ func getBody(ctx *gin.Context) string {
 	}
 
 	body := data.String()
-	displayBody := body
-
-	if len(body) > 4*1024 {
-		displayBody = `大于4K,请查看日志`
-	}
 
 	ctx.Request.Body = io.NopCloser(strings.NewReader(body))
 
-	return displayBody
+	return redactor.body(ctx.ContentType(), data.Bytes())
+}
+
+// TraceOption 请求追踪选项
+type TraceOption struct {
+	IgnorePrefix []string       // 以这些前缀开头的路径使用前缀作为 span 名称
+	Headers      []string       // 记录为标签的请求头,为空时记录 token
+	Redaction    TraceRedaction // 脱敏规则,配置的请求头和字段追加到 DefaultTraceRedaction 的规则之后,默认规则总是生效
 }
 
 var once sync.Once
 
 func Trace(tracer opentracing.Tracer, ignorePrefix ...string) func(ctx *gin.Context) {
+	return TraceWithOption(tracer, TraceOption{IgnorePrefix: ignorePrefix})
+}
+
+/*
+TraceWithOption 请求追踪,请求头、查询参数和请求体按照脱敏规则记录
+参数:
+*	tracer                	opentracing.Tracer    	tracer
+*	option                	TraceOption           	选项
+返回值:
+*	func(ctx *gin.Context)	func(ctx *gin.Context)	中间件
+*/
+func TraceWithOption(tracer opentracing.Tracer, option TraceOption) func(ctx *gin.Context) {
 	once.Do(func() {
 		opentracing.SetGlobalTracer(tracer)
 	})
 
+	if len(option.Headers) == 0 {
+		option.Headers = []string{`token`}
+	}
+
+	// 只配置了请求头或者字段时,也不能记录原始的 token、验证码和私钥
+	defaultRedaction := DefaultTraceRedaction()
+	option.Redaction.Headers = append(defaultRedaction.Headers, option.Redaction.Headers...)
+	option.Redaction.Fields = append(defaultRedaction.Fields, option.Redaction.Fields...)
+
+	redactor := newTraceRedactor(option.Redaction)
+
 	return func(ctx *gin.Context) {
 		if tracer != nil {
-			visit := ctx.Request.URL.String()
+			// 不使用完整URL,查询参数可能包含敏感信息
+			visit := ctx.Request.URL.Path
 
-			for _, prefix := range ignorePrefix {
+			for _, prefix := range option.IgnorePrefix {
 				if strings.HasPrefix(visit, prefix) {
 					visit = prefix
 					break
@@ -251,19 +311,30 @@ func Trace(tracer opentracing.Tracer, ignorePrefix ...string) func(ctx *gin.Cont
 
 			start := time.Now()
 
-			span := tracer.StartSpan(visit, opentracing.StartTime(start), opentracing.Tags{
-				`token`:      ctx.GetHeader(`token`),
+			tags := opentracing.Tags{
 				`method`:     ctx.Request.Method,
 				`path`:       ctx.Request.URL.Path,
-				`query`:      ctx.Request.URL.RawQuery,
+				`query`:      redactor.query(ctx.Request.URL.RawQuery),
 				`ip`:         ctx.ClientIP(),
 				`user-agent`: ctx.Request.UserAgent(),
 				`req-size`:   ctx.Request.ContentLength,
-			})
+			}
 
-			span.SetTag(`body`, getBody(ctx))
+			for _, header := range option.Headers {
+				tags[header] = redactor.header(header, ctx.GetHeader(header))
+			}
+
+			// 上游没有传递时 RPCServerOption 创建根 span
+			span := tracer.StartSpan(visit, ext.RPCServerOption(ExtractHTTP(tracer, ctx.Request.Header)), opentracing.StartTime(start), tags)
+
+			// 未采样时不读取请求体
+			if jaegerContext, ok := span.Context().(jaeger.SpanContext); !ok || jaegerContext.IsSampled() {
+				span.SetTag(`body`, getBody(ctx, redactor))
+			}
 
 			PutSpanInGin(span, ctx)
+			// 使用 ctx.Request.Context() 调用下游时可以通过 NewTracingTransport 传递
+			ctx.Request = ctx.Request.WithContext(PutSpanInCtx(&Span{span: span}, ctx.Request.Context()))
 			ctx.Next()
 
 			duration := time.Since(start)
@@ -0,0 +1,131 @@
+package helpers
+
+import (
+	"errors"
+
+	"github.com/opentracing/opentracing-go/ext"
+	"gorm.io/gorm"
+)
+
+const (
+	gormSpanKey        = `tracing:span`
+	gormCallbackPrefix = `tracing:`
+)
+
+// GormTracing gorm 追踪插件,通过 db.Use(&GormTracing{}) 注册,追踪所有的增删改查和原生SQL
+type GormTracing struct {
+	RedactValues bool // 为真时语句只记录占位符,不记录参数
+}
+
+func (g GormTracing) Name() string {
+	return `tracing`
+}
+
+/*
+Initialize 注册回调,实现 gorm.Plugin
+参数:
+*	db   	*gorm.DB	gorm
+返回值:
+*	error	error   	错误
+*/
+func (g GormTracing) Initialize(db *gorm.DB) error {
+	callback := db.Callback()
+
+	// gorm 没有导出回调的类型,使用方法值
+	callbacks := []struct {
+		name   string
+		before func(name string, fn func(*gorm.DB)) error
+		after  func(name string, fn func(*gorm.DB)) error
+	}{
+		{name: `create`, before: callback.Create().Before(`*`).Register, after: callback.Create().After(`*`).Register},
+		{name: `query`, before: callback.Query().Before(`*`).Register, after: callback.Query().After(`*`).Register},
+		{name: `update`, before: callback.Update().Before(`*`).Register, after: callback.Update().After(`*`).Register},
+		{name: `delete`, before: callback.Delete().Before(`*`).Register, after: callback.Delete().After(`*`).Register},
+		{name: `row`, before: callback.Row().Before(`*`).Register, after: callback.Row().After(`*`).Register},
+		{name: `raw`, before: callback.Raw().Before(`*`).Register, after: callback.Raw().After(`*`).Register},
+	}
+
+	for _, item := range callbacks {
+		if err := item.before(gormCallbackPrefix+`before_`+item.name, g.before(`mysql-`+item.name)); err != nil {
+			return err
+		}
+
+		if err := item.after(gormCallbackPrefix+`after_`+item.name, g.after); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// before 创建子 span,父 span 来自 GetSpanFromCtx,没有时不追踪
+func (g GormTracing) before(operation string) func(tx *gorm.DB) {
+	return func(tx *gorm.DB) {
+		ctx := tx.Statement.Context
+
+		if ctx == nil {
+			return
+		}
+
+		if span := StartChild(GetSpanFromCtx(ctx), operation); span != nil {
+			tx.InstanceSet(gormSpanKey, span)
+		}
+	}
+}
+
+/*
+after 记录表名、语句、影响行数和错误并结束 span
+参数:
+*	tx	*gorm.DB	gorm
+返回值:
+*/
+func (g GormTracing) after(tx *gorm.DB) {
+	value, exist := tx.InstanceGet(gormSpanKey)
+	if !exist {
+		return
+	}
+
+	span, ok := value.(*Span)
+	if !ok || span == nil {
+		return
+	}
+
+	statement := tx.Statement.SQL.String()
+	if !g.RedactValues {
+		statement = tx.Dialector.Explain(statement, tx.Statement.Vars...)
+	}
+
+	ext.DBType.Set(span.span, tx.Dialector.Name())
+	span.SetTag(`db.table`, tx.Statement.Table)
+	span.SetTag(`db.rows_affected`, tx.Statement.RowsAffected)
+	span.SetTag(`stmt`, statement)
+
+	if tx.Error != nil && !errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+		ext.Error.Set(span.span, true)
+	}
+
+	span.FinishSpan(tx.Error)
+
+	// 模型仍然嵌入 GormTracing 时 AfterFind 和回调都会调用,只结束一次
+	tx.InstanceSet(gormSpanKey, nil)
+}
+
+/*
+BeforeFind gorm查询前钩子设置span,已由 Initialize 注册的回调代替,保留兼容
+参数:
+*	tx	*gorm.DB	gorm
+返回值:
+*/
+func (g GormTracing) BeforeFind(tx *gorm.DB) {
+	g.before(`mysql-query`)(tx)
+}
+
+/*
+AfterFind gorm查询后钩子把生成的sql语句放入tag中,已由 Initialize 注册的回调代替,保留兼容
+参数:
+*	tx	*gorm.DB	gorm
+返回值:
+*/
+func (g GormTracing) AfterFind(tx *gorm.DB) {
+	g.after(tx)
+}
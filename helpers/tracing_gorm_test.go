@@ -0,0 +1,78 @@
+package helpers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+type tracingRecord struct {
+	ID    int64
+	Value string
+}
+
+func TestGormTracing(t *testing.T) {
+	for _, redact := range []bool{false, true} {
+		db, openErr := gorm.Open(mysql.New(mysql.Config{DSN: `root:@tcp(127.0.0.1:1)/test`, SkipInitializeWithVersion: true}), &gorm.Config{
+			DryRun:                 true,
+			SkipDefaultTransaction: true,
+			DisableAutomaticPing:   true,
+		})
+		require.NoError(t, openErr)
+		require.NoError(t, db.Use(&GormTracing{RedactValues: redact}))
+
+		mock := mocktracer.New()
+		parent := &Span{span: mock.StartSpan(`parent`)}
+		tx := db.WithContext(PutSpanInCtx(parent, context.Background()))
+
+		require.NoError(t, tx.Create(&tracingRecord{Value: `secret`}).Error)
+		require.NoError(t, tx.Where(`value = ?`, `secret`).Find(&[]tracingRecord{}).Error)
+		require.NoError(t, tx.Model(&tracingRecord{}).Where(`id = ?`, 1).Update(`value`, `secret`).Error)
+		require.NoError(t, tx.Where(`value = ?`, `secret`).Delete(&tracingRecord{}).Error)
+		require.NoError(t, tx.Exec(`update tracing_records set value = ?`, `secret`).Error)
+		_, _ = tx.Model(&tracingRecord{}).Where(`value = ?`, `secret`).Rows()
+
+		require.NoError(t, db.Create(&tracingRecord{Value: `secret`}).Error, `没有父span时不追踪`)
+
+		spans := mock.FinishedSpans()
+		operations := make([]string, 0, len(spans))
+
+		for _, span := range spans {
+			operations = append(operations, span.OperationName)
+			require.Equal(t, parent.span.Context().(mocktracer.MockSpanContext).SpanID, span.ParentID, `父span`)
+
+			stmt, _ := span.Tag(`stmt`).(string)
+			require.Equal(t, !redact, strings.Contains(stmt, `secret`), stmt)
+
+			if span.OperationName != `mysql-raw` {
+				require.Equal(t, `tracing_records`, span.Tag(`db.table`))
+			}
+		}
+
+		require.Equal(t, []string{`mysql-create`, `mysql-query`, `mysql-update`, `mysql-delete`, `mysql-raw`, `mysql-row`}, operations)
+	}
+}
+
+func TestGormTracing_AfterFind(t *testing.T) {
+	db, openErr := gorm.Open(mysql.New(mysql.Config{DSN: `root:@tcp(127.0.0.1:1)/test`, SkipInitializeWithVersion: true}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+	})
+	require.NoError(t, openErr)
+
+	mock := mocktracer.New()
+	parent := &Span{span: mock.StartSpan(`parent`)}
+	tx := db.WithContext(PutSpanInCtx(parent, context.Background())).Model(&tracingRecord{})
+
+	target := GormTracing{}
+	target.before(`mysql-query`)(tx)
+	target.AfterFind(tx)
+	target.after(tx)
+
+	require.Len(t, mock.FinishedSpans(), 1, `旧的钩子和回调只结束一次`)
+}
@@ -0,0 +1,342 @@
+package helpers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/uber/jaeger-client-go"
+)
+
+const (
+	defaultOTLPEndpoint = `http://localhost:4318/v1/traces`
+	otlpQueueSize       = 1000 // 待发送的span上限,超过时丢弃
+	otlpBatchSize       = 100  // 每次最多发送的span数量
+	otlpFlushInterval   = time.Second
+	otlpSendTimeout     = 10 * time.Second
+	otlpScopeName       = `github.com/fighterlyt/common/helpers`
+)
+
+// OTLP span kind 和状态码,见 opentelemetry-proto trace.proto
+const (
+	otlpKindInternal = 1
+	otlpKindServer   = 2
+	otlpKindClient   = 3
+	otlpKindProducer = 4
+	otlpKindConsumer = 5
+	otlpStatusError  = 2
+)
+
+// otlpReporter jaeger.Reporter 的 OTLP 实现,span 按 OTLP/HTTP 的 JSON 编码批量发送到 OpenTelemetry Collector
+type otlpReporter struct {
+	endpoint string
+	headers  map[string]string
+	resource otlpResource
+	client   *http.Client
+	logger   jaeger.Logger
+	queue    chan otlpSpan
+	closed   chan struct{}
+	done     chan struct{}
+	once     sync.Once
+}
+
+/*
+newOTLPReporter 创建 OTLP reporter 并开始后台发送
+参数:
+*	serviceName  	string           	服务名,作为 resource 的 service.name
+*	endpoint     	string           	Collector 的 /v1/traces 地址,为空时使用默认值
+*	headers      	map[string]string	附加的HTTP头,例如认证
+返回值:
+*	*otlpReporter	*otlpReporter    	reporter
+*/
+func newOTLPReporter(serviceName, endpoint string, headers map[string]string) *otlpReporter {
+	if endpoint == `` {
+		endpoint = defaultOTLPEndpoint
+	}
+
+	reporter := &otlpReporter{
+		endpoint: endpoint,
+		headers:  headers,
+		resource: otlpResource{Attributes: []otlpAttribute{newOTLPAttribute(`service.name`, serviceName)}},
+		client:   &http.Client{Timeout: otlpSendTimeout},
+		logger:   jaeger.StdLogger,
+		queue:    make(chan otlpSpan, otlpQueueSize),
+		closed:   make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	go reporter.run()
+
+	return reporter
+}
+
+// Report span 结束时调用,不能阻塞业务,队列满时丢弃
+func (o *otlpReporter) Report(span *jaeger.Span) {
+	select {
+	case o.queue <- newOTLPSpan(span):
+	default:
+		o.logger.Error(`otlp发送队列已满,丢弃span`)
+	}
+}
+
+// Close 发送剩余的span后返回
+func (o *otlpReporter) Close() {
+	o.once.Do(func() {
+		close(o.closed)
+	})
+
+	<-o.done
+}
+
+func (o *otlpReporter) run() {
+	defer close(o.done)
+
+	ticker := time.NewTicker(otlpFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]otlpSpan, 0, otlpBatchSize)
+
+	flush := func() {
+		if len(batch) > 0 {
+			o.send(batch)
+			batch = make([]otlpSpan, 0, otlpBatchSize)
+		}
+	}
+
+	for {
+		select {
+		case span := <-o.queue:
+			if batch = append(batch, span); len(batch) >= otlpBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-o.closed:
+			for {
+				select {
+				case span := <-o.queue:
+					if batch = append(batch, span); len(batch) >= otlpBatchSize {
+						flush()
+					}
+				default:
+					flush()
+
+					return
+				}
+			}
+		}
+	}
+}
+
+/*
+send 发送一批span,失败时记录日志,不重试
+参数:
+*	spans	[]otlpSpan	span
+返回值:
+*/
+func (o *otlpReporter) send(spans []otlpSpan) {
+	body, err := json.Marshal(otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   o.resource,
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: otlpScopeName}, Spans: spans}},
+	}}})
+	if err != nil {
+		o.logger.Error(`otlp序列化失败:` + err.Error())
+
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), otlpSendTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.endpoint, bytes.NewReader(body))
+	if err != nil {
+		o.logger.Error(`otlp创建请求失败:` + err.Error())
+
+		return
+	}
+
+	req.Header.Set(`Content-Type`, `application/json`)
+
+	for key, value := range o.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		o.logger.Error(`otlp发送失败:` + err.Error())
+
+		return
+	}
+
+	_ = resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		o.logger.Error(fmt.Sprintf(`otlp发送失败,状态码[%d]`, resp.StatusCode))
+	}
+}
+
+// otlpRequest ExportTraceServiceRequest 的JSON编码
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+// otlpSpan traceId 和 spanId 按 OTLP/JSON 的规定使用hex编码,纳秒时间使用字符串
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Events            []otlpEvent     `json:"events,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string          `json:"timeUnixNano"`
+	Name         string          `json:"name"`
+	Attributes   []otlpAttribute `json:"attributes,omitempty"`
+}
+
+type otlpStatus struct {
+	Code int `json:"code,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    string   `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+/*
+newOTLPSpan 转换 jaeger span,在 Report 中同步转换,之后不再访问原始span
+参数:
+*	span    	*jaeger.Span	span
+返回值:
+*	otlpSpan	otlpSpan    	OTLP span
+*/
+func newOTLPSpan(span *jaeger.Span) otlpSpan {
+	spanContext := span.SpanContext()
+	traceID := spanContext.TraceID()
+	start := span.StartTime()
+
+	result := otlpSpan{
+		TraceID:           fmt.Sprintf(`%016x%016x`, traceID.High, traceID.Low),
+		SpanID:            fmt.Sprintf(`%016x`, uint64(spanContext.SpanID())),
+		Name:              span.OperationName(),
+		Kind:              otlpKindInternal,
+		StartTimeUnixNano: strconv.FormatInt(start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(start.Add(span.Duration()).UnixNano(), 10),
+	}
+
+	if parent := spanContext.ParentID(); parent != 0 {
+		result.ParentSpanID = fmt.Sprintf(`%016x`, uint64(parent))
+	}
+
+	for key, value := range span.Tags() {
+		switch key {
+		case string(ext.SpanKind):
+			result.Kind = otlpKind(value)
+		case string(ext.Error):
+			if failed, ok := value.(bool); ok && failed {
+				result.Status.Code = otlpStatusError
+			}
+		}
+
+		result.Attributes = append(result.Attributes, newOTLPAttribute(key, value))
+	}
+
+	for _, record := range span.Logs() {
+		event := otlpEvent{TimeUnixNano: strconv.FormatInt(record.Timestamp.UnixNano(), 10), Name: `log`}
+
+		for _, field := range record.Fields {
+			if field.Key() == `event` {
+				event.Name = fmt.Sprint(field.Value())
+			}
+
+			event.Attributes = append(event.Attributes, newOTLPAttribute(field.Key(), field.Value()))
+		}
+
+		result.Events = append(result.Events, event)
+	}
+
+	return result
+}
+
+func otlpKind(value interface{}) int {
+	switch fmt.Sprint(value) {
+	case string(ext.SpanKindRPCServerEnum):
+		return otlpKindServer
+	case string(ext.SpanKindRPCClientEnum):
+		return otlpKindClient
+	case string(ext.SpanKindProducerEnum):
+		return otlpKindProducer
+	case string(ext.SpanKindConsumerEnum):
+		return otlpKindConsumer
+	default:
+		return otlpKindInternal
+	}
+}
+
+func newOTLPAttribute(key string, value interface{}) otlpAttribute {
+	attribute := otlpAttribute{Key: key}
+
+	switch v := value.(type) {
+	case bool:
+		attribute.Value.BoolValue = &v
+	case int:
+		attribute.Value.IntValue = strconv.FormatInt(int64(v), 10)
+	case int32:
+		attribute.Value.IntValue = strconv.FormatInt(int64(v), 10)
+	case int64:
+		attribute.Value.IntValue = strconv.FormatInt(v, 10)
+	case uint16:
+		attribute.Value.IntValue = strconv.FormatUint(uint64(v), 10)
+	case uint32:
+		attribute.Value.IntValue = strconv.FormatUint(uint64(v), 10)
+	case uint64:
+		attribute.Value.IntValue = strconv.FormatUint(v, 10)
+	case float32:
+		double := float64(v)
+		attribute.Value.DoubleValue = &double
+	case float64:
+		attribute.Value.DoubleValue = &v
+	default:
+		text := fmt.Sprint(v)
+		attribute.Value.StringValue = &text
+	}
+
+	return attribute
+}
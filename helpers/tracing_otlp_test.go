@@ -0,0 +1,74 @@
+package helpers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadTracer_OTLP(t *testing.T) {
+	var (
+		lock     sync.Mutex
+		requests []otlpRequest
+		headers  http.Header
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := otlpRequest{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+
+		lock.Lock()
+		requests = append(requests, request)
+		headers = r.Header.Clone()
+		lock.Unlock()
+
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	defer server.Close()
+
+	loaded, err := LoadTracer(TracerConfig{
+		ServiceName: `test`,
+		Exporter:    TracerExporterOTLP,
+		Endpoint:    server.URL + `/v1/traces`,
+		Headers:     map[string]string{`Authorization`: `Bearer token`},
+	})
+	require.NoError(t, err)
+
+	parent := loaded.Tracer.StartSpan(`parent`)
+	ext.SpanKindRPCClient.Set(parent)
+	ext.Error.Set(parent, true)
+	parent.LogFields(log.String(`event`, `失败`), log.Int(`code`, 1))
+	parent.Finish()
+
+	require.NoError(t, loaded.Closer.Close(), `关闭时发送剩余的span`)
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	require.Len(t, requests, 1)
+	require.Equal(t, `application/json`, headers.Get(`Content-Type`))
+	require.Equal(t, `Bearer token`, headers.Get(`Authorization`))
+
+	resourceSpans := requests[0].ResourceSpans
+	require.Len(t, resourceSpans, 1)
+	require.Equal(t, `service.name`, resourceSpans[0].Resource.Attributes[0].Key)
+	require.Equal(t, `test`, *resourceSpans[0].Resource.Attributes[0].Value.StringValue)
+
+	spans := resourceSpans[0].ScopeSpans[0].Spans
+	require.Len(t, spans, 1)
+	require.Equal(t, `parent`, spans[0].Name)
+	require.Len(t, spans[0].TraceID, 32, `traceId为16字节hex`)
+	require.Len(t, spans[0].SpanID, 16, `spanId为8字节hex`)
+	require.Empty(t, spans[0].ParentSpanID)
+	require.Equal(t, otlpKindClient, spans[0].Kind)
+	require.Equal(t, otlpStatusError, spans[0].Status.Code)
+	require.Len(t, spans[0].Events, 1)
+	require.Equal(t, `失败`, spans[0].Events[0].Name)
+}
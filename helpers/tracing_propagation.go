@@ -0,0 +1,295 @@
+package helpers
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/pkg/errors"
+	"github.com/uber/jaeger-client-go"
+)
+
+const (
+	// TracePropagationJaeger jaeger 的 uber-trace-id 头
+	TracePropagationJaeger = `jaeger`
+	// TracePropagationW3C W3C Trace Context 的 traceparent 头,OpenTelemetry 默认使用
+	TracePropagationW3C = `w3c`
+
+	traceparentHeader = `traceparent`
+	traceparentSize   = 55
+	w3cVersion        = `00`
+	w3cInvalidVersion = `ff`
+	w3cSampled        = 0x01
+)
+
+var (
+	errTraceparent = errors.New(`traceparent 格式错误`)
+)
+
+// tracePropagator 同时支持 jaeger 和 W3C 格式,注入时写入所有格式,提取时优先 W3C
+type tracePropagator struct {
+	jaeger *jaeger.TextMapPropagator
+	w3c    bool
+}
+
+/*
+newTracePropagator 新建传播器
+参数:
+*	propagations    	[]string        	格式,为空时同时使用 jaeger 和 W3C
+*	httpHeader      	bool            	是否用于 HTTP 头,jaeger 格式在 HTTP 头中需要转义
+返回值:
+*	*tracePropagator	*tracePropagator	传播器
+*	error           	error           	错误
+*/
+func newTracePropagator(propagations []string, httpHeader bool) (*tracePropagator, error) {
+	if len(propagations) == 0 {
+		propagations = []string{TracePropagationJaeger, TracePropagationW3C}
+	}
+
+	propagator := &tracePropagator{}
+
+	for _, propagation := range propagations {
+		switch propagation {
+		case TracePropagationJaeger:
+			headers := (&jaeger.HeadersConfig{}).ApplyDefaults()
+
+			if httpHeader {
+				propagator.jaeger = jaeger.NewHTTPHeaderPropagator(headers, *jaeger.NewNullMetrics())
+			} else {
+				propagator.jaeger = jaeger.NewTextMapPropagator(headers, *jaeger.NewNullMetrics())
+			}
+		case TracePropagationW3C:
+			propagator.w3c = true
+		default:
+			return nil, fmt.Errorf(`不支持的传播格式[%s]`, propagation)
+		}
+	}
+
+	return propagator, nil
+}
+
+func (p tracePropagator) Inject(spanContext jaeger.SpanContext, carrier interface{}) error {
+	writer, ok := carrier.(opentracing.TextMapWriter)
+	if !ok {
+		return opentracing.ErrInvalidCarrier
+	}
+
+	if p.jaeger != nil {
+		if err := p.jaeger.Inject(spanContext, carrier); err != nil {
+			return err
+		}
+	}
+
+	if p.w3c {
+		writer.Set(traceparentHeader, formatTraceparent(spanContext))
+	}
+
+	return nil
+}
+
+func (p tracePropagator) Extract(carrier interface{}) (jaeger.SpanContext, error) {
+	reader, ok := carrier.(opentracing.TextMapReader)
+	if !ok {
+		return jaeger.SpanContext{}, opentracing.ErrInvalidCarrier
+	}
+
+	if p.w3c {
+		var traceparent string
+
+		_ = reader.ForeachKey(func(key, value string) error {
+			if strings.EqualFold(key, traceparentHeader) {
+				traceparent = value
+			}
+
+			return nil
+		})
+
+		if traceparent != `` {
+			if spanContext, err := parseTraceparent(traceparent); err == nil {
+				return spanContext, nil
+			}
+		}
+	}
+
+	if p.jaeger != nil {
+		return p.jaeger.Extract(carrier)
+	}
+
+	return jaeger.SpanContext{}, opentracing.ErrSpanContextNotFound
+}
+
+// formatTraceparent 00-{trace-id}-{parent-id}-{flags}
+func formatTraceparent(spanContext jaeger.SpanContext) string {
+	var flags byte
+
+	if spanContext.IsSampled() {
+		flags = w3cSampled
+	}
+
+	traceID := spanContext.TraceID()
+
+	return fmt.Sprintf(`%s-%016x%016x-%016x-%02x`, w3cVersion, traceID.High, traceID.Low, uint64(spanContext.SpanID()), flags)
+}
+
+/*
+parseTraceparent 解析 W3C traceparent
+参数:
+*	value      	string            	traceparent 头
+返回值:
+*	spanContext	jaeger.SpanContext	上游 span
+*	err        	error             	错误
+*/
+func parseTraceparent(value string) (spanContext jaeger.SpanContext, err error) {
+	value = strings.TrimSpace(value)
+
+	// 更高版本可能在后面追加字段,只解析前面的部分
+	if len(value) < traceparentSize || (len(value) > traceparentSize && value[traceparentSize] != '-') {
+		return spanContext, errTraceparent
+	}
+
+	parts := strings.Split(value[:traceparentSize], `-`)
+	if len(parts) != 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return spanContext, errTraceparent
+	}
+
+	if parts[0] == w3cInvalidVersion || (parts[0] == w3cVersion && len(value) != traceparentSize) {
+		return spanContext, errTraceparent
+	}
+
+	var (
+		traceID jaeger.TraceID
+		spanID  uint64
+		flags   []byte
+	)
+
+	if traceID.High, err = parseHexUint64(parts[1][:16]); err != nil {
+		return spanContext, errTraceparent
+	}
+
+	if traceID.Low, err = parseHexUint64(parts[1][16:]); err != nil {
+		return spanContext, errTraceparent
+	}
+
+	if spanID, err = parseHexUint64(parts[2]); err != nil {
+		return spanContext, errTraceparent
+	}
+
+	if flags, err = hex.DecodeString(parts[3]); err != nil || len(flags) != 1 {
+		return spanContext, errTraceparent
+	}
+
+	if !traceID.IsValid() || spanID == 0 {
+		return spanContext, errTraceparent
+	}
+
+	return jaeger.NewSpanContext(traceID, jaeger.SpanID(spanID), 0, flags[0]&w3cSampled != 0, nil), nil
+}
+
+func parseHexUint64(value string) (result uint64, err error) {
+	data, err := hex.DecodeString(value)
+	if err != nil || len(data) != 8 {
+		return 0, errTraceparent
+	}
+
+	for _, b := range data {
+		result = result<<8 | uint64(b)
+	}
+
+	return result, nil
+}
+
+/*
+ExtractHTTP 从 HTTP 头中提取上游 span
+参数:
+*	tracer                 	opentracing.Tracer     	tracer
+*	header                 	http.Header            	HTTP 头
+返回值:
+*	opentracing.SpanContext	opentracing.SpanContext	上游 span,没有时返回 nil
+*/
+func ExtractHTTP(tracer opentracing.Tracer, header http.Header) opentracing.SpanContext {
+	if tracer == nil {
+		return nil
+	}
+
+	spanContext, err := tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(header))
+	if err != nil {
+		return nil
+	}
+
+	return spanContext
+}
+
+/*
+InjectHTTP 把 span 写入 HTTP 头,用于调用下游服务
+参数:
+*	header	http.Header	HTTP 头
+返回值:
+*	error 	error      	错误
+*/
+func (s *Span) InjectHTTP(header http.Header) error {
+	if s == nil {
+		return nil
+	}
+
+	return s.span.Tracer().Inject(s.span.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(header))
+}
+
+type tracingTransport struct {
+	base http.RoundTripper
+}
+
+/*
+NewTracingTransport 带链路追踪的 http.RoundTripper,请求的 context 中有 span 时创建子 span 并注入到请求头
+参数:
+*	base             	http.RoundTripper	底层,为空时使用 http.DefaultTransport,已经封装过时直接返回
+返回值:
+*	http.RoundTripper	http.RoundTripper	封装后的 RoundTripper
+*/
+func NewTracingTransport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	if traced, ok := base.(*tracingTransport); ok {
+		return traced
+	}
+
+	return &tracingTransport{base: base}
+}
+
+func (t tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	span := StartChild(GetSpanFromCtx(req.Context()), `http-`+req.Method+` `+req.URL.Host)
+	if span == nil {
+		return t.base.RoundTrip(req)
+	}
+
+	// 查询参数可能包含密钥,不记录
+	ext.SpanKindRPCClient.Set(span.span)
+	ext.HTTPMethod.Set(span.span, req.Method)
+	ext.HTTPUrl.Set(span.span, req.URL.Scheme+`://`+req.URL.Host+req.URL.Path)
+
+	// RoundTripper 不能修改原始请求
+	req = req.Clone(req.Context())
+
+	if err := span.InjectHTTP(req.Header); err != nil {
+		span.SetTag(`inject-err`, err.Error())
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err == nil {
+		ext.HTTPStatusCode.Set(span.span, uint16(resp.StatusCode))
+
+		if resp.StatusCode >= http.StatusInternalServerError {
+			ext.Error.Set(span.span, true)
+		}
+	} else {
+		ext.Error.Set(span.span, true)
+	}
+
+	span.FinishSpan(err)
+
+	return resp, err
+}
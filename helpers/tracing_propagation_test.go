@@ -0,0 +1,102 @@
+package helpers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/jaeger-client-go"
+)
+
+const (
+	testTraceID     = `4bf92f3577b34da6a3ce929d0e0e4736`
+	testTraceparent = `00-` + testTraceID + `-00f067aa0ba902b7-01`
+)
+
+func TestParseTraceparent(t *testing.T) {
+	spanContext, parseErr := parseTraceparent(testTraceparent)
+	require.NoError(t, parseErr)
+	require.True(t, spanContext.IsSampled())
+	require.Equal(t, testTraceparent, formatTraceparent(spanContext), `格式化后一致`)
+
+	for _, value := range []string{
+		``,
+		`00-` + testTraceID + `-00f067aa0ba902b7`,
+		`ff-` + testTraceID + `-00f067aa0ba902b7-01`,
+		`00-00000000000000000000000000000000-00f067aa0ba902b7-01`,
+		`00-` + testTraceID + `-0000000000000000-01`,
+		`00-` + testTraceID + `-00f067aa0ba902b7-01-extra`,
+		`00-` + testTraceID + `x00f067aa0ba902b7-01`,
+	} {
+		_, parseErr = parseTraceparent(value)
+		require.Error(t, parseErr, value)
+	}
+
+	_, parseErr = parseTraceparent(`01-` + testTraceID + `-00f067aa0ba902b7-01-extra`)
+	require.NoError(t, parseErr, `高版本允许追加字段`)
+}
+
+func TestTracePropagation(t *testing.T) {
+	loaded, loadErr := LoadTracer(TracerConfig{ServiceName: `test`})
+	require.NoError(t, loadErr)
+
+	defer loaded.Closer.Close()
+
+	downstream := http.Header{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downstream = r.Header.Clone()
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	defer server.Close()
+
+	client := &http.Client{Transport: NewTracingTransport(nil)}
+	require.Same(t, client.Transport, NewTracingTransport(client.Transport), `已经封装过的不重复封装`)
+
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(Trace(loaded.Tracer))
+	router.GET(`/test`, func(ctx *gin.Context) {
+		req, reqErr := http.NewRequestWithContext(ctx.Request.Context(), http.MethodGet, server.URL, nil)
+		require.NoError(t, reqErr)
+
+		resp, doErr := client.Do(req)
+		require.NoError(t, doErr)
+
+		_ = resp.Body.Close()
+
+		ctx.Status(http.StatusOK)
+	})
+
+	request := httptest.NewRequest(http.MethodGet, `/test`, nil)
+	request.Header.Set(traceparentHeader, testTraceparent)
+	router.ServeHTTP(httptest.NewRecorder(), request)
+
+	traceparent := downstream.Get(traceparentHeader)
+	require.True(t, strings.HasPrefix(traceparent, `00-`+testTraceID+`-`), `W3C上游的trace-id传递到下游:`+traceparent)
+	require.NotContains(t, traceparent, `00f067aa0ba902b7`, `下游的父span是新的span`)
+	require.True(t, strings.HasPrefix(downstream.Get(jaeger.TraceContextHeaderName), strings.TrimLeft(testTraceID, `0`)), `同时注入jaeger格式`)
+
+	// jaeger 格式的上游
+	request = httptest.NewRequest(http.MethodGet, `/test`, nil)
+	request.Header.Set(jaeger.TraceContextHeaderName, `1234:5678:0:1`)
+	router.ServeHTTP(httptest.NewRecorder(), request)
+
+	require.True(t, strings.HasPrefix(downstream.Get(traceparentHeader), `00-00000000000000000000000000001234-`), `jaeger上游的trace-id传递到下游`)
+}
+
+func TestLoadTracer(t *testing.T) {
+	_, loadErr := LoadTracer(TracerConfig{ServiceName: `test`, Exporter: `unknown`})
+	require.Error(t, loadErr)
+
+	_, loadErr = LoadTracer(TracerConfig{ServiceName: `test`, Propagation: []string{`b3`}})
+	require.Error(t, loadErr)
+
+	loaded, loadErr := LoadTracer(TracerConfig{ServiceName: `test`, Exporter: TracerExporterJaegerHTTP, Propagation: []string{TracePropagationW3C}})
+	require.NoError(t, loadErr)
+	require.NoError(t, loaded.Closer.Close())
+}
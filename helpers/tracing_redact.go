@@ -0,0 +1,274 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/uber/jaeger-client-go"
+)
+
+const (
+	// TraceSamplerConst 全部采样(Param 为1)或者全部不采样(Param 为0)
+	TraceSamplerConst = `const`
+	// TraceSamplerProbabilistic 按照概率采样,Param 为 0~1
+	TraceSamplerProbabilistic = `probabilistic`
+	// TraceSamplerRateLimiting 限流采样,Param 为每秒最多采样的数量
+	TraceSamplerRateLimiting = `ratelimiting`
+
+	redactedValue  = `***`
+	defaultMaxBody = 4 * 1024
+)
+
+// TraceSampler 采样配置
+type TraceSampler struct {
+	Type  string  `json:"type"`  // TraceSamplerConst、TraceSamplerProbabilistic、TraceSamplerRateLimiting,为空时全部采样
+	Param float64 `json:"param"` // 参数
+}
+
+func (t TraceSampler) build() (jaeger.Sampler, error) {
+	switch t.Type {
+	case ``:
+		return jaeger.NewConstSampler(true), nil
+	case TraceSamplerConst:
+		return jaeger.NewConstSampler(t.Param != 0), nil
+	case TraceSamplerProbabilistic:
+		return jaeger.NewProbabilisticSampler(t.Param)
+	case TraceSamplerRateLimiting:
+		return jaeger.NewRateLimitingSampler(t.Param), nil
+	default:
+		return nil, fmt.Errorf(`不支持的采样类型[%s]`, t.Type)
+	}
+}
+
+// routeSampler 按照路由前缀选择采样器,最长前缀优先,span 名称是请求路径
+type routeSampler struct {
+	fallback jaeger.Sampler
+	prefixes []string
+	routes   map[string]jaeger.Sampler
+}
+
+/*
+newTraceSampler 新建采样器
+参数:
+*	fallback      	TraceSampler           	默认采样
+*	routes        	map[string]TraceSampler	路由前缀->采样
+返回值:
+*	jaeger.Sampler	jaeger.Sampler         	采样器
+*	error         	error                  	错误
+*/
+func newTraceSampler(fallback TraceSampler, routes map[string]TraceSampler) (jaeger.Sampler, error) {
+	sampler, err := fallback.build()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(routes) == 0 {
+		return sampler, nil
+	}
+
+	target := &routeSampler{
+		fallback: sampler,
+		prefixes: make([]string, 0, len(routes)),
+		routes:   make(map[string]jaeger.Sampler, len(routes)),
+	}
+
+	for prefix, route := range routes {
+		if target.routes[prefix], err = route.build(); err != nil {
+			return nil, fmt.Errorf(`路由[%s]: %w`, prefix, err)
+		}
+
+		target.prefixes = append(target.prefixes, prefix)
+	}
+
+	sort.Slice(target.prefixes, func(i, j int) bool {
+		return len(target.prefixes[i]) > len(target.prefixes[j])
+	})
+
+	return target, nil
+}
+
+func (r routeSampler) IsSampled(id jaeger.TraceID, operation string) (sampled bool, tags []jaeger.Tag) {
+	for _, prefix := range r.prefixes {
+		if strings.HasPrefix(operation, prefix) {
+			return r.routes[prefix].IsSampled(id, operation)
+		}
+	}
+
+	return r.fallback.IsSampled(id, operation)
+}
+
+func (r routeSampler) Close() {
+	r.fallback.Close()
+
+	for _, sampler := range r.routes {
+		sampler.Close()
+	}
+}
+
+func (r routeSampler) Equal(other jaeger.Sampler) bool {
+	return false
+}
+
+// TraceRedaction 链路追踪脱敏规则
+type TraceRedaction struct {
+	Headers []string `json:"headers"` // 需要脱敏的请求头,不区分大小写
+	Fields  []string `json:"fields"`  // 需要脱敏的JSON字段和查询参数,不含 . 时匹配任意层级,例如 code;含 . 时从根开始匹配,例如 wallet.privateKey
+	MaxBody int      `json:"maxBody"` // 记录的请求体最大字节数,超过不记录
+}
+
+// DefaultTraceRedaction 默认脱敏规则
+func DefaultTraceRedaction() TraceRedaction {
+	return TraceRedaction{
+		Headers: []string{`token`, `Authorization`, `Cookie`},
+		Fields:  []string{`code`, `password`, `privateKey`, `secret`, `token`},
+		MaxBody: defaultMaxBody,
+	}
+}
+
+type traceRedactor struct {
+	headers  map[string]struct{}
+	anywhere map[string]struct{}
+	paths    map[string]struct{}
+	maxBody  int
+}
+
+func newTraceRedactor(redaction TraceRedaction) *traceRedactor {
+	redactor := &traceRedactor{
+		headers:  make(map[string]struct{}, len(redaction.Headers)),
+		anywhere: make(map[string]struct{}, len(redaction.Fields)),
+		paths:    make(map[string]struct{}, len(redaction.Fields)),
+		maxBody:  redaction.MaxBody,
+	}
+
+	if redactor.maxBody <= 0 {
+		redactor.maxBody = defaultMaxBody
+	}
+
+	for _, header := range redaction.Headers {
+		redactor.headers[strings.ToLower(header)] = struct{}{}
+	}
+
+	for _, field := range redaction.Fields {
+		field = strings.ToLower(field)
+
+		if strings.Contains(field, `.`) {
+			redactor.paths[field] = struct{}{}
+		} else {
+			redactor.anywhere[field] = struct{}{}
+		}
+	}
+
+	return redactor
+}
+
+func (r traceRedactor) header(name, value string) string {
+	if value == `` {
+		return ``
+	}
+
+	if _, exist := r.headers[strings.ToLower(name)]; exist {
+		return redactedValue
+	}
+
+	return value
+}
+
+func (r traceRedactor) match(key, path string) bool {
+	if _, exist := r.anywhere[strings.ToLower(key)]; exist {
+		return true
+	}
+
+	_, exist := r.paths[strings.ToLower(path)]
+
+	return exist
+}
+
+// query 脱敏查询参数或者表单
+func (r traceRedactor) query(raw string) string {
+	values, err := url.ParseQuery(raw)
+	if err != nil {
+		return redactedValue
+	}
+
+	changed := false
+
+	for key := range values {
+		if r.match(key, key) {
+			values[key] = []string{redactedValue}
+			changed = true
+		}
+	}
+
+	if !changed {
+		return raw
+	}
+
+	return values.Encode()
+}
+
+/*
+body 脱敏请求体,JSON 按照字段脱敏,表单按照参数脱敏
+参数:
+*	contentType	string	Content-Type
+*	body       	[]byte	请求体
+返回值:
+*	string     	string	用于记录的请求体
+*/
+func (r traceRedactor) body(contentType string, body []byte) string {
+	if len(body) > r.maxBody {
+		return fmt.Sprintf(`大于%d字节,请查看日志`, r.maxBody)
+	}
+
+	if strings.Contains(contentType, `application/x-www-form-urlencoded`) {
+		return r.query(string(body))
+	}
+
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return string(body)
+	}
+
+	var value interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader(trimmed))
+	decoder.UseNumber()
+
+	if err := decoder.Decode(&value); err != nil {
+		return `JSON格式错误,请查看日志`
+	}
+
+	data, err := json.Marshal(r.redactJSON(value, ``))
+	if err != nil {
+		return `JSON格式错误,请查看日志`
+	}
+
+	return string(data)
+}
+
+func (r traceRedactor) redactJSON(value interface{}, path string) interface{} {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		for key, item := range typed {
+			itemPath := key
+			if path != `` {
+				itemPath = path + `.` + key
+			}
+
+			if r.match(key, itemPath) {
+				typed[key] = redactedValue
+			} else {
+				typed[key] = r.redactJSON(item, itemPath)
+			}
+		}
+	case []interface{}:
+		for i := range typed {
+			typed[i] = r.redactJSON(typed[i], path)
+		}
+	}
+
+	return value
+}
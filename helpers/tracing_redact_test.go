@@ -0,0 +1,145 @@
+package helpers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/jaeger-client-go"
+)
+
+func TestTraceRedactor(t *testing.T) {
+	redactor := newTraceRedactor(TraceRedaction{
+		Headers: []string{`token`},
+		Fields:  []string{`code`, `wallet.privateKey`},
+		MaxBody: 128,
+	})
+
+	require.Equal(t, redactedValue, redactor.header(`Token`, `abc`))
+	require.Equal(t, `zh`, redactor.header(`lang`, `zh`))
+
+	body := redactor.body(`application/json`, []byte(`{"code":"123456","parameters":[{"Code":1}],"wallet":{"privateKey":"key","address":"T"},"privateKey":"other"}`))
+	require.JSONEq(t, `{"code":"***","parameters":[{"Code":"***"}],"wallet":{"privateKey":"***","address":"T"},"privateKey":"other"}`, body)
+
+	require.Equal(t, `code=%2A%2A%2A&page=1`, redactor.body(`application/x-www-form-urlencoded`, []byte(`code=1&page=1`)))
+	require.Equal(t, `page=1`, redactor.query(`page=1`), `没有敏感参数时保持原样`)
+	require.Equal(t, `JSON格式错误,请查看日志`, redactor.body(`application/json`, []byte(`{"code":`)))
+	require.Contains(t, redactor.body(`application/json`, []byte(`{"a":"`+strings.Repeat(`a`, 200)+`"}`)), `大于128字节`)
+}
+
+func TestTraceSampler(t *testing.T) {
+	sampler, samplerErr := newTraceSampler(TraceSampler{Type: TraceSamplerConst}, map[string]TraceSampler{
+		`/api`:        {Type: TraceSamplerConst, Param: 1},
+		`/api/health`: {Type: TraceSamplerProbabilistic, Param: 0},
+	})
+	require.NoError(t, samplerErr)
+
+	defer sampler.Close()
+
+	id := jaeger.TraceID{Low: 1}
+
+	sampled, _ := sampler.IsSampled(id, `/other`)
+	require.False(t, sampled, `默认不采样`)
+
+	sampled, _ = sampler.IsSampled(id, `/api/user`)
+	require.True(t, sampled, `路由覆盖`)
+
+	sampled, _ = sampler.IsSampled(id, `/api/health/check`)
+	require.False(t, sampled, `最长前缀优先`)
+
+	_, samplerErr = newTraceSampler(TraceSampler{Type: `unknown`}, nil)
+	require.Error(t, samplerErr)
+
+	_, samplerErr = newTraceSampler(TraceSampler{}, map[string]TraceSampler{`/api`: {Type: TraceSamplerProbabilistic, Param: 2}})
+	require.Error(t, samplerErr, `概率超过1`)
+}
+
+func TestTraceWithOption(t *testing.T) {
+	mock := mocktracer.New()
+
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(TraceWithOption(mock, TraceOption{}))
+	router.POST(`/parameters/set`, func(ctx *gin.Context) {
+		data, readErr := ctx.GetRawData()
+		require.NoError(t, readErr)
+		require.Contains(t, string(data), `123456`, `处理函数读取到原始请求体`)
+
+		ctx.Status(http.StatusOK)
+	})
+
+	request := httptest.NewRequest(http.MethodPost, `/parameters/set?secret=abc`, strings.NewReader(`{"code":"123456","userID":1}`))
+	request.Header.Set(`Content-Type`, `application/json`)
+	request.Header.Set(`token`, `jwt`)
+	router.ServeHTTP(httptest.NewRecorder(), request)
+
+	spans := mock.FinishedSpans()
+	require.Len(t, spans, 1)
+	require.Equal(t, `/parameters/set`, spans[0].OperationName, `span名称不包含查询参数`)
+
+	tags := spans[0].Tags()
+	require.Equal(t, redactedValue, tags[`token`])
+	require.Equal(t, `secret=%2A%2A%2A`, tags[`query`])
+	require.JSONEq(t, `{"code":"***","userID":1}`, tags[`body`].(string))
+}
+
+func TestTraceWithOption_defaultHeaders(t *testing.T) {
+	mock := mocktracer.New()
+
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(TraceWithOption(mock, TraceOption{
+		Headers:   []string{`token`, `Authorization`},
+		Redaction: TraceRedaction{Fields: []string{`pin`}},
+	}))
+	router.GET(`/user`, func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+
+	request := httptest.NewRequest(http.MethodGet, `/user?pin=1`, nil)
+	request.Header.Set(`token`, `jwt`)
+	request.Header.Set(`Authorization`, `Bearer jwt`)
+	router.ServeHTTP(httptest.NewRecorder(), request)
+
+	spans := mock.FinishedSpans()
+	require.Len(t, spans, 1)
+
+	tags := spans[0].Tags()
+	require.Equal(t, redactedValue, tags[`token`], `只配置字段时默认请求头仍然脱敏`)
+	require.Equal(t, redactedValue, tags[`Authorization`])
+	require.Equal(t, `pin=%2A%2A%2A`, tags[`query`])
+}
+
+func TestTraceWithOption_defaultFields(t *testing.T) {
+	mock := mocktracer.New()
+
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(TraceWithOption(mock, TraceOption{
+		Headers:   []string{`X-Sign`},
+		Redaction: TraceRedaction{Headers: []string{`X-Sign`}},
+	}))
+	router.POST(`/wallet`, func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+
+	request := httptest.NewRequest(http.MethodPost, `/wallet?password=1`, strings.NewReader(`{"privateKey":"key","userID":1}`))
+	request.Header.Set(`Content-Type`, `application/json`)
+	request.Header.Set(`X-Sign`, `sign`)
+	router.ServeHTTP(httptest.NewRecorder(), request)
+
+	spans := mock.FinishedSpans()
+	require.Len(t, spans, 1)
+
+	tags := spans[0].Tags()
+	require.Equal(t, redactedValue, tags[`X-Sign`])
+	require.Equal(t, `password=%2A%2A%2A`, tags[`query`], `只配置请求头时默认字段仍然脱敏`)
+	require.JSONEq(t, `{"privateKey":"***","userID":1}`, tags[`body`].(string))
+}
@@ -10,6 +10,8 @@ import (
 	"net/http/httputil"
 	"strconv"
 	"time"
+
+	"github.com/fighterlyt/common/helpers"
 )
 
 // Client http://47.241.192.246:4999/web/#/page/edit/43/243
@@ -40,6 +42,11 @@ func NewClient(client *http.Client, key, iv []byte) (*Client, error) {
 		}
 	}
 
+	// 调用方传入的客户端同样需要传递链路,复制一份避免修改调用方的客户端
+	traced := *client
+	traced.Transport = helpers.NewTracingTransport(client.Transport)
+	client = &traced
+
 	aesCryptoCBC, err := NewAESCryptoCBC(key)
 	if err != nil {
 		return nil, err
@@ -63,7 +63,7 @@ func TestMain(m *testing.M) {
 
 	redisClient.AddHook(helpers.NewRedisLogger(testLogger))
 
-	Init(`data/parameters.json`, nil)
+	Init(`data/parameters.json`, nil, nil)
 
 	if testService, err = NewService(db, redisClient, testLogger, gin.Default()); err != nil {
 		panic(`NewService ` + err.Error())
@@ -0,0 +1,318 @@
+package parameters
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ImportOption 导入选项
+type ImportOption struct {
+	DryRun bool `json:"dryRun"` // 只计算差异,不修改
+	Prune  bool `json:"prune"`  // 删除导入数据中不存在的参数
+}
+
+// ValueChange 值变化
+type ValueChange struct {
+	Key   string `json:"key"`   // 参数key
+	Old   string `json:"old"`   // 当前值
+	New   string `json:"new"`   // 导入值
+	Hide  bool   `json:"hide"`  // 是否隐藏
+	Valid string `json:"valid"` // 验证失败原因,为空表示通过
+}
+
+// ImportDiff 导入差异
+type ImportDiff struct {
+	Added   []*Parameter  `json:"added"`   // 新增的参数
+	Changed []ValueChange `json:"changed"` // 值变化的参数
+	Removed []*Parameter  `json:"removed"` // 导入数据中不存在的参数,Prune 时会被删除
+}
+
+/*
+IsEmpty 是否没有差异
+参数:
+返回值:
+*	bool	bool	是否没有差异
+*/
+func (d ImportDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Changed) == 0 && len(d.Removed) == 0
+}
+
+/*
+keyValue 会被修改的参数,用于判断是否需要审批
+参数:
+*	prune            	bool             	是否包含删除的参数
+返回值:
+*	map[string]string	map[string]string	key->新值
+*/
+func (d ImportDiff) keyValue(prune bool) map[string]string {
+	keyValue := make(map[string]string, len(d.Added)+len(d.Changed)+len(d.Removed))
+
+	for _, parameter := range d.Added {
+		keyValue[parameter.Key] = parameter.Value
+	}
+
+	for _, change := range d.Changed {
+		keyValue[change.Key] = change.New
+	}
+
+	if prune {
+		for _, parameter := range d.Removed {
+			keyValue[parameter.Key] = ``
+		}
+	}
+
+	return keyValue
+}
+
+/*
+Mask 隐藏参数的值置空,用于返回给前端
+参数:
+返回值:
+*/
+func (d *ImportDiff) Mask() {
+	for _, parameter := range d.Added {
+		maskParameter(parameter)
+	}
+
+	for _, parameter := range d.Removed {
+		maskParameter(parameter)
+	}
+
+	for i := range d.Changed {
+		if d.Changed[i].Hide {
+			d.Changed[i].Old, d.Changed[i].New = ``, ``
+		}
+	}
+}
+
+func maskParameter(parameter *Parameter) {
+	if parameter.Hide {
+		parameter.Value = ``
+	}
+}
+
+/*
+Export 导出全部参数
+参数:
+*	mask      	bool        	是否置空隐藏参数的值
+返回值:
+*	parameters	[]*Parameter	参数
+*	err       	error       	错误
+*/
+func (s *service) Export(mask bool) (parameters []*Parameter, err error) {
+	if parameters, err = s.parameter.All(); err != nil {
+		return nil, errors.Wrap(err, `获取参数`)
+	}
+
+	if mask {
+		for _, parameter := range parameters {
+			maskParameter(parameter)
+		}
+	}
+
+	return parameters, nil
+}
+
+/*
+Import 导入参数,先计算差异并验证,DryRun 时直接返回差异,否则在一个事务中应用全部差异.
+隐藏参数导出时被置空的值不会覆盖当前值
+参数:
+*	parameters	[]*Parameter	导入的参数
+*	userID    	int64       	用户ID
+*	option    	ImportOption	导入选项
+返回值:
+*	diff      	*ImportDiff 	差异
+*	err       	error       	错误,验证失败时包含全部未通过的key
+*/
+func (s *service) Import(parameters []*Parameter, userID int64, option ImportOption) (diff *ImportDiff, err error) {
+	if diff, err = s.diff(parameters); err != nil {
+		return nil, errors.Wrap(err, `计算差异`)
+	}
+
+	if err = s.checkDiff(diff); err != nil || option.DryRun {
+		return diff, err
+	}
+
+	if s.needApproval(diff.keyValue(option.Prune)) {
+		return diff, ErrNeedApproval
+	}
+
+	if err = s.db.Transaction(func(tx *gorm.DB) error {
+		return s.applyDiff(tx, diff, userID, option.Prune)
+	}); err != nil {
+		return diff, errors.Wrap(err, `导入数据`)
+	}
+
+	keys := make([]string, 0, len(diff.Added)+len(diff.Changed)+len(diff.Removed))
+	changes := make([]ParameterChange, 0, cap(keys))
+
+	for _, parameter := range diff.Added {
+		keys = append(keys, parameter.Key)
+		changes = append(changes, NewParameterChange(parameter.Key, parameter.Value, userID))
+	}
+
+	for _, change := range diff.Changed {
+		keys = append(keys, change.Key)
+		changes = append(changes, NewParameterChange(change.Key, change.New, userID))
+	}
+
+	if option.Prune {
+		for _, parameter := range diff.Removed {
+			keys = append(keys, parameter.Key)
+			changes = append(changes, NewParameterChange(parameter.Key, ``, userID))
+		}
+	}
+
+	if err = s.parameter.Invalidate(keys...); err != nil {
+		s.logger.Warn(`删除缓存失败`, zap.String(`错误`, err.Error()))
+	}
+
+	s.publish(changes...)
+
+	return diff, nil
+}
+
+/*
+diff 计算导入数据和当前数据的差异
+参数:
+*	parameters	[]*Parameter	导入的参数
+返回值:
+*	diff      	*ImportDiff 	差异
+*	err       	error       	错误
+*/
+func (s *service) diff(parameters []*Parameter) (diff *ImportDiff, err error) {
+	var (
+		current []*Parameter
+	)
+
+	if current, err = s.parameter.All(); err != nil {
+		return nil, errors.Wrap(err, `获取当前参数`)
+	}
+
+	existed := make(map[string]*Parameter, len(current))
+
+	for _, parameter := range current {
+		existed[parameter.Key] = parameter
+	}
+
+	imported := make(map[string]struct{}, len(parameters))
+	diff = &ImportDiff{}
+
+	for _, parameter := range parameters {
+		imported[parameter.Key] = struct{}{}
+
+		old, exist := existed[parameter.Key]
+
+		switch {
+		case !exist:
+			diff.Added = append(diff.Added, parameter)
+		case parameter.Hide && parameter.Value == ``: // 导出时被置空的隐藏参数
+			continue
+		case old.Value != parameter.Value:
+			diff.Changed = append(diff.Changed, ValueChange{
+				Key:  parameter.Key,
+				Old:  old.Value,
+				New:  parameter.Value,
+				Hide: old.Hide || parameter.Hide,
+			})
+		}
+	}
+
+	for _, parameter := range current {
+		if _, exist := imported[parameter.Key]; !exist {
+			diff.Removed = append(diff.Removed, parameter)
+		}
+	}
+
+	return diff, nil
+}
+
+/*
+checkDiff 验证新增和修改的参数,每个修改的验证结果写入 ValueChange.Valid
+参数:
+*	diff 	*ImportDiff	差异
+返回值:
+*	err  	error      	错误,包含全部未通过的key
+*/
+func (s *service) checkDiff(diff *ImportDiff) (err error) {
+	for _, parameter := range diff.Added {
+		if singleErr := parameter.Validate(); singleErr != nil {
+			err = multierr.Append(err, errors.Wrapf(singleErr, `新增参数[%s]`, parameter.Key))
+		}
+	}
+
+	keyValue := make(map[string]string, len(diff.Changed))
+
+	for i, change := range diff.Changed {
+		keyValue[change.Key] = change.New
+
+		if singleErr := s.parameter.Check(map[string]string{change.Key: change.New}); singleErr != nil {
+			diff.Changed[i].Valid = singleErr.Error()
+			err = multierr.Append(err, singleErr)
+		}
+	}
+
+	if s.validate != nil && len(keyValue) != 0 {
+		if singleErr := s.validate.Validate(keyValue); singleErr != nil {
+			err = multierr.Append(err, errors.Wrap(singleErr, `参数验证失败`))
+		}
+	}
+
+	return err
+}
+
+/*
+applyDiff 在事务中应用差异
+参数:
+*	tx    	*gorm.DB   	事务
+*	diff  	*ImportDiff	差异
+*	userID	int64      	用户ID
+*	prune 	bool       	是否删除导入数据中不存在的参数
+返回值:
+*	error 	error      	错误
+*/
+func (s *service) applyDiff(tx *gorm.DB, diff *ImportDiff, userID int64, prune bool) error {
+	parameter, history := s.parameter.WithTx(tx), s.history.WithTx(tx)
+
+	for _, added := range diff.Added {
+		added.UpdateTime = time.Now().Unix()
+
+		if err := parameter.Save(added); err != nil {
+			return errors.Wrapf(err, `新增[%s]`, added.Key)
+		}
+
+		if err := history.Save(added.Key, added.Value, userID); err != nil {
+			return errors.Wrapf(err, `保存[%s]变更记录`, added.Key)
+		}
+	}
+
+	for _, change := range diff.Changed {
+		if err := parameter.Update(change.Key, change.New); err != nil {
+			return errors.Wrapf(err, `修改[%s]`, change.Key)
+		}
+
+		if err := history.Save(change.Key, change.New, userID); err != nil {
+			return errors.Wrapf(err, `保存[%s]变更记录`, change.Key)
+		}
+	}
+
+	if !prune {
+		return nil
+	}
+
+	for _, removed := range diff.Removed {
+		if err := parameter.Delete(removed.Key); err != nil {
+			return errors.Wrapf(err, `删除[%s]`, removed.Key)
+		}
+
+		if err := history.SaveDeleted(removed.Key, removed.Value, userID); err != nil {
+			return errors.Wrapf(err, `保存[%s]删除记录`, removed.Key)
+		}
+	}
+
+	return nil
+}
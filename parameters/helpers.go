@@ -1,72 +1,23 @@
 package parameters
 
 import (
-	"fmt"
-	"strconv"
-	"strings"
-
-	"github.com/pkg/errors"
 	"github.com/shopspring/decimal"
 )
 
 func (s *service) GetString(key string) (wallet string, err error) {
-	var (
-		result map[string]*Parameter
-	)
-
-	if result, err = s.GetParameters(key); err != nil {
-		return ``, errors.Wrapf(err, `获取业务参数[%s]`, key)
-	}
-
-	if result[key] == nil {
-		return "", fmt.Errorf("key[%s]不存在", key)
-	}
-
-	return result[key].Value, nil
+	return getRaw[string](s, key, `string`, decoderOf[string]())
 }
 
 func (s *service) GetDecimal(key string) (value decimal.Decimal, err error) {
-	var (
-		result string
-	)
-
-	if result, err = s.GetString(key); err != nil {
-		return value, err
-	}
-
-	return decimal.NewFromString(result)
+	return getRaw[decimal.Decimal](s, key, `decimal.Decimal`, decoderOf[decimal.Decimal]())
 }
 
 func (s *service) GetInts(key, delimiter string) (value []int64, err error) {
-	var (
-		result string
-		temp   int64
-	)
-
-	if result, err = s.GetString(key); err != nil {
-		return value, err
-	}
-
-	fields := strings.Split(result, delimiter)
-
-	for _, field := range fields {
-		if temp, err = strconv.ParseInt(field, 10, 64); err != nil {
-			return nil, err
-		}
-		value = append(value, temp)
-	}
-
-	return value, nil
+	return getRaw[[]int64](s, key, `ints:`+delimiter, func(value string) (interface{}, error) {
+		return decodeInts(value, delimiter)
+	})
 }
 
 func (s *service) GetInt(key string) (value int64, err error) {
-	var (
-		result string
-	)
-
-	if result, err = s.GetString(key); err != nil {
-		return value, err
-	}
-
-	return strconv.ParseInt(result, 10, 64)
+	return getRaw[int64](s, key, `int64`, decoderOf[int64]())
 }
@@ -27,6 +27,56 @@ func (h historyService) Save(key, value string, userID int64) error {
 	return nil
 }
 
+func (h historyService) SaveRollback(key, value string, userID, rollbackID int64) error {
+	history := NewRollbackHistory(key, value, userID, rollbackID)
+
+	if err := h.db.Create(history).Error; err != nil {
+		return errors.Wrap(err, `保存数据错误`)
+	}
+
+	return nil
+}
+
+func (h historyService) SaveApproved(key, value string, proposerID, approverID, proposalID int64) error {
+	history := NewApprovedHistory(key, value, proposerID, approverID, proposalID)
+
+	if err := h.db.Create(history).Error; err != nil {
+		return errors.Wrap(err, `保存数据错误`)
+	}
+
+	return nil
+}
+
+func (h historyService) SaveDeleted(key, value string, userID int64) error {
+	history := NewDeletedHistory(key, value, userID)
+
+	if err := h.db.Create(history).Error; err != nil {
+		return errors.Wrap(err, `保存数据错误`)
+	}
+
+	return nil
+}
+
+func (h historyService) GetByID(id int64) (history *History, err error) {
+	history = &History{}
+
+	if err = h.db.Model(h.model).Where(`id = ?`, id).First(history).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+
+		return nil, errors.Wrapf(err, `获取数据,id=[%d]`, id)
+	}
+
+	return history, nil
+}
+
+func (h historyService) WithTx(tx *gorm.DB) HistoryService {
+	h.db = tx
+
+	return h
+}
+
 func (h historyService) Get(key string, startTime, endTime int64, start, limit int) (allCount int64, histories []History, err error) {
 	h.logger.Debug(`获取数据`, zap.String(`elemKey`, key), zap.Ints(`开始/数量`, []int{start, limit}))
 
@@ -11,6 +11,13 @@ import (
 
 func (s *service) http() {
 	s.router.POST(`/set`, s.setParameters)
+	s.router.POST(`/rollback`, s.rollback)
+	s.router.POST(`/propose`, s.propose)
+	s.router.POST(`/approve`, s.approve)
+	s.router.POST(`/reject`, s.reject)
+	s.router.POST(`/getProposals`, s.getProposals)
+	s.router.POST(`/export`, s.export)
+	s.router.POST(`/import`, s.importParameters)
 	s.router.POST(`/get`, s.getParameters)
 	s.router.POST(`/getHistory`, s.getHistory)
 	s.router.POST(`/groupInfo`, s.httpGroupInfo)
@@ -44,23 +51,15 @@ func (s *service) setParameters(ctx *gin.Context) {
 		return
 	}
 
-	// 是否需要二次验证
-	if s.needTwoFactor(argument.Parameters) {
-		// 验证码为空,直接返回需要二次验证
-		if argument.Code == "" {
-			invoke.ReturnFail(ctx, invoke.NeedTwoFactor, errors.New("需要进行谷歌二次验证"), "请输入谷歌二次验证码")
-
-			return
-		}
+	if s.needApproval(argument.Parameters) {
+		err = ErrNeedApproval
+		invoke.ReturnFail(ctx, invoke.Fail, invoke.ErrFail, err.Error())
 
-		// 验证码不为空进行验证
-		ok, err := s.auth.Validate(argument.Code)
-		if !ok || err != nil {
-			err = errors.New("验证码错误")
-			invoke.ReturnFail(ctx, invoke.NeedTwoFactor, invoke.ErrFail, err.Error())
+		return
+	}
 
-			return
-		}
+	if !s.twoFactorPassed(ctx, argument.Parameters, argument.Code) {
+		return
 	}
 
 	if err = s.Modify(argument.Parameters, argument.UserID); err != nil {
@@ -73,6 +72,58 @@ func (s *service) setParameters(ctx *gin.Context) {
 	invoke.ReturnSuccess(ctx, nil)
 }
 
+/*
+twoFactorPassed 需要二次验证的参数进行验证,未通过时已经返回结果
+参数:
+*	ctx       	*gin.Context     	gin
+*	parameters	map[string]string	修改的参数
+*	code      	string           	验证码
+返回值:
+*	passed    	bool             	是否通过
+*/
+func (s *service) twoFactorPassed(ctx *gin.Context, parameters map[string]string, code string) (passed bool) {
+	// 是否需要二次验证
+	if !s.needTwoFactor(parameters) {
+		return true
+	}
+
+	return s.codePassed(ctx, code)
+}
+
+/*
+codePassed 验证二次验证码,未通过时已经返回结果
+参数:
+*	ctx   	*gin.Context	gin
+*	code  	string      	验证码
+返回值:
+*	passed	bool        	是否通过
+*/
+func (s *service) codePassed(ctx *gin.Context, code string) (passed bool) {
+	if s.auth == nil {
+		invoke.ReturnFail(ctx, invoke.Fail, invoke.ErrFail, `未配置谷歌二次验证`)
+
+		return false
+	}
+
+	// 验证码为空,直接返回需要二次验证
+	if code == "" {
+		invoke.ReturnFail(ctx, invoke.NeedTwoFactor, errors.New("需要进行谷歌二次验证"), "请输入谷歌二次验证码")
+
+		return false
+	}
+
+	// 验证码不为空进行验证
+	ok, err := s.auth.Validate(code)
+	if !ok || err != nil {
+		err = errors.New("验证码错误")
+		invoke.ReturnFail(ctx, invoke.NeedTwoFactor, invoke.ErrFail, err.Error())
+
+		return false
+	}
+
+	return true
+}
+
 func (s *service) needTwoFactor(keys map[string]string) (need bool) {
 	for key, _ := range keys {
 		for i := range s.needTwoFactorKeys {
@@ -103,6 +154,416 @@ func (s setParametersArgument) Validate() error {
 	return nil
 }
 
+/*
+rollback 回滚参数到历史值
+参数:
+*	ctx	*gin.Context	gin
+返回值:
+*/
+func (s *service) rollback(ctx *gin.Context) {
+	argument := &rollbackArgument{}
+
+	var (
+		returned bool
+		err      error
+	)
+
+	defer func() {
+		if err != nil {
+			s.logger.Error(err.Error())
+		}
+	}()
+
+	if returned, err = invoke.ProcessArgument(ctx, argument); err != nil || returned {
+		return
+	}
+
+	if !s.twoFactorPassed(ctx, map[string]string{argument.Key: ``}, argument.Code) {
+		return
+	}
+
+	if err = s.Rollback(argument.Key, argument.HistoryID, argument.UserID); err != nil {
+		err = errors.Wrap(err, `回滚参数`)
+		invoke.ReturnFail(ctx, invoke.Fail, invoke.ErrFail, err.Error())
+
+		return
+	}
+
+	invoke.ReturnSuccess(ctx, nil)
+}
+
+type rollbackArgument struct {
+	Key       string `json:"key"`
+	HistoryID int64  `json:"historyID"`
+	UserID    int64  `json:"userID"`
+	Code      string `json:"code"`
+}
+
+func (r rollbackArgument) Validate() error {
+	if r.Key == `` {
+		return errors.New(`key不能为空`)
+	}
+
+	if r.HistoryID <= 0 {
+		return fmt.Errorf(`historyID[%d]非法`, r.HistoryID)
+	}
+
+	if r.UserID <= 0 {
+		return fmt.Errorf(`userID[%d]非法`, r.UserID)
+	}
+
+	return nil
+}
+
+/*
+propose 提交修改提案
+参数:
+*	ctx	*gin.Context	gin
+返回值:
+*/
+func (s *service) propose(ctx *gin.Context) {
+	argument := &setParametersArgument{}
+
+	var (
+		returned bool
+		err      error
+		proposal *Proposal
+	)
+
+	defer func() {
+		if err != nil {
+			s.logger.Error(err.Error())
+		}
+	}()
+
+	if returned, err = invoke.ProcessArgument(ctx, argument); err != nil || returned {
+		return
+	}
+
+	if !s.twoFactorPassed(ctx, argument.Parameters, argument.Code) {
+		return
+	}
+
+	if proposal, err = s.Propose(argument.Parameters, argument.UserID); err != nil {
+		err = errors.Wrap(err, `提交提案`)
+		invoke.ReturnFail(ctx, invoke.Fail, invoke.ErrFail, err.Error())
+
+		return
+	}
+
+	invoke.ReturnSuccess(ctx, proposal)
+}
+
+/*
+approve 审批通过提案
+参数:
+*	ctx	*gin.Context	gin
+返回值:
+*/
+func (s *service) approve(ctx *gin.Context) {
+	argument := &reviewArgument{}
+
+	var (
+		returned bool
+		err      error
+		proposal *Proposal
+	)
+
+	defer func() {
+		if err != nil {
+			s.logger.Error(err.Error())
+		}
+	}()
+
+	if returned, err = invoke.ProcessArgument(ctx, argument); err != nil || returned {
+		return
+	}
+
+	if proposal, err = s.GetProposal(argument.ProposalID); err != nil {
+		err = errors.Wrapf(err, `获取提案[%d]`, argument.ProposalID)
+		invoke.ReturnFail(ctx, invoke.Fail, invoke.ErrFail, err.Error())
+
+		return
+	}
+
+	if proposal == nil {
+		err = fmt.Errorf(`提案[%d]不存在`, argument.ProposalID)
+		invoke.ReturnFail(ctx, invoke.Fail, invoke.ErrFail, err.Error())
+
+		return
+	}
+
+	if !s.twoFactorPassed(ctx, proposal.Parameters, argument.Code) {
+		return
+	}
+
+	if err = s.Approve(argument.ProposalID, argument.UserID); err != nil {
+		err = errors.Wrap(err, `审批提案`)
+		invoke.ReturnFail(ctx, invoke.Fail, invoke.ErrFail, err.Error())
+
+		return
+	}
+
+	invoke.ReturnSuccess(ctx, nil)
+}
+
+/*
+reject 拒绝提案
+参数:
+*	ctx	*gin.Context	gin
+返回值:
+*/
+func (s *service) reject(ctx *gin.Context) {
+	argument := &reviewArgument{}
+
+	var (
+		returned bool
+		err      error
+	)
+
+	defer func() {
+		if err != nil {
+			s.logger.Error(err.Error())
+		}
+	}()
+
+	if returned, err = invoke.ProcessArgument(ctx, argument); err != nil || returned {
+		return
+	}
+
+	if err = s.Reject(argument.ProposalID, argument.UserID); err != nil {
+		err = errors.Wrap(err, `拒绝提案`)
+		invoke.ReturnFail(ctx, invoke.Fail, invoke.ErrFail, err.Error())
+
+		return
+	}
+
+	invoke.ReturnSuccess(ctx, nil)
+}
+
+type reviewArgument struct {
+	ProposalID int64  `json:"proposalID"`
+	UserID     int64  `json:"userID"`
+	Code       string `json:"code"`
+}
+
+func (r reviewArgument) Validate() error {
+	if r.ProposalID <= 0 {
+		return fmt.Errorf(`proposalID[%d]非法`, r.ProposalID)
+	}
+
+	if r.UserID <= 0 {
+		return fmt.Errorf(`userID[%d]非法`, r.UserID)
+	}
+
+	return nil
+}
+
+func (s *service) getProposals(ctx *gin.Context) {
+	query := &proposalQuery{}
+	argument, err := invoke.NewListArgument(query)
+
+	if err != nil {
+		err = errors.Wrap(err, `构建列表参数错误`)
+		invoke.ReturnFail(ctx, invoke.Fail, err, err.Error())
+
+		return
+	}
+
+	var returned bool
+
+	if returned, err = invoke.ProcessArgument(ctx, argument); err != nil || returned {
+		return
+	}
+
+	var (
+		result     []Proposal
+		allCount   int64
+		listResult *invoke.ListResult
+	)
+
+	if allCount, result, err = s.GetProposals(query.Status, argument.Start, argument.Limit); err != nil {
+		err = errors.Wrap(err, `操作失败`)
+		invoke.ReturnFail(ctx, invoke.Fail, err, err.Error())
+
+		return
+	}
+
+	if listResult, err = invoke.NewListResult(allCount, result); err != nil {
+		err = errors.Wrap(err, `构建列表返回值`)
+		invoke.ReturnFail(ctx, invoke.Fail, err, err.Error())
+
+		return
+	}
+
+	invoke.ReturnSuccess(ctx, listResult)
+}
+
+type proposalQuery struct {
+	Status ProposalStatus `json:"status"`
+}
+
+func (p *proposalQuery) Validate() error {
+	if p.Status < 0 || p.Status > ProposalExpired {
+		return fmt.Errorf(`status[%d]非法`, p.Status)
+	}
+
+	return nil
+}
+
+func (p *proposalQuery) Scope(db *gorm.DB) *gorm.DB {
+	return db
+}
+
+/*
+export 导出全部参数
+参数:
+*	ctx	*gin.Context	gin
+返回值:
+*/
+func (s *service) export(ctx *gin.Context) {
+	argument := &exportArgument{}
+
+	var (
+		returned   bool
+		err        error
+		parameters []*Parameter
+	)
+
+	defer func() {
+		if err != nil {
+			s.logger.Error(err.Error())
+		}
+	}()
+
+	if returned, err = invoke.ProcessArgument(ctx, argument); err != nil || returned {
+		return
+	}
+
+	if parameters, err = s.Export(argument.Mask); err != nil {
+		err = errors.Wrap(err, `导出参数`)
+		invoke.ReturnFail(ctx, invoke.Fail, err, err.Error())
+
+		return
+	}
+
+	if !argument.Mask && !s.exportPassed(ctx, parameters, argument.Code) {
+		return
+	}
+
+	invoke.ReturnSuccess(ctx, parameters)
+}
+
+/*
+exportPassed 导出隐藏参数或者需要二次验证的参数的值时进行二次验证,未通过时已经返回结果
+参数:
+*	ctx       	*gin.Context	gin
+*	parameters	[]*Parameter	导出的参数
+*	code      	string      	验证码
+返回值:
+*	passed    	bool        	是否通过
+*/
+func (s *service) exportPassed(ctx *gin.Context, parameters []*Parameter, code string) (passed bool) {
+	keys := make(map[string]string, len(parameters))
+	hidden := false
+
+	for _, parameter := range parameters {
+		keys[parameter.Key] = parameter.Value
+		hidden = hidden || parameter.Hide
+	}
+
+	if !hidden && !s.needTwoFactor(keys) {
+		return true
+	}
+
+	return s.codePassed(ctx, code)
+}
+
+type exportArgument struct {
+	Mask bool   `json:"mask"`
+	Code string `json:"code"` // 不隐藏时需要二次验证
+}
+
+func (e exportArgument) Validate() error {
+	return nil
+}
+
+/*
+importParameters 导入参数,dryRun 时只返回差异
+参数:
+*	ctx	*gin.Context	gin
+返回值:
+*/
+func (s *service) importParameters(ctx *gin.Context) {
+	argument := &importArgument{}
+
+	var (
+		returned bool
+		err      error
+		diff     *ImportDiff
+	)
+
+	defer func() {
+		if err != nil {
+			s.logger.Error(err.Error())
+		}
+	}()
+
+	if returned, err = invoke.ProcessArgument(ctx, argument); err != nil || returned {
+		return
+	}
+
+	if !argument.DryRun {
+		// 按差异验证,Prune 时被删除的参数也需要二次验证
+		if diff, err = s.diff(argument.Parameters); err != nil {
+			err = errors.Wrap(err, `计算差异`)
+			invoke.ReturnFail(ctx, invoke.Fail, invoke.ErrFail, err.Error())
+
+			return
+		}
+
+		if !s.twoFactorPassed(ctx, diff.keyValue(argument.Prune), argument.Code) {
+			return
+		}
+	}
+
+	if diff, err = s.Import(argument.Parameters, argument.UserID, argument.ImportOption); err != nil {
+		err = errors.Wrap(err, `导入参数`)
+		invoke.ReturnFail(ctx, invoke.Fail, invoke.ErrFail, err.Error())
+
+		return
+	}
+
+	diff.Mask()
+
+	invoke.ReturnSuccess(ctx, diff)
+}
+
+type importArgument struct {
+	Parameters []*Parameter `json:"parameters"`
+	UserID     int64        `json:"userID"`
+	Code       string       `json:"code"`
+	ImportOption
+}
+
+func (i importArgument) Validate() error {
+	if i.UserID <= 0 {
+		return fmt.Errorf(`userID[%d]非法`, i.UserID)
+	}
+
+	if len(i.Parameters) == 0 {
+		return errors.New(`参数不能为空`)
+	}
+
+	for _, parameter := range i.Parameters {
+		if parameter == nil {
+			return errors.New(`参数不能为null`)
+		}
+	}
+
+	return nil
+}
+
 func (s *service) getParameters(ctx *gin.Context) {
 	argument := &getParametersArgument{}
 
@@ -2,6 +2,9 @@ package parameters
 
 import (
 	"context"
+	"time"
+
+	"gorm.io/gorm"
 
 	"github.com/fighterlyt/common/twofactor"
 	"github.com/shopspring/decimal"
@@ -19,28 +22,69 @@ type Service interface {
 	AddParameters(parameters ...*Parameter) error
 	Modify(keyValue map[string]string, userID int64) error
 	GetHistory(key string, startTime, endTime int64, start, limit int) (allCount int64, histories []History, err error)
+	Rollback(key string, historyID int64, userID int64) error
+	Propose(keyValue map[string]string, userID int64) (proposal *Proposal, err error)
+	Approve(proposalID, userID int64) error
+	Reject(proposalID, userID int64) error
+	GetProposal(proposalID int64) (proposal *Proposal, err error)
+	GetProposals(status ProposalStatus, start, limit int) (allCount int64, proposals []Proposal, err error)
+	SetApproval(expire time.Duration)
+	Export(mask bool) (parameters []*Parameter, err error)
+	Import(parameters []*Parameter, userID int64, option ImportOption) (diff *ImportDiff, err error)
 	HelperService
+	Decoder
 	SetTwoFactorAuth(needTwoFactorKeys []string, auth twofactor.Auth)
 	SetValidate(validate ParameterValidate)
+	Watch(keys ...string) <-chan ParameterChange
+	Unwatch(ch <-chan ParameterChange)
 	model.Module
 }
 
 // ParameterService 参数服务
 type ParameterService interface {
 	Save(parameter *Parameter) error
+	All() (parameters []*Parameter, err error)
+	Delete(key string) error
 	GetParameters(keys ...string) (parameters map[string]*Parameter, err error)
 	Modify(key, value string) error
+	Check(keyValue map[string]string) error
+	Update(key, value string) error
+	Invalidate(keys ...string) error
+	WithTx(tx *gorm.DB) ParameterService
 }
 
 // HistoryService 历史服务
 type HistoryService interface {
 	Save(key, value string, userID int64) error
+	SaveRollback(key, value string, userID, rollbackID int64) error
+	SaveApproved(key, value string, proposerID, approverID, proposalID int64) error
+	SaveDeleted(key, value string, userID int64) error
+	GetByID(id int64) (history *History, err error)
+	WithTx(tx *gorm.DB) HistoryService
 	Get(key string, startTime, endTime int64, start, limit int) (count int64, histories []History, err error)
 }
 
+// ProposalService 提案服务
+type ProposalService interface {
+	Create(proposal *Proposal) error
+	Get(id int64) (proposal *Proposal, err error)
+	Review(id, reviewerID int64, status ProposalStatus) error
+	List(status ProposalStatus, start, limit int) (allCount int64, proposals []Proposal, err error)
+	WithTx(tx *gorm.DB) ProposalService
+}
+
 type HelperService interface {
 	GetInts(key, delimiter string) (value []int64, err error)
 	GetString(key string) (value string, err error)
 	GetDecimal(key string) (value decimal.Decimal, err error)
 	GetInt(key string) (value int64, err error)
+	GetDuration(key string, fallback ...time.Duration) (value time.Duration, err error)
+	GetBool(key string, fallback ...bool) (value bool, err error)
+	GetTronAddresses(key string, fallback ...[]string) (value []string, err error)
+}
+
+// Decoder 获取并解码参数,见 Get 和 GetJSON
+type Decoder interface {
+	// Decode 获取参数,验证后使用 decode 解码,结果缓存到参数变更
+	Decode(key, kind string, decode DecodeFunc) (value interface{}, exist bool, err error)
 }
@@ -139,6 +139,10 @@ type History struct {
 	Value      string `gorm:"column:value;type:varchar(1024);comment:值" valid:"stringlength(1|1024)"` // 值
 	UpdateTime int64  `gorm:"column:updateTime;type:bigint"`                                          // 最后更新时间
 	UserID     int64  `gorm:"column:userID;type:bigint;comment:修改用户ID"`                               // 修改用户ID
+	RollbackID int64  `gorm:"column:rollbackID;type:bigint;comment:回滚的来源记录ID,0表示非回滚"`                 // 回滚的来源记录ID,0表示非回滚
+	ProposalID int64  `gorm:"column:proposalID;type:bigint;comment:审批提案ID,0表示无需审批"`                   // 审批提案ID,0表示无需审批
+	ApproverID int64  `gorm:"column:approverID;type:bigint;comment:审批用户ID"`                           // 审批用户ID
+	Deleted    bool   `gorm:"column:deleted;comment:是否为删除记录,值为删除前的值"`                                 // 是否为删除记录,值为删除前的值
 }
 
 /*NewHistory 新建一个变更记录
@@ -158,6 +162,64 @@ func NewHistory(key, value string, userID int64) *History {
 	}
 }
 
+/*NewRollbackHistory 新建一个回滚变更记录
+参数:
+*	key       	string  	参数key
+*	value     	string  	回滚后的值
+*	userID    	int64   	操作用户ID
+*	rollbackID	int64   	回滚的来源记录ID
+返回值:
+*	*History  	*History	返回值1
+*/
+func NewRollbackHistory(key, value string, userID, rollbackID int64) *History {
+	history := NewHistory(key, value, userID)
+	history.RollbackID = rollbackID
+
+	return history
+}
+
+/*NewApprovedHistory 新建一个经过审批的变更记录
+参数:
+*	key       	string  	参数key
+*	value     	string  	最新值
+*	proposer  	int64   	提案用户ID
+*	approverID	int64   	审批用户ID
+*	proposalID	int64   	提案ID
+返回值:
+*	*History  	*History	返回值1
+*/
+func NewApprovedHistory(key, value string, proposerID, approverID, proposalID int64) *History {
+	history := NewHistory(key, value, proposerID)
+	history.ApproverID = approverID
+	history.ProposalID = proposalID
+
+	return history
+}
+
+/*NewDeletedHistory 新建一个删除记录
+参数:
+*	key     	string  	参数key
+*	value   	string  	删除前的值
+*	userID  	int64   	操作用户ID
+返回值:
+*	*History	*History	返回值1
+*/
+func NewDeletedHistory(key, value string, userID int64) *History {
+	history := NewHistory(key, value, userID)
+	history.Deleted = true
+
+	return history
+}
+
+/*IsRollback 是否为回滚记录
+参数:
+返回值:
+*	bool	bool	是否回滚
+*/
+func (h History) IsRollback() bool {
+	return h.RollbackID != 0
+}
+
 /*TableName mysql表名
 参数:
 返回值:
@@ -2,11 +2,13 @@ package parameters
 
 import (
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/fighterlyt/log"
 	"github.com/go-redis/redis/v8"
 	"github.com/pkg/errors"
+	"go.uber.org/multierr"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
@@ -110,6 +112,39 @@ func (p parameterService) getFromMysql(key string) (parameter *Parameter, err er
 }
 
 func (p parameterService) Modify(key, value string) error {
+	if err := p.check(key, value); err != nil {
+		return err
+	}
+
+	if err := p.deleteFromRedis(key); err != nil {
+		return errors.Wrap(err, `删除缓存`)
+	}
+
+	if err := p.updateMYSQL(key, value); err != nil {
+		return errors.Wrap(err, `更新MYSQL`)
+	}
+
+	return nil
+}
+
+/*
+Check 验证全部参数,返回所有未通过的key的错误
+参数:
+*	keyValue	map[string]string	key->value map
+返回值:
+*	err     	error            	错误
+*/
+func (p parameterService) Check(keyValue map[string]string) (err error) {
+	for _, key := range sortedKeys(keyValue) {
+		if singleErr := p.check(key, keyValue[key]); singleErr != nil {
+			err = multierr.Append(err, singleErr)
+		}
+	}
+
+	return err
+}
+
+func (p parameterService) check(key, value string) error {
 	parameter, err := p.get(key)
 	if err != nil {
 		return errors.Wrapf(err, `获取[%s]验证规则`, key)
@@ -121,18 +156,52 @@ func (p parameterService) Modify(key, value string) error {
 
 	parameter.Value = value
 	if err = parameter.Validate(); err != nil {
-		return fmt.Errorf(`错误信息: [%v],值[%s]不满足要求[%s]`, err, parameter.Value, parameter.Description)
+		return fmt.Errorf(`参数[%s]错误信息: [%v],值[%s]不满足要求[%s]`, key, err, parameter.Value, parameter.Description)
 	}
 
-	if err := p.deleteFromRedis(key); err != nil {
-		return errors.Wrap(err, `删除缓存`)
-	}
+	return nil
+}
 
-	if err := p.updateMYSQL(key, parameter.Value); err != nil {
-		return errors.Wrap(err, `更新MYSQL`)
+/*
+Update 只更新MYSQL,不验证也不处理缓存,配合 WithTx 在事务中使用
+参数:
+*	key  	string	key
+*	value	string	值
+返回值:
+*	error	error 	错误
+*/
+func (p parameterService) Update(key, value string) error {
+	return p.updateMYSQL(key, value)
+}
+
+/*
+Invalidate 删除缓存
+参数:
+*	keys 	...string	key
+返回值:
+*	error	error    	错误
+*/
+func (p parameterService) Invalidate(keys ...string) (err error) {
+	for _, key := range keys {
+		if singleErr := p.deleteFromRedis(key); singleErr != nil {
+			err = multierr.Append(err, singleErr)
+		}
 	}
 
-	return nil
+	return err
+}
+
+/*
+WithTx 使用事务
+参数:
+*	tx              	*gorm.DB        	事务
+返回值:
+*	ParameterService	ParameterService	使用事务的服务
+*/
+func (p parameterService) WithTx(tx *gorm.DB) ParameterService {
+	p.db = tx
+
+	return p
 }
 
 func (p parameterService) deleteFromRedis(key string) error {
@@ -157,6 +226,22 @@ func (p parameterService) updateMYSQL(key, value string) error {
 	return nil
 }
 
+func (p parameterService) All() (parameters []*Parameter, err error) {
+	if err = p.db.Model(p.model).Order(`elemKey`).Find(&parameters).Error; err != nil {
+		return nil, errors.Wrap(err, `获取全部参数`)
+	}
+
+	return parameters, nil
+}
+
+func (p parameterService) Delete(key string) error {
+	if err := p.db.Where(`elemKey = ?`, key).Delete(p.model).Error; err != nil {
+		return errors.Wrapf(err, `删除MYSQL key[%s]`, key)
+	}
+
+	return nil
+}
+
 func (p parameterService) Save(parameter *Parameter) error {
 	if err := parameter.Validate(); err != nil {
 		return errors.Wrapf(err, `校验失败`)
@@ -167,3 +252,16 @@ func (p parameterService) Save(parameter *Parameter) error {
 		DoNothing: true,
 	}).Create(parameter).Error
 }
+
+// sortedKeys 排序后的key,保证批量操作的顺序稳定
+func sortedKeys(keyValue map[string]string) []string {
+	keys := make([]string, 0, len(keyValue))
+
+	for key := range keyValue {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,200 @@
+package parameters
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/fighterlyt/log"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ProposalStatus 提案状态
+type ProposalStatus int
+
+const (
+	// ProposalPending 待审批
+	ProposalPending ProposalStatus = 1
+	// ProposalApproved 已通过
+	ProposalApproved ProposalStatus = 2
+	// ProposalRejected 已拒绝
+	ProposalRejected ProposalStatus = 3
+	// ProposalExpired 已过期
+	ProposalExpired ProposalStatus = 4
+)
+
+// Proposal 参数修改提案,需要另一个用户审批后才生效
+type Proposal struct {
+	ID         int64             `gorm:"column:id;primaryKey;comment:id" json:"id"`
+	Content    string            `gorm:"column:content;type:text;comment:修改的参数,JSON" json:"-"`           // 修改的参数,JSON
+	ProposerID int64             `gorm:"column:proposerID;type:bigint;comment:提案用户ID" json:"proposerID"` // 提案用户ID
+	ReviewerID int64             `gorm:"column:reviewerID;type:bigint;comment:审批用户ID" json:"reviewerID"` // 审批用户ID
+	Status     ProposalStatus    `gorm:"column:status;index;comment:状态" json:"status"`                   // 状态
+	CreateTime int64             `gorm:"column:createTime;type:bigint;comment:创建时间" json:"createTime"`   // 创建时间
+	ExpireTime int64             `gorm:"column:expireTime;type:bigint;comment:过期时间" json:"expireTime"`   // 过期时间
+	UpdateTime int64             `gorm:"column:updateTime;type:bigint;comment:更新时间" json:"updateTime"`   // 更新时间
+	Parameters map[string]string `gorm:"-" json:"parameters"`                                            // 修改的参数
+}
+
+/*
+NewProposal 新建提案
+参数:
+*	keyValue  	map[string]string	修改的参数
+*	proposerID	int64            	提案用户ID
+*	expire    	time.Duration    	有效期
+返回值:
+*	*Proposal 	*Proposal        	提案
+*/
+func NewProposal(keyValue map[string]string, proposerID int64, expire time.Duration) *Proposal {
+	now := time.Now()
+
+	return &Proposal{
+		Parameters: keyValue,
+		ProposerID: proposerID,
+		Status:     ProposalPending,
+		CreateTime: now.Unix(),
+		ExpireTime: now.Add(expire).Unix(),
+		UpdateTime: now.Unix(),
+	}
+}
+
+/*
+IsExpired 是否已经过期
+参数:
+返回值:
+*	bool	bool	是否过期
+*/
+func (p Proposal) IsExpired() bool {
+	return time.Now().Unix() > p.ExpireTime
+}
+
+/*
+BeforeSave gorm hook,序列化参数
+参数:
+*	tx   	*gorm.DB	数据库
+返回值:
+*	error	error   	错误
+*/
+func (p *Proposal) BeforeSave(_ *gorm.DB) error {
+	data, err := json.Marshal(p.Parameters)
+	if err != nil {
+		return errors.Wrap(err, `序列化参数`)
+	}
+
+	p.Content = string(data)
+
+	return nil
+}
+
+/*
+AfterFind gorm hook,反序列化参数
+参数:
+*	tx   	*gorm.DB	数据库
+返回值:
+*	error	error   	错误
+*/
+func (p *Proposal) AfterFind(_ *gorm.DB) error {
+	if p.Content == `` {
+		return nil
+	}
+
+	return errors.Wrap(json.Unmarshal([]byte(p.Content), &p.Parameters), `反序列化参数`)
+}
+
+/*
+TableName mysql表名
+参数:
+返回值:
+*	string	string	表名
+*/
+func (Proposal) TableName() string {
+	return `parameters_proposal`
+}
+
+type proposalService struct {
+	db     *gorm.DB
+	logger log.Logger
+	model  *Proposal
+}
+
+func newProposalService(db *gorm.DB, logger log.Logger) *proposalService {
+	return &proposalService{db: db, logger: logger, model: &Proposal{}}
+}
+
+func (p proposalService) Create(proposal *Proposal) error {
+	if err := p.db.Create(proposal).Error; err != nil {
+		return errors.Wrap(err, `保存数据错误`)
+	}
+
+	return nil
+}
+
+func (p proposalService) Get(id int64) (proposal *Proposal, err error) {
+	proposal = &Proposal{}
+
+	if err = p.db.Model(p.model).Where(`id = ?`, id).First(proposal).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+
+		return nil, errors.Wrapf(err, `获取数据,id=[%d]`, id)
+	}
+
+	return proposal, nil
+}
+
+/*
+Review 审批,只有待审批的提案可以被修改,防止并发审批
+参数:
+*	id        	int64         	提案ID
+*	reviewerID	int64         	审批用户ID
+*	status    	ProposalStatus	审批后的状态
+返回值:
+*	error     	error         	错误
+*/
+func (p proposalService) Review(id, reviewerID int64, status ProposalStatus) error {
+	// BeforeSave 会修改 Model,每次使用新的对象,避免并发审批时竞争
+	result := p.db.Model(&Proposal{}).Where(`id = ? and status = ?`, id, ProposalPending).Updates(map[string]interface{}{
+		`reviewerID`: reviewerID,
+		`status`:     status,
+		`updateTime`: time.Now().Unix(),
+	})
+
+	if result.Error != nil {
+		return errors.Wrapf(result.Error, `更新数据,id=[%d]`, id)
+	}
+
+	if result.RowsAffected != 1 {
+		return fmt.Errorf(`提案[%d]已经被处理`, id)
+	}
+
+	return nil
+}
+
+func (p proposalService) List(status ProposalStatus, start, limit int) (allCount int64, proposals []Proposal, err error) {
+	p.logger.Debug(`获取数据`, zap.Int(`状态`, int(status)), zap.Ints(`开始/数量`, []int{start, limit}))
+
+	query := p.db.Model(p.model)
+
+	if status != 0 {
+		query = query.Where(`status = ?`, status)
+	}
+
+	if err = query.Count(&allCount).Error; err != nil {
+		return 0, nil, errors.Wrap(err, `统计数量`)
+	}
+
+	if err = query.Limit(limit).Offset(start).Order(`createTime desc`).Find(&proposals).Error; err != nil {
+		return 0, nil, errors.Wrapf(err, `获取数据,start=[%d],limit=[%d]`, start, limit)
+	}
+
+	return allCount, proposals, nil
+}
+
+func (p proposalService) WithTx(tx *gorm.DB) ProposalService {
+	p.db = tx
+
+	return p
+}
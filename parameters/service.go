@@ -23,6 +23,11 @@ const (
 	name = `参数管理`
 )
 
+var (
+	// ErrNeedApproval 开启审批后,需要二次验证的参数只能通过提案修改
+	ErrNeedApproval = errors.New(`参数需要审批,请提交提案`)
+)
+
 type service struct {
 	db                *gorm.DB         // 数据库
 	client            *redis.Client    // redis
@@ -34,10 +39,15 @@ type service struct {
 	auth              twofactor.Auth   // 验证器
 	needTwoFactorKeys []string         // 需要二次验证的key
 	validate          ParameterValidate
+	watcher           *watcher      // 变更通知
+	decoded           *decodedCache // 类型化参数缓存
+	proposal          ProposalService
+	approvalExpire    time.Duration // 提案有效期,0表示不需要审批
 }
 
 func (s *service) Close() {
 	s.shutdown.Close()
+	s.watcher.Close()
 }
 
 func (s *service) Add(count int64) {
@@ -72,16 +82,20 @@ func NewService(db *gorm.DB, client *redis.Client, logger log.Logger, iRouter gi
 		return nil, fmt.Errorf(`未初始化，必须调用初始化方法Init()`)
 	}
 
+	redisExpire := time.Minute
 	history := newHistoryService(db, logger.Derive(`变更管理器`))
-	parameter := newParameterService(client, db, logger.Derive(`数据管理器`), time.Minute)
+	parameter := newParameterService(client, db, logger.Derive(`数据管理器`), redisExpire)
 	targetService = &service{
 		db:        db,
 		client:    client,
 		history:   history,
 		parameter: parameter,
+		proposal:  newProposalService(db, logger.Derive(`提案管理器`)),
 		logger:    logger,
 		router:    iRouter,
 		shutdown:  model.NewShutdown(),
+		watcher:   newWatcher(client, logger.Derive(`变更通知`)),
+		decoded:   newDecodedCache(redisExpire),
 	}
 
 	moduleLogger = logger
@@ -103,7 +117,7 @@ func (s *service) GetParameters(keys ...string) (parameters map[string]*Paramete
 }
 
 /*
-Modify 修改参数
+Modify 修改参数,全部成功或全部失败
 参数:
 *	keyValue	map[string]string	key->value map
 *	userID  	int64               用户ID
@@ -117,19 +131,330 @@ func (s *service) Modify(keyValue map[string]string, userID int64) error {
 		}
 	}
 
-	for key, value := range keyValue {
-		if err := s.parameter.Modify(key, value); err != nil {
-			return errors.Wrap(err, `修改数据`)
+	return s.commit(keyValue, userID, false, nil, func(history HistoryService, key, value string) error {
+		return history.Save(key, value, userID)
+	})
+}
+
+/*
+commit 验证全部参数后在一个事务中修改参数并保存变更记录,全部成功后才删除缓存并通知.
+需要审批的参数只有审批通过的提案可以修改
+参数:
+*	keyValue   	map[string]string                                      	key->value map
+*	userID     	int64                                                  	用户ID
+*	approved   	bool                                                   	是否来自审批通过的提案
+*	before     	func(tx *gorm.DB) error                                	在同一个事务中先执行,可以为nil
+*	saveHistory	func(history HistoryService, key, value string) error	保存变更记录
+返回值:
+*	error      	error                                                  	错误,验证失败时包含全部未通过的key
+*/
+func (s *service) commit(keyValue map[string]string, userID int64, approved bool, before func(tx *gorm.DB) error,
+	saveHistory func(history HistoryService, key, value string) error) error {
+	if !approved && s.needApproval(keyValue) {
+		return ErrNeedApproval
+	}
+
+	if err := s.parameter.Check(keyValue); err != nil {
+		return errors.Wrap(err, `参数验证失败`)
+	}
+
+	keys := sortedKeys(keyValue)
+
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		if before != nil {
+			if err := before(tx); err != nil {
+				return err
+			}
 		}
 
-		if err := s.history.Save(key, value, userID); err != nil {
-			s.logger.Warn(`保存变更记录失败`, zap.String(`错误`, err.Error()))
+		parameter, history := s.parameter.WithTx(tx), s.history.WithTx(tx)
+
+		for _, key := range keys {
+			if err := parameter.Update(key, keyValue[key]); err != nil {
+				return errors.Wrapf(err, `修改[%s]`, key)
+			}
+
+			if err := saveHistory(history, key, keyValue[key]); err != nil {
+				return errors.Wrapf(err, `保存[%s]变更记录`, key)
+			}
 		}
+
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, `修改数据`)
+	}
+
+	if err := s.parameter.Invalidate(keys...); err != nil {
+		s.logger.Warn(`删除缓存失败`, zap.String(`错误`, err.Error()))
+	}
+
+	changes := make([]ParameterChange, 0, len(keys))
+
+	for _, key := range keys {
+		changes = append(changes, NewParameterChange(key, keyValue[key], userID))
 	}
 
+	s.publish(changes...)
+
 	return nil
 }
 
+/*
+Rollback 将参数回滚到历史记录中的值,值会重新验证,并记录一条回滚变更
+参数:
+*	key      	string	参数key
+*	historyID	int64 	历史记录ID
+*	userID   	int64 	用户ID
+返回值:
+*	error    	error 	错误
+*/
+func (s *service) Rollback(key string, historyID, userID int64) error {
+	history, err := s.history.GetByID(historyID)
+	if err != nil {
+		return errors.Wrapf(err, `获取变更记录[%d]`, historyID)
+	}
+
+	if history == nil {
+		return fmt.Errorf(`变更记录[%d]不存在`, historyID)
+	}
+
+	if history.Key != key {
+		return fmt.Errorf(`变更记录[%d]属于参数[%s],不是[%s]`, historyID, history.Key, key)
+	}
+
+	if history.Deleted {
+		return fmt.Errorf(`变更记录[%d]是删除记录,不能回滚,请重新导入参数`, historyID)
+	}
+
+	keyValue := map[string]string{key: history.Value}
+
+	if s.validate != nil {
+		if err = s.validate.Validate(keyValue); err != nil {
+			return errors.Wrap(err, "参数验证失败")
+		}
+	}
+
+	return s.commit(keyValue, userID, false, nil, func(historyService HistoryService, key, value string) error {
+		return historyService.SaveRollback(key, value, userID, historyID)
+	})
+}
+
+/*
+SetApproval 开启审批,开启后需要二次验证的参数只能通过提案修改
+参数:
+*	expire	time.Duration	提案有效期,0表示关闭审批
+返回值:
+*/
+func (s *service) SetApproval(expire time.Duration) {
+	s.approvalExpire = expire
+}
+
+/*
+needApproval 修改的参数是否需要审批
+参数:
+*	keyValue	map[string]string	修改的参数
+返回值:
+*	bool    	bool             	是否需要审批
+*/
+func (s *service) needApproval(keyValue map[string]string) bool {
+	return s.approvalExpire > 0 && s.needTwoFactor(keyValue)
+}
+
+/*
+Propose 提交修改提案,参数会被验证,但是审批通过后才生效
+参数:
+*	keyValue	map[string]string	key->value map
+*	userID  	int64            	提案用户ID
+返回值:
+*	proposal	*Proposal        	提案
+*	err     	error            	错误
+*/
+func (s *service) Propose(keyValue map[string]string, userID int64) (proposal *Proposal, err error) {
+	if s.approvalExpire <= 0 {
+		return nil, errors.New(`未开启审批`)
+	}
+
+	if s.validate != nil {
+		if err = s.validate.Validate(keyValue); err != nil {
+			return nil, errors.Wrap(err, "参数验证失败")
+		}
+	}
+
+	if err = s.parameter.Check(keyValue); err != nil {
+		return nil, errors.Wrap(err, `参数验证失败`)
+	}
+
+	proposal = NewProposal(keyValue, userID, s.approvalExpire)
+
+	if err = s.proposal.Create(proposal); err != nil {
+		return nil, errors.Wrap(err, `保存提案`)
+	}
+
+	return proposal, nil
+}
+
+/*
+Approve 审批通过,审批人不能是提案人,审批和修改在同一个事务中
+参数:
+*	proposalID	int64	提案ID
+*	userID    	int64	审批用户ID
+返回值:
+*	error     	error	错误
+*/
+func (s *service) Approve(proposalID, userID int64) error {
+	proposal, err := s.pendingProposal(proposalID)
+	if err != nil {
+		return err
+	}
+
+	if proposal.ProposerID == userID {
+		return fmt.Errorf(`提案[%d]不能由提案人审批`, proposalID)
+	}
+
+	if s.validate != nil {
+		if err = s.validate.Validate(proposal.Parameters); err != nil {
+			return errors.Wrap(err, "参数验证失败")
+		}
+	}
+
+	return s.commit(proposal.Parameters, userID, true, func(tx *gorm.DB) error {
+		return s.proposal.WithTx(tx).Review(proposalID, userID, ProposalApproved)
+	}, func(history HistoryService, key, value string) error {
+		return history.SaveApproved(key, value, proposal.ProposerID, userID, proposalID)
+	})
+}
+
+/*
+Reject 拒绝提案
+参数:
+*	proposalID	int64	提案ID
+*	userID    	int64	审批用户ID
+返回值:
+*	error     	error	错误
+*/
+func (s *service) Reject(proposalID, userID int64) error {
+	if _, err := s.pendingProposal(proposalID); err != nil {
+		return err
+	}
+
+	return s.proposal.Review(proposalID, userID, ProposalRejected)
+}
+
+/*
+pendingProposal 获取待审批的提案,已过期的提案会被标记为过期
+参数:
+*	proposalID	int64    	提案ID
+返回值:
+*	proposal  	*Proposal	提案
+*	err       	error    	错误
+*/
+func (s *service) pendingProposal(proposalID int64) (proposal *Proposal, err error) {
+	if proposal, err = s.proposal.Get(proposalID); err != nil {
+		return nil, errors.Wrapf(err, `获取提案[%d]`, proposalID)
+	}
+
+	if proposal == nil {
+		return nil, fmt.Errorf(`提案[%d]不存在`, proposalID)
+	}
+
+	if proposal.Status != ProposalPending {
+		return nil, fmt.Errorf(`提案[%d]已经被处理`, proposalID)
+	}
+
+	if proposal.IsExpired() {
+		if err = s.proposal.Review(proposalID, 0, ProposalExpired); err != nil {
+			s.logger.Warn(`标记提案过期失败`, zap.String(`错误`, err.Error()))
+		}
+
+		return nil, fmt.Errorf(`提案[%d]已经过期`, proposalID)
+	}
+
+	return proposal, nil
+}
+
+/*
+GetProposal 获取提案
+参数:
+*	proposalID	int64    	提案ID
+返回值:
+*	proposal  	*Proposal	提案,不存在时为nil
+*	err       	error    	错误
+*/
+func (s *service) GetProposal(proposalID int64) (proposal *Proposal, err error) {
+	return s.proposal.Get(proposalID)
+}
+
+/*
+GetProposals 获取提案列表
+参数:
+*	status   	ProposalStatus	状态,0表示全部
+*	start    	int           	开始位置，0开始
+*	limit    	int           	限量
+返回值:
+*	allCount 	int64         	总数
+*	proposals	[]Proposal    	提案
+*	err      	error         	错误
+*/
+func (s *service) GetProposals(status ProposalStatus, start, limit int) (allCount int64, proposals []Proposal, err error) {
+	return s.proposal.List(status, start, limit)
+}
+
+/*
+Watch 订阅参数变更,任意实例修改参数后，所有实例都会收到通知
+参数:
+*	keys                  	...string             	关注的key,为空表示全部
+返回值:
+*	<-chan ParameterChange	<-chan ParameterChange	通知通道,服务关闭时被关闭
+*/
+func (s *service) Watch(keys ...string) <-chan ParameterChange {
+	return s.watcher.Watch(keys...)
+}
+
+/*
+Unwatch 取消订阅
+参数:
+*	ch	<-chan ParameterChange	Watch 返回的通道
+返回值:
+*/
+func (s *service) Unwatch(ch <-chan ParameterChange) {
+	s.watcher.Unwatch(ch)
+}
+
+/*
+invalidateOnChange 其他实例修改参数时，使本地的类型化缓存失效
+参数:
+返回值:
+*/
+func (s *service) invalidateOnChange() {
+	changes := s.watcher.Watch()
+
+	helpers.EnsureGo(s.logger, func() {
+		for change := range changes {
+			s.decoded.Invalidate(change.Key)
+		}
+	})
+}
+
+/*
+publish 发布变更通知,失败只记录日志,不影响已经完成的修改
+参数:
+*	changes	...ParameterChange	变更通知
+返回值:
+*/
+func (s *service) publish(changes ...ParameterChange) {
+	if len(changes) == 0 {
+		return
+	}
+
+	for _, change := range changes {
+		s.decoded.Invalidate(change.Key)
+	}
+
+	if err := s.watcher.Publish(changes...); err != nil {
+		s.logger.Warn(`发布变更通知失败`, zap.String(`错误`, err.Error()))
+	}
+}
+
 /*
 AddParameters 添加参数
 参数:
@@ -143,6 +468,8 @@ func (s *service) AddParameters(parameters ...*Parameter) error {
 			return errors.Wrapf(err, `保存参数[%s]失败`, parameter.Key)
 		}
 
+		s.decoded.Invalidate(parameter.Key)
+
 		if err := s.history.Save(parameter.Key, parameter.Value, -1); err != nil {
 			return errors.Wrapf(err, `保存[%s]变更记录失败`, parameter.Key)
 		}
@@ -173,6 +500,8 @@ Create 创建参数
 *	error    	error     	错误
 */
 func (s *service) Create(parameter *Parameter) error {
+	defer s.decoded.Invalidate(parameter.Key)
+
 	return s.parameter.Save(parameter)
 }
 
@@ -191,6 +520,12 @@ func (s *service) init() error {
 		return errors.Wrap(err, `加载配置数据`)
 	}
 
+	if err := s.watcher.Start(); err != nil {
+		return errors.Wrap(err, `订阅变更通知`)
+	}
+
+	s.invalidateOnChange()
+
 	s.http()
 
 	return nil
@@ -211,6 +546,10 @@ func (s *service) dbInit() error {
 		return errors.Wrap(err, `创建记录表`)
 	}
 
+	if err := s.db.AutoMigrate(&Proposal{}); err != nil {
+		return errors.Wrap(err, `创建提案表`)
+	}
+
 	return nil
 }
 
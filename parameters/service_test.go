@@ -2,6 +2,7 @@ package parameters
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -59,3 +60,194 @@ func TestService_AddParameters(t *testing.T) {
 	require.EqualValues(t, parameter.Value, history[0].Value, `值相同`)
 	require.EqualValues(t, -1, history[0].UserID, `操作人员相同`)
 }
+
+func TestService_Watch(t *testing.T) {
+	userID := int64(3)
+	key := `test:a:c`
+	newValue := `5`
+
+	parameter := NewParameter(key, `测试`, `1`, `测试1`, `positiveInteger`)
+	require.NoError(t, testService.Create(parameter))
+
+	ch := testService.Watch(key)
+	defer testService.Unwatch(ch)
+
+	require.NoError(t, testService.Modify(map[string]string{key: newValue}, userID), `修改`)
+
+	select {
+	case change := <-ch:
+		require.EqualValues(t, key, change.Key, `key相同`)
+		require.EqualValues(t, newValue, change.Value, `值相同`)
+		require.EqualValues(t, userID, change.UserID, `操作人员相同`)
+	case <-time.After(time.Second):
+		t.Fatal(`未收到变更通知`)
+	}
+}
+
+func TestService_Rollback(t *testing.T) {
+	userID := int64(3)
+	key := `test:a:d`
+
+	parameter := NewParameter(key, `测试`, `1`, `测试1`, `positiveInteger`)
+	require.NoError(t, testService.Create(parameter))
+
+	require.NoError(t, testService.Modify(map[string]string{key: `2`}, userID), `修改`)
+	require.NoError(t, testService.Modify(map[string]string{key: `3`}, userID), `再次修改`)
+
+	_, history, err := testService.GetHistory(key, 0, 0, 0, 10)
+	require.NoError(t, err, `获取变更记录`)
+	require.EqualValues(t, 2, len(history), `两条`)
+
+	var target History
+
+	for _, elem := range history {
+		if elem.Value == `2` {
+			target = elem
+		}
+	}
+
+	require.NotZero(t, target.ID, `找到第一次修改`)
+
+	require.NoError(t, testService.Rollback(key, target.ID, userID), `回滚`)
+
+	newParameter, err := testService.GetParameters(key)
+	require.NoError(t, err, `获取回滚后的`)
+	require.EqualValues(t, `2`, newParameter[key].Value, `值相同`)
+
+	_, history, err = testService.GetHistory(key, 0, 0, 0, 10)
+	require.NoError(t, err, `获取变更记录`)
+	require.EqualValues(t, 3, len(history), `三条`)
+
+	rollbackCount := 0
+
+	for _, elem := range history {
+		if elem.IsRollback() {
+			require.EqualValues(t, target.ID, elem.RollbackID, `回滚来源`)
+			rollbackCount++
+		}
+	}
+
+	require.EqualValues(t, 1, rollbackCount, `一条回滚记录`)
+
+	require.Error(t, testService.Rollback(`test:a:b`, target.ID, userID), `key不匹配`)
+}
+
+func TestService_ModifyAtomic(t *testing.T) {
+	userID := int64(3)
+	first, second, third := `test:b:a`, `test:b:b`, `test:b:c`
+
+	for _, key := range []string{first, second, third} {
+		require.NoError(t, testService.Create(NewParameter(key, `测试`, `1`, `测试1`, `positiveInteger`)))
+	}
+
+	err := testService.Modify(map[string]string{first: `2`, second: `-1`, third: `a`}, userID)
+	require.Error(t, err, `部分参数非法`)
+	require.Contains(t, err.Error(), second, `包含全部失败的key`)
+	require.Contains(t, err.Error(), third, `包含全部失败的key`)
+
+	parameters, err := testService.GetParameters(first)
+	require.NoError(t, err)
+	require.EqualValues(t, `1`, parameters[first].Value, `未修改`)
+
+	_, history, err := testService.GetHistory(first, 0, 0, 0, 10)
+	require.NoError(t, err)
+	require.EqualValues(t, 0, len(history), `没有变更记录`)
+}
+
+func TestService_Approve(t *testing.T) {
+	proposerID, approverID := int64(3), int64(4)
+	key := `test:c:a`
+
+	require.NoError(t, testService.Create(NewParameter(key, `测试`, `1`, `测试1`, `positiveInteger`)))
+
+	testService.SetApproval(time.Minute)
+	testService.SetTwoFactorAuth([]string{key}, nil)
+
+	defer func() {
+		testService.SetApproval(0)
+		testService.SetTwoFactorAuth(nil, nil)
+	}()
+
+	require.ErrorIs(t, testService.Modify(map[string]string{key: `2`}, proposerID), ErrNeedApproval, `不能直接修改`)
+
+	_, err := testService.Import([]*Parameter{NewParameter(key, `测试`, `2`, `测试1`, `positiveInteger`)}, proposerID, ImportOption{})
+	require.ErrorIs(t, err, ErrNeedApproval, `不能通过导入修改`)
+
+	proposal, err := testService.Propose(map[string]string{key: `2`}, proposerID)
+	require.NoError(t, err, `提交提案`)
+
+	parameters, err := testService.GetParameters(key)
+	require.NoError(t, err)
+	require.EqualValues(t, `1`, parameters[key].Value, `审批前未生效`)
+
+	require.Error(t, testService.Approve(proposal.ID, proposerID), `提案人不能审批`)
+	require.NoError(t, testService.Approve(proposal.ID, approverID), `审批`)
+	require.Error(t, testService.Approve(proposal.ID, approverID), `不能重复审批`)
+
+	parameters, err = testService.GetParameters(key)
+	require.NoError(t, err)
+	require.EqualValues(t, `2`, parameters[key].Value, `审批后生效`)
+
+	_, history, err := testService.GetHistory(key, 0, 0, 0, 10)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, len(history), `一条变更记录`)
+	require.EqualValues(t, proposerID, history[0].UserID, `提案人`)
+	require.EqualValues(t, approverID, history[0].ApproverID, `审批人`)
+	require.EqualValues(t, proposal.ID, history[0].ProposalID, `提案`)
+}
+
+func TestService_Import(t *testing.T) {
+	userID := int64(3)
+	changed, added := `test:d:a`, `test:d:b`
+
+	require.NoError(t, testService.Create(NewParameter(changed, `测试`, `1`, `测试1`, `positiveInteger`)))
+
+	exported, err := testService.Export(true)
+	require.NoError(t, err, `导出`)
+
+	for _, parameter := range exported {
+		if parameter.Key == changed {
+			parameter.Value = `2`
+		}
+	}
+
+	exported = append(exported, NewParameter(added, `测试`, `1`, `测试1`, `positiveInteger`))
+
+	diff, err := testService.Import(exported, userID, ImportOption{DryRun: true})
+	require.NoError(t, err, `计算差异`)
+	require.EqualValues(t, 1, len(diff.Added), `一个新增`)
+	require.EqualValues(t, 1, len(diff.Changed), `一个修改`)
+	require.EqualValues(t, 0, len(diff.Removed), `没有删除`)
+
+	parameters, err := testService.GetParameters(changed, added)
+	require.NoError(t, err)
+	require.EqualValues(t, `1`, parameters[changed].Value, `dryRun未修改`)
+	require.Nil(t, parameters[added], `dryRun未新增`)
+
+	_, err = testService.Import(exported, userID, ImportOption{})
+	require.NoError(t, err, `导入`)
+
+	parameters, err = testService.GetParameters(changed, added)
+	require.NoError(t, err)
+	require.EqualValues(t, `2`, parameters[changed].Value, `已修改`)
+	require.NotNil(t, parameters[added], `已新增`)
+
+	diff, err = testService.Import(exported[:len(exported)-1], userID, ImportOption{Prune: true})
+	require.NoError(t, err, `删除`)
+	require.EqualValues(t, 1, len(diff.Removed), `一个删除`)
+
+	_, history, err := testService.GetHistory(added, 0, 0, 0, 10)
+	require.NoError(t, err)
+
+	var deleted *History
+
+	for i := range history {
+		if history[i].Deleted {
+			deleted = &history[i]
+		}
+	}
+
+	require.NotNil(t, deleted, `保存删除记录`)
+	require.EqualValues(t, `1`, deleted.Value, `删除前的值`)
+	require.Error(t, testService.Rollback(added, deleted.ID, userID), `删除记录不能回滚`)
+}
@@ -0,0 +1,371 @@
+package parameters
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// ListDelimiter 列表类参数的分隔符,和 tronAddresses 验证器一致
+	ListDelimiter = `,`
+)
+
+// DecodeFunc 将参数值解码为目标类型
+type DecodeFunc func(value string) (interface{}, error)
+
+// decodedKey 缓存key,同一个参数可以按不同方式解码
+type decodedKey struct {
+	key  string
+	kind string
+}
+
+// decodedValue 缓存的解码结果
+type decodedValue struct {
+	value  interface{}
+	raw    string // 参数原始值,可变的解码结果每次从原始值重新解码
+	exist  bool
+	expire time.Time
+}
+
+// decodedCache 解码结果缓存,参数变更时失效,expire 兜底防止漏掉变更通知
+type decodedCache struct {
+	lock   *sync.RWMutex
+	data   map[decodedKey]decodedValue
+	expire time.Duration
+}
+
+func newDecodedCache(expire time.Duration) *decodedCache {
+	return &decodedCache{
+		lock:   &sync.RWMutex{},
+		data:   make(map[decodedKey]decodedValue),
+		expire: expire,
+	}
+}
+
+func (d *decodedCache) get(key decodedKey) (value decodedValue, ok bool) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	if value, ok = d.data[key]; !ok || time.Now().After(value.expire) {
+		return value, false
+	}
+
+	return value, true
+}
+
+func (d *decodedCache) set(key decodedKey, value interface{}, raw string, exist bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.data[key] = decodedValue{
+		value:  value,
+		raw:    raw,
+		exist:  exist,
+		expire: time.Now().Add(d.expire),
+	}
+}
+
+/*
+Invalidate 使参数的所有解码结果失效
+参数:
+*	keys	...string	参数key
+返回值:
+*/
+func (d *decodedCache) Invalidate(keys ...string) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for _, key := range keys {
+		for cacheKey := range d.data {
+			if cacheKey.key == key {
+				delete(d.data, cacheKey)
+			}
+		}
+	}
+}
+
+/*
+Decode 获取并解码参数,解码前使用参数的验证方法验证,结果缓存到参数变更
+参数:
+*	key        	string     	参数key
+*	kind       	string     	解码方式,相同key不同kind分别缓存
+*	decode     	DecodeFunc 	解码方法
+返回值:
+*	value      	interface{}	解码结果
+*	exist      	bool       	参数是否存在
+*	err        	error      	错误
+*/
+func (s *service) Decode(key, kind string, decode DecodeFunc) (value interface{}, exist bool, err error) {
+	return s.decode(key, kind, decode, true)
+}
+
+/*
+decode 获取并解码参数,结果缓存到参数变更.切片、map、指针、结构体等可变的结果被调用方修改会影响其他调用方,
+只缓存验证结果和原始值,每次重新解码
+参数:
+*	key     	string     	参数key
+*	kind    	string     	解码方式,相同key不同kind分别缓存
+*	decode  	DecodeFunc 	解码方法
+*	validate	bool       	是否先使用参数的验证方法验证,旧版的 GetString 等方法不验证
+返回值:
+*	value   	interface{}	解码结果
+*	exist   	bool       	参数是否存在
+*	err     	error      	错误
+*/
+func (s *service) decode(key, kind string, decode DecodeFunc, validate bool) (value interface{}, exist bool, err error) {
+	if !validate {
+		kind = `raw:` + kind
+	}
+
+	cacheKey := decodedKey{key: key, kind: kind}
+
+	if cached, ok := s.decoded.get(cacheKey); ok {
+		if !cached.exist || !mutable(cached.value) {
+			return cached.value, cached.exist, nil
+		}
+
+		if value, err = decode(cached.raw); err != nil {
+			return nil, true, errors.Wrapf(err, `参数[%s]解码为[%s]`, key, kind)
+		}
+
+		return value, true, nil
+	}
+
+	var (
+		result map[string]*Parameter
+		raw    string
+	)
+
+	if result, err = s.GetParameters(key); err != nil {
+		return nil, false, errors.Wrapf(err, `获取业务参数[%s]`, key)
+	}
+
+	if parameter := result[key]; parameter != nil {
+		if validate {
+			if err = parameter.Validate(); err != nil {
+				return nil, true, errors.Wrapf(err, `参数[%s]验证失败`, key)
+			}
+		}
+
+		if value, err = decode(parameter.Value); err != nil {
+			return nil, true, errors.Wrapf(err, `参数[%s]解码为[%s]`, key, kind)
+		}
+
+		raw, exist = parameter.Value, true
+	}
+
+	s.decoded.set(cacheKey, value, raw, exist)
+
+	return value, exist, nil
+}
+
+/*
+Get 获取参数并解码为T,支持 string,bool,int,int64,float64,decimal.Decimal,time.Duration,[]string,[]int64,
+其他类型按JSON解码
+参数:
+*	helper  	Decoder      	参数服务
+*	key     	string       	参数key
+*	fallback	...T         	参数不存在时的默认值,不传时参数不存在返回错误
+返回值:
+*	value   	T            	值
+*	err     	error        	错误
+*/
+func Get[T any](helper Decoder, key string, fallback ...T) (value T, err error) {
+	kind := reflect.TypeOf(&value).Elem().String()
+
+	return get[T](helper, key, kind, decoderOf[T](), fallback...)
+}
+
+/*
+GetJSON 获取参数并按JSON解码为T
+参数:
+*	helper  	Decoder      	参数服务
+*	key     	string       	参数key
+*	fallback	...T         	参数不存在时的默认值,不传时参数不存在返回错误
+返回值:
+*	value   	T            	值
+*	err     	error        	错误
+*/
+func GetJSON[T any](helper Decoder, key string, fallback ...T) (value T, err error) {
+	kind := `json:` + reflect.TypeOf(&value).Elem().String()
+
+	return get[T](helper, key, kind, decodeJSON[T], fallback...)
+}
+
+func get[T any](helper Decoder, key, kind string, decode DecodeFunc, fallback ...T) (value T, err error) {
+	return fromDecoded[T](key, fallback)(helper.Decode(key, kind, decode))
+}
+
+// getRaw 不验证参数,用于旧版的 GetString 等方法
+func getRaw[T any](s *service, key, kind string, decode DecodeFunc) (value T, err error) {
+	return fromDecoded[T](key, nil)(s.decode(key, kind, decode, false))
+}
+
+func fromDecoded[T any](key string, fallback []T) func(result interface{}, exist bool, err error) (T, error) {
+	return func(result interface{}, exist bool, err error) (value T, _ error) {
+		if err != nil {
+			return value, err
+		}
+
+		if !exist {
+			if len(fallback) == 0 {
+				return value, fmt.Errorf("key[%s]不存在", key)
+			}
+
+			return fallback[0], nil
+		}
+
+		return result.(T), nil
+	}
+}
+
+// mutable 解码结果是否可能被调用方修改,decimal.Decimal 和 time.Time 的方法不修改自身,按标量处理
+func mutable(value interface{}) bool {
+	switch value.(type) {
+	case nil, decimal.Decimal, time.Time:
+		return false
+	}
+
+	switch reflect.TypeOf(value).Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return false
+	default:
+		return true
+	}
+}
+
+func decoderOf[T any]() DecodeFunc {
+	var zero T
+
+	switch any(zero).(type) {
+	case string:
+		return func(value string) (interface{}, error) {
+			return value, nil
+		}
+	case bool:
+		return decodeBool
+	case int:
+		return func(value string) (interface{}, error) {
+			return strconv.Atoi(value)
+		}
+	case int64:
+		return func(value string) (interface{}, error) {
+			return strconv.ParseInt(value, 10, 64)
+		}
+	case float64:
+		return func(value string) (interface{}, error) {
+			return strconv.ParseFloat(value, 64)
+		}
+	case decimal.Decimal:
+		return func(value string) (interface{}, error) {
+			return decimal.NewFromString(value)
+		}
+	case time.Duration:
+		return func(value string) (interface{}, error) {
+			return time.ParseDuration(value)
+		}
+	case []string:
+		return func(value string) (interface{}, error) {
+			return strings.Split(value, ListDelimiter), nil
+		}
+	case []int64:
+		return func(value string) (interface{}, error) {
+			return decodeInts(value, ListDelimiter)
+		}
+	default:
+		return decodeJSON[T]
+	}
+}
+
+func decodeJSON[T any](value string) (interface{}, error) {
+	var result T
+
+	if err := json.Unmarshal([]byte(value), &result); err != nil {
+		return nil, errors.Wrap(err, `JSON解码`)
+	}
+
+	return result, nil
+}
+
+// decodeBool 和 isBool 验证器保持一致,使用0/1,同时兼容 true/false
+func decodeBool(value string) (interface{}, error) {
+	if isBool(value, nil) {
+		return value == `1`, nil
+	}
+
+	return strconv.ParseBool(value)
+}
+
+func decodeInts(value, delimiter string) (interface{}, error) {
+	fields := strings.Split(value, delimiter)
+	result := make([]int64, 0, len(fields))
+
+	for _, field := range fields {
+		temp, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, `[%s]不是整数`, field)
+		}
+
+		result = append(result, temp)
+	}
+
+	return result, nil
+}
+
+func decodeTronAddresses(value string) (interface{}, error) {
+	if !tronAddresses(value, nil) {
+		return nil, fmt.Errorf(validateFailFMT, value, `tronAddresses`)
+	}
+
+	return strings.Split(value, ListDelimiter), nil
+}
+
+/*
+GetDuration 获取时长参数
+参数:
+*	key     	string       	参数key
+*	fallback	...time.Duration	参数不存在时的默认值
+返回值:
+*	value   	time.Duration	值
+*	err     	error        	错误
+*/
+func (s *service) GetDuration(key string, fallback ...time.Duration) (value time.Duration, err error) {
+	return Get[time.Duration](s, key, fallback...)
+}
+
+/*
+GetBool 获取布尔参数,0/1
+参数:
+*	key     	string  	参数key
+*	fallback	...bool 	参数不存在时的默认值
+返回值:
+*	value   	bool    	值
+*	err     	error   	错误
+*/
+func (s *service) GetBool(key string, fallback ...bool) (value bool, err error) {
+	return Get[bool](s, key, fallback...)
+}
+
+/*
+GetTronAddresses 获取逗号分隔的波场地址列表,每个地址都经过验证
+参数:
+*	key     	string    	参数key
+*	fallback	...[]string	参数不存在时的默认值
+返回值:
+*	value   	[]string  	地址列表
+*	err     	error     	错误
+*/
+func (s *service) GetTronAddresses(key string, fallback ...[]string) (value []string, err error) {
+	return get[[]string](s, key, `tronAddresses`, decodeTronAddresses, fallback...)
+}
@@ -0,0 +1,98 @@
+package parameters
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecoderOf(t *testing.T) {
+	tests := []struct {
+		name    string
+		decode  DecodeFunc
+		value   string
+		want    interface{}
+		wantErr bool
+	}{
+		{name: `string`, decode: decoderOf[string](), value: `a`, want: `a`},
+		{name: `bool 1`, decode: decoderOf[bool](), value: `1`, want: true},
+		{name: `bool 0`, decode: decoderOf[bool](), value: `0`, want: false},
+		{name: `bool 非法`, decode: decoderOf[bool](), value: `2`, wantErr: true},
+		{name: `int64`, decode: decoderOf[int64](), value: `12`, want: int64(12)},
+		{name: `decimal`, decode: decoderOf[decimal.Decimal](), value: `1.5`, want: decimal.RequireFromString(`1.5`)},
+		{name: `duration`, decode: decoderOf[time.Duration](), value: `30s`, want: 30 * time.Second},
+		{name: `ints`, decode: decoderOf[[]int64](), value: `1,2`, want: []int64{1, 2}},
+		{name: `ints 非法`, decode: decoderOf[[]int64](), value: `1,a`, wantErr: true},
+		{name: `json`, decode: decoderOf[map[string]int](), value: `{"a":1}`, want: map[string]int{`a`: 1}},
+		{
+			name:   `tronAddresses`,
+			decode: decodeTronAddresses,
+			value:  `TBkbH9yKoBtmPtsH5gcxgmn6rWzSREAoUU,TWN9sjAWrUEUmrCoyo3EbrDgno5ye8SyQN`,
+			want:   []string{`TBkbH9yKoBtmPtsH5gcxgmn6rWzSREAoUU`, `TWN9sjAWrUEUmrCoyo3EbrDgno5ye8SyQN`},
+		},
+		{name: `tronAddresses 非法`, decode: decodeTronAddresses, value: `TBkbH9yKoBtmPtsH5gcxgmn6rWzSREAoUC`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.decode(tt.value)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.EqualValues(t, tt.want, got)
+		})
+	}
+}
+
+func TestGet(t *testing.T) {
+	key := `test:typed`
+
+	require.NoError(t, testService.Create(NewParameter(key, `测试`, `10s`, `测试1`, `duration`)))
+
+	value, err := testService.GetDuration(key)
+	require.NoError(t, err)
+	require.EqualValues(t, 10*time.Second, value)
+
+	require.NoError(t, testService.Modify(map[string]string{key: `20s`}, 3), `修改`)
+
+	value, err = Get[time.Duration](testService, key)
+	require.NoError(t, err)
+	require.EqualValues(t, 20*time.Second, value, `修改后缓存失效`)
+
+	fallback, err := Get[int64](testService, `test:notExist`, 5)
+	require.NoError(t, err)
+	require.EqualValues(t, 5, fallback, `不存在时使用默认值`)
+
+	_, err = Get[int64](testService, `test:notExist`)
+	require.Error(t, err, `不存在且无默认值`)
+}
+
+func TestGet_copy(t *testing.T) {
+	target := &service{decoded: newDecodedCache(time.Minute)}
+
+	target.decoded.set(decodedKey{key: `ints`, kind: `[]int64`}, []int64{1, 2}, `1,2`, true)
+	target.decoded.set(decodedKey{key: `map`, kind: `json:map[string]int`}, map[string]int{`a`: 1}, `{"a":1}`, true)
+
+	ints, err := Get[[]int64](target, `ints`)
+	require.NoError(t, err)
+
+	ints[0] = 10
+
+	ints, err = Get[[]int64](target, `ints`)
+	require.NoError(t, err)
+	require.EqualValues(t, []int64{1, 2}, ints, `修改返回值不影响缓存`)
+
+	values, err := GetJSON[map[string]int](target, `map`)
+	require.NoError(t, err)
+
+	values[`a`] = 2
+
+	values, err = GetJSON[map[string]int](target, `map`)
+	require.NoError(t, err)
+	require.EqualValues(t, map[string]int{`a`: 1}, values, `修改返回值不影响缓存`)
+}
@@ -0,0 +1,257 @@
+package parameters
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/fighterlyt/common/helpers"
+	"github.com/fighterlyt/log"
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	changeChannel     = `parameters_change` // 变更通知的REDIS频道
+	watchChannelSize  = 16                  // 每个订阅者的缓冲大小
+	publishRetryLimit = 3                   // 发布通知的重试次数
+)
+
+// ParameterChange 参数变更通知
+type ParameterChange struct {
+	Key        string `json:"key"`        // 参数key
+	Value      string `json:"value"`      // 最新值
+	UserID     int64  `json:"userID"`     // 操作用户ID
+	UpdateTime int64  `json:"updateTime"` // 变更时间
+}
+
+/*
+NewParameterChange 新建变更通知
+参数:
+*	key             	string          	参数key
+*	value           	string          	最新值
+*	userID          	int64           	操作用户ID
+返回值:
+*	ParameterChange 	ParameterChange 	变更通知
+*/
+func NewParameterChange(key, value string, userID int64) ParameterChange {
+	return ParameterChange{
+		Key:        key,
+		Value:      value,
+		UserID:     userID,
+		UpdateTime: time.Now().Unix(),
+	}
+}
+
+/*
+MarshalBinary 序列化,方便REDIS PUBLISH
+参数:
+返回值:
+*	data	[]byte	数据
+*	err 	error 	错误
+*/
+func (c ParameterChange) MarshalBinary() (data []byte, err error) {
+	return json.Marshal(c)
+}
+
+// subscriber 订阅者
+type subscriber struct {
+	keys map[string]struct{}  // 关注的key,为空表示全部
+	ch   chan ParameterChange // 通知通道
+}
+
+func newSubscriber(keys []string) *subscriber {
+	result := &subscriber{
+		keys: make(map[string]struct{}, len(keys)),
+		ch:   make(chan ParameterChange, watchChannelSize),
+	}
+
+	for _, key := range keys {
+		result.keys[key] = struct{}{}
+	}
+
+	return result
+}
+
+func (s subscriber) match(key string) bool {
+	if len(s.keys) == 0 {
+		return true
+	}
+
+	_, exist := s.keys[key]
+
+	return exist
+}
+
+// watcher 基于REDIS PUB/SUB的变更通知,所有实例共享同一个频道
+type watcher struct {
+	client      *redis.Client
+	logger      log.Logger
+	pubSub      *redis.PubSub
+	lock        *sync.RWMutex
+	subscribers []*subscriber
+	closed      bool
+}
+
+func newWatcher(client *redis.Client, logger log.Logger) *watcher {
+	return &watcher{
+		client: client,
+		logger: logger,
+		lock:   &sync.RWMutex{},
+	}
+}
+
+/*
+Start 订阅REDIS频道并开始分发
+参数:
+返回值:
+*	error	error	错误
+*/
+func (w *watcher) Start() error {
+	w.pubSub = w.client.Subscribe(bg, changeChannel)
+
+	if _, err := w.pubSub.Receive(bg); err != nil {
+		return errors.Wrapf(err, `REDIS SUBSCRIBE %s`, changeChannel)
+	}
+
+	ch := w.pubSub.Channel()
+
+	helpers.EnsureGo(w.logger, func() {
+		for msg := range ch {
+			w.receive(msg)
+		}
+	})
+
+	return nil
+}
+
+func (w *watcher) receive(msg *redis.Message) {
+	change := ParameterChange{}
+
+	if err := json.Unmarshal([]byte(msg.Payload), &change); err != nil {
+		w.logger.Warn(`解析变更通知失败`, zap.String(`数据`, msg.Payload), zap.String(`错误`, err.Error()))
+
+		return
+	}
+
+	w.dispatch(change)
+}
+
+/*
+dispatch 分发到本地订阅者,订阅者处理过慢时丢弃,不能阻塞其他订阅者
+参数:
+*	change	ParameterChange	变更通知
+返回值:
+*/
+func (w *watcher) dispatch(change ParameterChange) {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	for _, sub := range w.subscribers {
+		if !sub.match(change.Key) {
+			continue
+		}
+
+		select {
+		case sub.ch <- change:
+		default:
+			w.logger.Warn(`订阅者处理过慢,丢弃变更通知`, zap.String(`key`, change.Key))
+		}
+	}
+}
+
+/*
+Publish 发布变更通知
+参数:
+*	changes	...ParameterChange	变更通知
+返回值:
+*	error  	error             	错误
+*/
+func (w *watcher) Publish(changes ...ParameterChange) error {
+	for _, change := range changes {
+		var err error
+
+		for i := 0; i < publishRetryLimit; i++ {
+			if err = w.client.Publish(bg, changeChannel, change).Err(); err == nil {
+				break
+			}
+		}
+
+		if err != nil {
+			return errors.Wrapf(err, `REDIS PUBLISH %s key[%s]`, changeChannel, change.Key)
+		}
+	}
+
+	return nil
+}
+
+/*
+Watch 订阅参数变更
+参数:
+*	keys                  	...string             	关注的key,为空表示全部
+返回值:
+*	<-chan ParameterChange	<-chan ParameterChange	通知通道,服务关闭时被关闭
+*/
+func (w *watcher) Watch(keys ...string) <-chan ParameterChange {
+	sub := newSubscriber(keys)
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.closed {
+		close(sub.ch)
+
+		return sub.ch
+	}
+
+	w.subscribers = append(w.subscribers, sub)
+
+	return sub.ch
+}
+
+/*
+Unwatch 取消订阅,通道会被关闭
+参数:
+*	ch	<-chan ParameterChange	Watch 返回的通道
+返回值:
+*/
+func (w *watcher) Unwatch(ch <-chan ParameterChange) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for i, sub := range w.subscribers {
+		if sub.ch == ch {
+			close(sub.ch)
+			w.subscribers = append(w.subscribers[:i], w.subscribers[i+1:]...)
+
+			return
+		}
+	}
+}
+
+/*
+Close 关闭订阅,所有订阅通道都会被关闭
+参数:
+返回值:
+*/
+func (w *watcher) Close() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.closed {
+		return
+	}
+
+	w.closed = true
+
+	if w.pubSub != nil {
+		helpers.IgnoreError(w.logger, `关闭REDIS订阅`, w.pubSub.Close)
+	}
+
+	for _, sub := range w.subscribers {
+		close(sub.ch)
+	}
+
+	w.subscribers = nil
+}
@@ -1,6 +1,8 @@
 package sms
 
 import (
+	"context"
+
 	"github.com/fighterlyt/common/model"
 	"github.com/pkg/errors"
 	"github.com/shopspring/decimal"
@@ -16,6 +18,15 @@ type Service interface {
 	Balance() (balance decimal.Decimal, err error)
 }
 
+// ContextService 支持传递上下文的短信服务,上下文用于链路追踪和取消
+type ContextService interface {
+	Service
+	// TemplateSendContext 同 TemplateSend
+	TemplateSendContext(ctx context.Context, target, content, id string) error
+	// BalanceContext 同 Balance
+	BalanceContext(ctx context.Context) (balance decimal.Decimal, err error)
+}
+
 type Supported int
 
 const (
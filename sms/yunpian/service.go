@@ -61,7 +61,7 @@ NewService 新建服务
 func NewService(apiKey string, timeout time.Duration, logger log.Logger, pullStatusInterval time.Duration, recordService sms.RecordAccess, retryCheckTimes int) *Service { //nolint:lll
 	service := &Service{
 		apiKey:             apiKey,
-		client:             &http.Client{},
+		client:             &http.Client{Transport: helpers.NewTracingTransport(nil)},
 		timeout:            timeout,
 		logger:             logger,
 		pullStatusInterval: pullStatusInterval,
@@ -79,6 +79,20 @@ func (s Service) DirectSend(_, _ string) error {
 }
 
 func (s Service) TemplateSend(target, content, id string) error {
+	return s.TemplateSendContext(bg, target, content, id)
+}
+
+/*
+TemplateSendContext 模板发送,ctx 中的 span 会传递给云片
+参数:
+*	ctx    	context.Context	上下文
+*	target 	string         	手机号
+*	content	string         	内容
+*	id     	string         	记录ID
+返回值:
+*	error  	error          	错误
+*/
+func (s Service) TemplateSendContext(ctx context.Context, target, content, id string) error {
 	s.logger.Info(`发送短信`, zap.Strings(`目标/内容/id`, []string{target, content, id}))
 
 	target = strings.ReplaceAll(target, `-`, ``)
@@ -95,7 +109,7 @@ func (s Service) TemplateSend(target, content, id string) error {
 
 	result := &sendResponse{}
 
-	exceeded, err := s.send(sendSMSURL, values, result, debug)
+	exceeded, err := s.send(ctx, sendSMSURL, values, result, debug)
 
 	if err != nil && !exceeded {
 		return err
@@ -158,20 +172,24 @@ func (s Service) Support(supported sms.Supported) bool {
 }
 
 func (s Service) Balance() (balance decimal.Decimal, err error) {
+	return s.BalanceContext(bg)
+}
+
+func (s Service) BalanceContext(ctx context.Context) (balance decimal.Decimal, err error) {
 	values := url.Values{}
 	values.Set(`apikey`, s.apiKey)
 
 	result := &getResponse{}
 
-	if _, err = s.send(balanceURL, values, result, debug); err != nil {
+	if _, err = s.send(ctx, balanceURL, values, result, debug); err != nil {
 		return decimal.Zero, err
 	}
 
 	return result.Balance, nil
 }
 
-func (s Service) send(url string, data url.Values, result result, debug bool) (isExceed bool, err error) {
-	ctx, cancel := context.WithTimeout(bg, s.timeout)
+func (s Service) send(ctx context.Context, url string, data url.Values, result result, debug bool) (isExceed bool, err error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
 	defer cancel()
 
 	var (
@@ -314,7 +332,7 @@ func (s Service) pullStatus() (result *pullStatusResult, err error) {
 
 	result = &pullStatusResult{}
 
-	if _, err := s.send(pullStatusURL, values, result, debug); err != nil {
+	if _, err := s.send(bg, pullStatusURL, values, result, debug); err != nil {
 		return nil, err
 	}
 
@@ -1,12 +1,18 @@
 package yunpian
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/fighterlyt/common/helpers"
 	"github.com/fighterlyt/common/sms"
 	"github.com/fighterlyt/log"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/require"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -173,3 +179,28 @@ func TestServicePullStatus(t *testing.T) {
 
 	t.Log(status)
 }
+
+func TestService_sendContext(t *testing.T) {
+	helpers.SetTimeZone(time.UTC)
+	opentracing.SetGlobalTracer(mocktracer.New())
+
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+	header := http.Header{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		_, _ = w.Write([]byte(`{"balance":1}`))
+	}))
+
+	defer server.Close()
+
+	logger, _ := log.NewEasyLogger(true, false, ``, `测试`)
+	target := Service{client: &http.Client{Transport: helpers.NewTracingTransport(nil)}, timeout: time.Second, logger: logger}
+
+	span := helpers.NewSpan(`sms`, nil)
+	result := &getResponse{}
+
+	_, err := target.send(helpers.PutSpanInCtx(span, context.Background()), server.URL, nil, result, false)
+	require.NoError(t, err)
+	require.NotEmpty(t, header.Get(`mockpfx-ids-traceid`), `调用方的span传递给下游`)
+}
@@ -0,0 +1,354 @@
+package summaryextend
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fighterlyt/common/helpers"
+	"github.com/fighterlyt/common/model"
+	"github.com/fighterlyt/log"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	maxExtendValue        = 10              // 最多支持的扩展值数量,和 Detail 一致
+	defaultBufferInterval = time.Second     // 默认刷新间隔
+	defaultBufferSize     = 500             // 默认刷新阈值
+	bufferFlushTimeout    = 5 * time.Second // 关闭时等待刷新完成的最长时间
+)
+
+var (
+	bufferDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: `summaryextend:buffer:depth`,
+		Help: `缓冲中待写入的行数`,
+	}, []string{`table`})
+
+	bufferFlushLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    `summaryextend:buffer:flushSeconds`,
+		Help:    `缓冲刷新耗时(秒)`,
+		Buckets: prometheus.DefBuckets,
+	}, []string{`table`})
+
+	bufferFlushErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: `summaryextend:buffer:flushErrors`,
+		Help: `缓冲刷新失败次数`,
+	}, []string{`table`})
+)
+
+// BufferOption 缓冲选项
+type BufferOption struct {
+	Interval time.Duration // 刷新间隔,为0使用默认值
+	Size     int           // 待写入行数达到该值时立即刷新,为0使用默认值
+}
+
+// BufferedClient 缓冲客户端,Summarize 类操作先在内存中按(ownerID,slotValue)合并,再批量写入
+type BufferedClient interface {
+	Client
+	model.Module
+	// Flush 立即写入缓冲中的数据
+	Flush() error
+}
+
+// bufferKey 合并的维度,和表的唯一索引一致
+type bufferKey struct {
+	ownerID   string
+	slotValue string
+}
+
+// bufferDelta 合并后的增量
+type bufferDelta struct {
+	value  decimal.Decimal
+	extend [maxExtendValue]decimal.Decimal
+	times  int64
+}
+
+func (d *bufferDelta) add(other *bufferDelta) {
+	d.value = d.value.Add(other.value)
+	d.times += other.times
+
+	for i := range d.extend {
+		d.extend[i] = d.extend[i].Add(other.extend[i])
+	}
+}
+
+// bufferedClient 缓冲客户端,未缓冲的操作直接使用 client
+type bufferedClient struct {
+	*client
+	option   BufferOption
+	lock     *sync.Mutex
+	flushing *sync.Mutex // 保证同一时间只有一个刷新,失败回填时不会和后续刷新交错
+	pending  map[bufferKey]*bufferDelta
+	shutdown model.Shutdown
+	closed   bool // 由 lock 保护,关闭之后的写入直接返回错误,不会在最后一次刷新之后进入缓冲
+	once     *sync.Once
+	exit     chan struct{}
+	done     chan struct{}
+	notify   chan struct{}
+}
+
+/*
+NewBufferedClient 新建缓冲客户端,按间隔或者行数阈值刷新,关闭时刷新剩余数据.
+写入是最终一致的,进程异常退出时会丢失未刷新的数据,需要精确一次的场景使用 SummarizeOnce
+参数:
+*	tableName     	string        	表名
+*	slot          	Slot          	槽位类型
+*	option        	BufferOption  	缓冲选项
+*	logger        	log.Logger    	日志器
+*	db            	*gorm.DB      	数据库
+返回值:
+*	BufferedClient	BufferedClient	客户端
+*	error         	error         	错误
+*/
+func NewBufferedClient(tableName string, slot Slot, option BufferOption, logger log.Logger, db *gorm.DB) (BufferedClient, error) {
+	base, err := NewClient(tableName, slot, logger, db)
+	if err != nil {
+		return nil, err
+	}
+
+	if option.Interval <= 0 {
+		option.Interval = defaultBufferInterval
+	}
+
+	if option.Size <= 0 {
+		option.Size = defaultBufferSize
+	}
+
+	result := &bufferedClient{
+		client:   base,
+		option:   option,
+		lock:     &sync.Mutex{},
+		flushing: &sync.Mutex{},
+		pending:  make(map[bufferKey]*bufferDelta, option.Size),
+		shutdown: model.NewShutdown(),
+		once:     &sync.Once{},
+		exit:     make(chan struct{}),
+		done:     make(chan struct{}),
+		notify:   make(chan struct{}, 1),
+	}
+
+	result.start()
+
+	return result, nil
+}
+
+func (b *bufferedClient) start() {
+	helpers.EnsureGo(b.logger, func() {
+		defer close(b.done)
+
+		ticker := time.NewTicker(b.option.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+			case <-b.notify:
+			case <-b.exit:
+				b.flushAndLog()
+
+				return
+			}
+
+			b.flushAndLog()
+		}
+	})
+}
+
+func (b *bufferedClient) flushAndLog() {
+	if err := b.Flush(); err != nil {
+		b.logger.Error(`刷新汇总缓冲失败`, zap.String(`表`, b.tableName), zap.String(`错误`, err.Error()))
+	}
+}
+
+func (b *bufferedClient) Summarize(ownerID string, amount decimal.Decimal, extendValue ...decimal.Decimal) error {
+	return b.add(ownerID, amount, 1, extendValue...)
+}
+
+func (b *bufferedClient) SummarizeOptimism(ownerID string, amount decimal.Decimal, extendValue ...decimal.Decimal) error {
+	return b.add(ownerID, amount, 1, extendValue...)
+}
+
+func (b *bufferedClient) SummarizeNotAddTimes(ownerID string, amount decimal.Decimal, extendValue ...decimal.Decimal) error {
+	return b.add(ownerID, amount, 0, extendValue...)
+}
+
+func (b *bufferedClient) RevertSummarize(ownerID string, amount decimal.Decimal, extendValue ...decimal.Decimal) error {
+	return b.add(ownerID, amount.Neg(), -1, negAll(extendValue)...)
+}
+
+func (b *bufferedClient) RevertSummarizeOptimism(ownerID string, amount decimal.Decimal, extendValue ...decimal.Decimal) error {
+	return b.add(ownerID, amount.Neg(), -1, negAll(extendValue)...)
+}
+
+func negAll(values []decimal.Decimal) []decimal.Decimal {
+	result := make([]decimal.Decimal, 0, len(values))
+
+	for _, value := range values {
+		result = append(result, value.Neg())
+	}
+
+	return result
+}
+
+/*
+add 合并增量,slotValue 在调用时计算,保证跨槽位的数据写入正确的槽位
+参数:
+*	ownerID    	string            	所有者
+*	amount     	decimal.Decimal   	值
+*	times      	int64             	次数增量
+*	extendValue	...decimal.Decimal	扩展值
+返回值:
+*	error      	error             	错误
+*/
+func (b *bufferedClient) add(ownerID string, amount decimal.Decimal, times int64, extendValue ...decimal.Decimal) error {
+	if len(extendValue) > maxExtendValue {
+		return fmt.Errorf(`最多支持%d个扩展数据`, maxExtendValue)
+	}
+
+	slotValue, err := b.getSlotValue(ownerID)
+	if err != nil {
+		return errors.Wrap(err, `计算slotValue错误`)
+	}
+
+	delta := &bufferDelta{value: amount, times: times}
+	copy(delta.extend[:], extendValue)
+
+	b.lock.Lock()
+	if b.closed {
+		b.lock.Unlock()
+
+		return errors.New(`缓冲客户端已经关闭`)
+	}
+
+	b.merge(bufferKey{ownerID: ownerID, slotValue: slotValue}, delta)
+	depth := len(b.pending)
+	b.lock.Unlock()
+
+	if depth >= b.option.Size {
+		select {
+		case b.notify <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// merge 调用方持有锁
+func (b *bufferedClient) merge(key bufferKey, delta *bufferDelta) {
+	if exist, ok := b.pending[key]; ok {
+		exist.add(delta)
+	} else {
+		b.pending[key] = delta
+	}
+
+	bufferDepth.WithLabelValues(b.tableName).Set(float64(len(b.pending)))
+}
+
+/*
+Flush 将缓冲中的数据使用多行 INSERT ... ON DUPLICATE KEY UPDATE 写入,失败时数据放回缓冲等待下次刷新
+参数:
+返回值:
+*	error	error	错误
+*/
+func (b *bufferedClient) Flush() error {
+	b.flushing.Lock()
+	defer b.flushing.Unlock()
+
+	b.lock.Lock()
+	pending := b.pending
+	b.pending = make(map[bufferKey]*bufferDelta, b.option.Size)
+	bufferDepth.WithLabelValues(b.tableName).Set(0)
+	b.lock.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	start := time.Now()
+	err := b.write(pending)
+
+	bufferFlushLatency.WithLabelValues(b.tableName).Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		bufferFlushErrors.WithLabelValues(b.tableName).Inc()
+
+		b.lock.Lock()
+		for key, delta := range pending {
+			b.merge(key, delta)
+		}
+		b.lock.Unlock()
+
+		return errors.Wrapf(err, `写入[%d]行`, len(pending))
+	}
+
+	return nil
+}
+
+func (b *bufferedClient) write(pending map[bufferKey]*bufferDelta) error {
+	rows := make([]*Detail, 0, len(pending))
+
+	for key, delta := range pending {
+		row := newSummary(b.slot, key.ownerID, delta.value, key.slotValue)
+		row.Times = delta.times
+
+		for i, extend := range delta.extend {
+			if err := row.SetExtendValue(i, extend); err != nil {
+				return err
+			}
+		}
+
+		rows = append(rows, row)
+	}
+
+	columns := []string{`value`, `times`}
+	for i := 1; i <= maxExtendValue; i++ {
+		columns = append(columns, fmt.Sprintf(`value_%d`, i))
+	}
+
+	updates := make(map[string]interface{}, len(columns))
+	for _, column := range columns {
+		updates[column] = gorm.Expr(fmt.Sprintf(`%s + VALUES(%s)`, column, column))
+	}
+
+	return b.db.Session(&gorm.Session{}).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: `ownerID`}, {Name: `slotValue`}},
+		DoUpdates: clause.Assignments(updates),
+	}).CreateInBatches(rows, b.option.Size).Error
+}
+
+/*
+Close 停止接收数据,刷新剩余数据后返回,最多等待 bufferFlushTimeout
+参数:
+返回值:
+*/
+func (b *bufferedClient) Close() {
+	b.once.Do(func() {
+		b.lock.Lock()
+		b.closed = true
+		b.lock.Unlock()
+
+		b.shutdown.Close()
+		close(b.exit)
+
+		select {
+		case <-b.done:
+		case <-time.After(bufferFlushTimeout):
+			b.logger.Error(`等待汇总缓冲刷新超时`, zap.String(`表`, b.tableName))
+		}
+	})
+}
+
+func (b *bufferedClient) IsClosed() bool {
+	return b.shutdown.IsClosed()
+}
+
+func (b *bufferedClient) Name() string {
+	return b.tableName + `汇总缓冲`
+}
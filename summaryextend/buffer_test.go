@@ -0,0 +1,67 @@
+package summaryextend
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBufferedClient_Flush(t *testing.T) {
+	target, err := NewBufferedClient(`summary_extend_buffer`, SlotWhole, BufferOption{Interval: time.Hour}, logger, db)
+	require.NoError(t, err, `构建Client`)
+
+	defer target.Close()
+
+	ownerID := `buffer_` + time.Now().Format(`150405.000`)
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, target.Summarize(ownerID, decimal.New(1, 0), decimal.New(2, 0)), `缓冲汇总`)
+	}
+
+	require.NoError(t, target.RevertSummarizeOptimism(ownerID, decimal.New(1, 0), decimal.New(2, 0)), `缓冲撤销`)
+
+	records, err := target.GetSummary([]string{ownerID}, 0, 0)
+	require.NoError(t, err, `刷新前`)
+	require.Empty(t, records, `刷新前不应写入`)
+
+	require.NoError(t, target.Flush(), `刷新`)
+
+	records, err = target.GetSummary([]string{ownerID}, 0, 0)
+	require.NoError(t, err, `刷新后`)
+	require.Len(t, records, 1)
+	require.True(t, decimal.New(9, 0).Equal(records[0].GetValue()), `合并后的值`)
+	require.True(t, decimal.New(18, 0).Equal(records[0].GetExtendValue()[0]), `合并后的扩展值`)
+	require.EqualValues(t, 9, records[0].GetTimes(), `合并后的次数`)
+}
+
+func TestBufferedClient_Close(t *testing.T) {
+	target, err := NewBufferedClient(`summary_extend_buffer`, SlotWhole, BufferOption{Interval: time.Hour}, logger, db)
+	require.NoError(t, err, `构建Client`)
+
+	ownerID := `buffer_close_` + time.Now().Format(`150405.000`)
+
+	require.NoError(t, target.Summarize(ownerID, decimal.New(1, 0)), `缓冲汇总`)
+
+	wg := &sync.WaitGroup{}
+
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			target.Close()
+		}()
+	}
+
+	wg.Wait()
+
+	require.True(t, target.IsClosed(), `并发关闭`)
+	require.Error(t, target.Summarize(ownerID, decimal.New(1, 0)), `关闭后不接收数据`)
+
+	records, err := target.GetSummary([]string{ownerID}, 0, 0)
+	require.NoError(t, err, `关闭后`)
+	require.Len(t, records, 1, `关闭时刷新剩余数据`)
+}
@@ -49,6 +49,34 @@ func (c chainClients) SummarizeDayOptimism(date int64, ownerID string, amount de
 	return nil
 }
 
+func (c chainClients) SummarizeOnce(eventID, ownerID string, amount decimal.Decimal, extendValue ...decimal.Decimal) error {
+	var (
+		err, singleErr error
+	)
+
+	for _, client := range c {
+		if singleErr = client.SummarizeOnce(eventID, ownerID, amount, extendValue...); singleErr != nil {
+			err = multierr.Append(err, errors.Wrap(singleErr, client.Key()))
+		}
+	}
+
+	return err
+}
+
+func (c chainClients) RevertSummarizeOnce(eventID, ownerID string, amount decimal.Decimal, extendValue ...decimal.Decimal) error {
+	var (
+		err, singleErr error
+	)
+
+	for _, client := range c {
+		if singleErr = client.RevertSummarizeOnce(eventID, ownerID, amount, extendValue...); singleErr != nil {
+			err = multierr.Append(err, errors.Wrap(singleErr, client.Key()))
+		}
+	}
+
+	return err
+}
+
 func (c chainClients) SummarizeNotAddTimes(ownerID string, amount decimal.Decimal, extend ...decimal.Decimal) error {
 	var (
 		err, singleErr error
@@ -2,6 +2,7 @@ package summaryextend
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/fighterlyt/common/helpers"
 	"github.com/fighterlyt/log"
@@ -20,6 +21,7 @@ type client struct {
 	logger    log.Logger // 日志器
 	db        *gorm.DB   // db
 	bigInt    bool
+	events    *eventTable // 幂等汇总的事件表
 }
 
 func (m *client) SetBigInt(on bool) {
@@ -94,6 +96,7 @@ func NewClient(tableName string, slot Slot, logger log.Logger, db *gorm.DB) (res
 		logger:    logger,
 		db:        db,
 		model:     model,
+		events:    newEventTable(tableName),
 	}, nil
 }
 
@@ -289,6 +292,33 @@ func (m client) getSlotValue(userID string) (value string, err error) {
 		return userID, nil
 	case SlotMonth:
 		return fmt.Sprintf(`%d`, helpers.GetMonthInDefault()), nil
+	default:
+		return m.slotValueByTime(time.Now().Unix())
+	}
+}
+
+/*
+slotValueByTime 时间戳所在的slotValue,SlotWhole 不依赖时间,不支持
+参数:
+*	t    	int64 	时间戳
+返回值:
+*	value	string	slotValue
+*	err  	error 	错误
+*/
+func (m client) slotValueByTime(t int64) (value string, err error) {
+	switch m.slot {
+	case SlotDay:
+		return fmt.Sprintf(`%d`, helpers.GetDateByTime(t)), nil
+	case SlotMonth:
+		return fmt.Sprintf(`%d`, helpers.GetMonthByTime(t)), nil
+	case SlotHour: // yyyymmddhh
+		return fmt.Sprintf(`%d`, helpers.GetHourByTime(t)), nil
+	case SlotWeek: // 周一的yyyymmdd
+		return fmt.Sprintf(`%d`, helpers.GetWeekByTime(t)), nil
+	case SlotQuarter: // yyyyq
+		return fmt.Sprintf(`%d`, helpers.GetQuarterByTime(t)), nil
+	case SlotYear: // yyyy
+		return fmt.Sprintf(`%d`, helpers.GetYearByTime(t)), nil
 	default:
 		return ``, newErrNotSupportSlot(m.slot)
 	}
@@ -362,12 +392,28 @@ func (m client) buildScopeByRange(from, to int64, db *gorm.DB) (query *gorm.DB,
 		return db, nil
 	}
 
+	if m.slot == SlotWhole { // 总汇总和时间无关
+		return db, nil
+	}
+
+	var (
+		slotValue string
+	)
+
 	if from != 0 && to == 0 { // 有开始无截止
-		return db.Where(`slotValue >= ?`, helpers.GetDateByTime(from)), nil
+		if slotValue, err = m.slotValueByTime(from); err != nil {
+			return nil, err
+		}
+
+		return db.Where(`slotValue >= ?`, slotValue), nil
 	}
 
 	if from == 0 && to != 0 { // 有截止无开始
-		return db.Where(`slotValue <= ?`, helpers.GetDateByTime(to)), nil
+		if slotValue, err = m.slotValueByTime(to); err != nil {
+			return nil, err
+		}
+
+		return db.Where(`slotValue <= ?`, slotValue), nil
 	}
 
 	if scope, err = m.getSlotValueByRange(from, to); err != nil { // 有开始有截止
@@ -406,6 +452,26 @@ func (m client) getSlotValueByRange(from, to int64) (scope helpers.Scope, err er
 		return func(db *gorm.DB) *gorm.DB {
 			return db.Where(`slotValue >= ? and slotValue <= ?`, fmt.Sprintf(`%d`, helpers.GetMonthByTime(from)), fmt.Sprintf(`%d`, helpers.GetMonthByTime(to))) //nolint:lll
 		}, nil
+	case SlotHour, SlotWeek, SlotQuarter, SlotYear: // 同一种slot的slotValue长度相同,可以直接比较
+		if from >= to {
+			return nil, fmt.Errorf(`开始时间[%d]必须小于结束时间[%d]`, from, to)
+		}
+
+		var (
+			start, end string
+		)
+
+		if start, err = m.slotValueByTime(from); err != nil {
+			return nil, err
+		}
+
+		if end, err = m.slotValueByTime(to); err != nil {
+			return nil, err
+		}
+
+		return func(db *gorm.DB) *gorm.DB {
+			return db.Where(`slotValue >= ? and slotValue <= ?`, start, end)
+		}, nil
 	case SlotWhole:
 		return nil, nil
 	default:
@@ -113,3 +113,64 @@ func TestClient_SummarizeFirstUpdate(t *testing.T) {
 	helpers.SetTimeZone(helpers.GetBeiJin())
 	require.NoError(t, dayClient.SummarizeOptimism(`2`, decimal.New(10, 0), decimal.New(20, 0), decimal.New(30, 0), decimal.New(40, 0)))
 }
+
+func TestClient_slotValueByTime(t *testing.T) {
+	helpers.SetTimeZone(helpers.GetBeiJin())
+
+	now := time.Date(2021, 9, 18, 15, 4, 5, 0, helpers.GetBeiJin()).Unix()
+
+	tests := []struct {
+		slot Slot
+		want string
+	}{
+		{slot: SlotDay, want: `20210918`},
+		{slot: SlotMonth, want: `202109`},
+		{slot: SlotHour, want: `2021091815`},
+		{slot: SlotWeek, want: `20210913`},
+		{slot: SlotQuarter, want: `20213`},
+		{slot: SlotYear, want: `2021`},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.slot), func(t *testing.T) {
+			value, err := client{slot: tt.slot}.slotValueByTime(now)
+			require.NoError(t, err)
+			require.EqualValues(t, tt.want, value)
+		})
+	}
+
+	_, err = client{slot: SlotWhole}.slotValueByTime(now)
+	require.Error(t, err, `总汇总不依赖时间`)
+}
+
+func TestClient_SummarizeOnce(t *testing.T) {
+	target, err := NewClient(`summary_extend_once`, SlotWhole, logger, db)
+	require.NoError(t, err, `构建Client`)
+
+	eventID := `once_` + time.Now().Format(`150405.000`)
+
+	before, err := target.GetSummary([]string{`1`}, 0, 0)
+	require.NoError(t, err, `汇总前`)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, target.SummarizeOnce(eventID, `1`, decimal.New(1, 0)), `重复汇总`)
+	}
+
+	after, err := target.GetSummary([]string{`1`}, 0, 0)
+	require.NoError(t, err, `汇总后`)
+	require.Len(t, after, 1)
+
+	expected := decimal.New(1, 0)
+	if len(before) == 1 {
+		expected = expected.Add(before[0].GetValue())
+	}
+
+	require.True(t, expected.Equal(after[0].GetValue()), `同一个事件只汇总一次`)
+
+	require.NoError(t, target.RevertSummarizeOnce(eventID, `1`, decimal.New(1, 0)), `撤销`)
+	require.NoError(t, target.RevertSummarizeOnce(eventID, `1`, decimal.New(1, 0)), `重复撤销`)
+
+	reverted, err := target.GetSummary([]string{`1`}, 0, 0)
+	require.NoError(t, err, `撤销后`)
+	require.True(t, expected.Sub(decimal.New(1, 0)).Equal(reverted[0].GetValue()), `同一个事件只撤销一次`)
+}
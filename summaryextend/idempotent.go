@@ -0,0 +1,124 @@
+package summaryextend
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	eventTableSuffix = `_event` // 事件表名后缀
+)
+
+// eventTable 事件表,第一次使用时创建,不使用幂等汇总的表不会创建
+type eventTable struct {
+	name string
+	once *sync.Once
+	err  error
+}
+
+func newEventTable(tableName string) *eventTable {
+	return &eventTable{
+		name: tableName + eventTableSuffix,
+		once: &sync.Once{},
+	}
+}
+
+func (e *eventTable) ensure(db *gorm.DB) error {
+	e.once.Do(func() {
+		if err := db.Session(&gorm.Session{NewDB: true}).Table(e.name).AutoMigrate(&Event{tableName: e.name}); err != nil {
+			e.err = errors.Wrapf(err, `创建事件表[%s]`, e.name)
+		}
+	})
+
+	return e.err
+}
+
+/*
+SummarizeOnce 按业务事件ID幂等汇总,同一个事件ID重复调用不会重复汇总,事件记录和汇总在同一个事务中
+参数:
+*	eventID    	string             	业务事件ID,例如交易哈希
+*	ownerID    	string             	所有者
+*	amount     	decimal.Decimal    	值
+*	extendValue	...decimal.Decimal 	扩展值
+返回值:
+*	error      	error              	错误
+*/
+func (m *client) SummarizeOnce(eventID, ownerID string, amount decimal.Decimal, extendValue ...decimal.Decimal) error {
+	return m.once(eventID, EventSummarize, func(target *client) error {
+		return target.Summarize(ownerID, amount, extendValue...)
+	})
+}
+
+/*
+RevertSummarizeOnce 按业务事件ID幂等撤销汇总,和 SummarizeOnce 分别去重
+参数:
+*	eventID    	string             	业务事件ID
+*	ownerID    	string             	所有者
+*	amount     	decimal.Decimal    	值
+*	extendValue	...decimal.Decimal 	扩展值
+返回值:
+*	error      	error              	错误
+*/
+func (m *client) RevertSummarizeOnce(eventID, ownerID string, amount decimal.Decimal, extendValue ...decimal.Decimal) error {
+	return m.once(eventID, EventRevert, func(target *client) error {
+		return target.RevertSummarize(ownerID, amount, extendValue...)
+	})
+}
+
+/*
+once 在事务中先记录事件,事件已经存在时直接返回
+参数:
+*	eventID	string                       	业务事件ID
+*	kind   	EventKind                    	操作类型
+*	fn     	func(target *client) error   	使用事务的客户端执行汇总
+返回值:
+*	error  	error                        	错误
+*/
+func (m *client) once(eventID string, kind EventKind, fn func(target *client) error) error {
+	if eventID == `` {
+		return errors.New(`事件ID不能为空`)
+	}
+
+	if err := m.events.ensure(m.db); err != nil {
+		return err
+	}
+
+	return m.db.Session(&gorm.Session{NewDB: true}).Transaction(func(tx *gorm.DB) error {
+		result := tx.Table(m.events.name).Clauses(clause.OnConflict{DoNothing: true}).Create(newEvent(eventID, kind, m.events.name))
+
+		if result.Error != nil {
+			return errors.Wrapf(result.Error, `记录事件[%s]`, eventID)
+		}
+
+		if result.RowsAffected == 0 { // 已经处理过
+			m.logger.Debug(`重复的事件,忽略`, zap.String(`事件ID`, eventID), zap.String(`操作类型`, string(kind)))
+
+			return nil
+		}
+
+		target := *m
+		target.db = tx.Table(m.tableName)
+
+		return fn(&target)
+	})
+}
+
+/*
+CleanEvents 删除指定时间之前的事件记录,之后这些事件ID重复调用会被再次汇总
+参数:
+*	before	int64	时间戳,秒
+返回值:
+*	error 	error	错误
+*/
+func (m *client) CleanEvents(before int64) error {
+	if err := m.events.ensure(m.db); err != nil {
+		return err
+	}
+
+	return m.db.Session(&gorm.Session{NewDB: true}).Table(m.events.name).Where(`createTime < ?`, before).Delete(&Event{}).Error
+}
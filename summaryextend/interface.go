@@ -25,6 +25,11 @@ type Client interface {
 	SummarizeOptimism(ownerID string, amount decimal.Decimal, extendValue ...decimal.Decimal) error
 
 	RevertSummarizeOptimism(ownerID string, amount decimal.Decimal, extendValue ...decimal.Decimal) error
+
+	// SummarizeOnce 按业务事件ID幂等汇总
+	SummarizeOnce(eventID, ownerID string, amount decimal.Decimal, extendValue ...decimal.Decimal) error
+	// RevertSummarizeOnce 按业务事件ID幂等撤销汇总
+	RevertSummarizeOnce(eventID, ownerID string, amount decimal.Decimal, extendValue ...decimal.Decimal) error
 }
 
 // Summary 记录抽象
@@ -4,6 +4,7 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/shopspring/decimal"
 )
@@ -17,6 +18,14 @@ const (
 	SlotMonth Slot = `月`
 	// SlotWhole 总汇总
 	SlotWhole Slot = `开天辟地到地老天荒`
+	// SlotHour 按小时汇总
+	SlotHour Slot = `小时`
+	// SlotWeek 按周汇总(ISO周,周一开始)
+	SlotWeek Slot = `周`
+	// SlotQuarter 按季度汇总
+	SlotQuarter Slot = `季度`
+	// SlotYear 按年汇总
+	SlotYear Slot = `年`
 )
 
 // Detail 真实数据
@@ -40,6 +49,37 @@ type Detail struct {
 	Times     int64           `gorm:"column:times;comment:次数" json:"次数"`
 }
 
+// Event 已经处理的业务事件,用于保证同一个事件只汇总一次
+type Event struct {
+	EventID    string    `gorm:"column:eventID;primaryKey;type:varchar(128);comment:业务事件ID" json:"eventID"` // 业务事件ID
+	Kind       EventKind `gorm:"column:kind;primaryKey;type:varchar(16);comment:操作类型" json:"kind"`          // 操作类型
+	CreateTime int64     `gorm:"column:createTime;type:bigint;index;comment:处理时间" json:"createTime"`        // 处理时间
+	tableName  string    // 表名
+}
+
+// EventKind 事件操作类型,同一个事件的汇总和撤销分别去重
+type EventKind string
+
+const (
+	// EventSummarize 汇总
+	EventSummarize EventKind = `summarize`
+	// EventRevert 撤销汇总
+	EventRevert EventKind = `revert`
+)
+
+func newEvent(eventID string, kind EventKind, tableName string) *Event {
+	return &Event{
+		EventID:    eventID,
+		Kind:       kind,
+		CreateTime: time.Now().Unix(),
+		tableName:  tableName,
+	}
+}
+
+func (e Event) TableName() string {
+	return e.tableName
+}
+
 func (s *Detail) SetValue(value decimal.Decimal) {
 	s.Value = value
 }
@@ -0,0 +1,381 @@
+package summaryextend
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/fighterlyt/log"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	metricColumnPrefix = `metric_` // 扩展值列名前缀,防止和固定列冲突
+)
+
+var (
+	metricNameRegex = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]{0,56}$`)
+)
+
+// SchemaClient 扩展值按名称声明的客户端,扩展值数量不受限制
+type SchemaClient interface {
+	Key() string
+	Metrics() []string
+	Summarize(ownerID string, amount decimal.Decimal, values map[string]decimal.Decimal) error
+	RevertSummarize(ownerID string, amount decimal.Decimal, values map[string]decimal.Decimal) error
+	SummarizeDay(date int64, ownerID string, amount decimal.Decimal, values map[string]decimal.Decimal) error
+	RevertSummarizeDay(date int64, ownerID string, amount decimal.Decimal, values map[string]decimal.Decimal) error
+	GetSummary(ownerIDs []string, from, to int64) (records []*SchemaSummary, err error)
+	GetSummarySummary(ownerIDs []string, from, to int64) (record *SchemaSummary, err error)
+}
+
+// SchemaSummary 按名称声明扩展值的汇总数据
+type SchemaSummary struct {
+	ID        int64                      `json:"id"`
+	Slot      Slot                       `json:"slot"`      // 槽位类型
+	OwnerID   string                     `json:"ownerID"`   // 所有者ID
+	Value     decimal.Decimal            `json:"value"`     // 汇总值
+	Values    map[string]decimal.Decimal `json:"values"`    // 扩展值,指标名->值
+	SlotValue string                     `json:"slotValue"` // 所属的时间
+	Times     int64                      `json:"times"`     // 次数
+}
+
+// schemaDetail 固定列,扩展值列根据声明的指标添加
+type schemaDetail struct {
+	ID        int64           `gorm:"column:id;primary_key;column:id;type:bigint(20) unsigned AUTO_INCREMENT;not null;comment:ID"`
+	Slot      Slot            `gorm:"column:slot;type:varchar(128);comment:槽位类型"`                                                              //nolint:lll    // 槽位类型
+	OwnerID   string          `gorm:"column:ownerID;uniqueIndex:ownerID_slotValue,priority:1;type:varchar(64);comment:所有者ID"`                  //nolint:lll    // 所有者ID
+	Value     decimal.Decimal `gorm:"column:value;type:decimal(30,8);comment:汇总值"`                                                             // 汇总值
+	SlotValue string          `gorm:"column:slotValue;type:varchar(64);uniqueIndex:ownerID_slotValue,priority:2;index:slotValue;comment:汇总时间"` //nolint:lll    // 所属的时间
+	Times     int64           `gorm:"column:times;comment:次数"`
+	tableName string          // 表名
+}
+
+func (s schemaDetail) TableName() string {
+	return s.tableName
+}
+
+// schemaClient 按名称声明扩展值的客户端,时间槽位的计算复用 client
+type schemaClient struct {
+	base    client            // 固定部分
+	metrics []string          // 指标名,按声明顺序
+	columns map[string]string // 指标名->列名
+}
+
+/*
+NewSchemaClient 新建按名称声明扩展值的客户端,声明的指标会自动添加到表中
+参数:
+*	tableName	string      	表名
+*	slot     	Slot        	槽位类型
+*	metrics  	[]string    	指标名,只能包含字母数字下划线,字母开头
+*	logger   	log.Logger  	日志器
+*	db       	*gorm.DB    	数据库
+返回值:
+*	result   	SchemaClient	客户端
+*	err      	error       	错误
+*/
+func NewSchemaClient(tableName string, slot Slot, metrics []string, logger log.Logger, db *gorm.DB) (result SchemaClient, err error) {
+	if db == nil {
+		return nil, errors.New(`db不能为空`)
+	}
+
+	if logger == nil {
+		return nil, errors.New(`日志器不能为空`)
+	}
+
+	if tableName == `` {
+		return nil, errors.New(`表名不能为空`)
+	}
+
+	target := &schemaClient{
+		base: client{
+			tableName: tableName,
+			slot:      slot,
+			logger:    logger,
+			db:        db.Table(tableName),
+		},
+		metrics: make([]string, 0, len(metrics)),
+		columns: make(map[string]string, len(metrics)),
+	}
+
+	for _, metric := range metrics {
+		if !metricNameRegex.MatchString(metric) {
+			return nil, fmt.Errorf(`指标名[%s]非法`, metric)
+		}
+
+		if _, exist := target.columns[metric]; exist {
+			return nil, fmt.Errorf(`指标名[%s]重复`, metric)
+		}
+
+		target.metrics = append(target.metrics, metric)
+		target.columns[metric] = metricColumnPrefix + metric
+	}
+
+	if err = target.migrate(); err != nil {
+		return nil, errors.Wrap(err, `创建表`)
+	}
+
+	return target, nil
+}
+
+/*
+migrate 创建表并添加缺少的指标列,已经存在的列不会被修改或删除
+参数:
+返回值:
+*	error	error	错误
+*/
+func (s schemaClient) migrate() error {
+	model := &schemaDetail{tableName: s.base.tableName}
+	db := s.base.db.Session(&gorm.Session{})
+
+	if err := db.AutoMigrate(model); err != nil {
+		return errors.Wrap(err, `创建固定列`)
+	}
+
+	for _, metric := range s.metrics {
+		column := s.columns[metric]
+
+		if db.Migrator().HasColumn(model, column) {
+			continue
+		}
+
+		if err := db.Exec(`ALTER TABLE ? ADD COLUMN ? decimal(30,8) NOT NULL DEFAULT 0 COMMENT ?`,
+			clause.Table{Name: s.base.tableName}, clause.Column{Name: column}, metric).Error; err != nil {
+			return errors.Wrapf(err, `添加指标列[%s]`, metric)
+		}
+	}
+
+	return nil
+}
+
+func (s schemaClient) Key() string {
+	return s.base.tableName
+}
+
+func (s schemaClient) Metrics() []string {
+	return append([]string(nil), s.metrics...)
+}
+
+/*
+Summarize 汇总
+参数:
+*	ownerID	string                    	所有者
+*	amount 	decimal.Decimal           	值
+*	values 	map[string]decimal.Decimal	扩展值,指标名->值,必须是声明过的指标
+返回值:
+*	error  	error                     	错误
+*/
+func (s schemaClient) Summarize(ownerID string, amount decimal.Decimal, values map[string]decimal.Decimal) error {
+	slotValue, err := s.base.getSlotValue(ownerID)
+	if err != nil {
+		return errors.Wrap(err, `计算slotValue错误`)
+	}
+
+	return s.upsert(slotValue, ownerID, amount, 1, values)
+}
+
+func (s schemaClient) RevertSummarize(ownerID string, amount decimal.Decimal, values map[string]decimal.Decimal) error {
+	slotValue, err := s.base.getSlotValue(ownerID)
+	if err != nil {
+		return errors.Wrap(err, `计算slotValue错误`)
+	}
+
+	return s.upsert(slotValue, ownerID, amount.Neg(), -1, negValues(values))
+}
+
+func (s schemaClient) SummarizeDay(date int64, ownerID string, amount decimal.Decimal, values map[string]decimal.Decimal) error {
+	return s.upsert(fmt.Sprintf(`%d`, date), ownerID, amount, 1, values)
+}
+
+func (s schemaClient) RevertSummarizeDay(date int64, ownerID string, amount decimal.Decimal, values map[string]decimal.Decimal) error {
+	return s.upsert(fmt.Sprintf(`%d`, date), ownerID, amount.Neg(), -1, negValues(values))
+}
+
+func negValues(values map[string]decimal.Decimal) map[string]decimal.Decimal {
+	result := make(map[string]decimal.Decimal, len(values))
+
+	for metric, value := range values {
+		result[metric] = value.Neg()
+	}
+
+	return result
+}
+
+func (s schemaClient) upsert(slotValue, ownerID string, amount decimal.Decimal, times int, values map[string]decimal.Decimal) error {
+	data := map[string]interface{}{
+		`slot`:      s.base.slot,
+		`ownerID`:   ownerID,
+		`value`:     amount,
+		`slotValue`: slotValue,
+		`times`:     times,
+	}
+
+	updates := map[string]interface{}{
+		"value": gorm.Expr(`value + ?`, amount),
+	}
+
+	if times != 0 {
+		updates["times"] = gorm.Expr(`times + ?`, times)
+	}
+
+	for metric, value := range values {
+		column, exist := s.columns[metric]
+		if !exist {
+			return fmt.Errorf(`指标[%s]未声明`, metric)
+		}
+
+		if value.IsZero() {
+			continue
+		}
+
+		data[column] = value
+		updates[column] = gorm.Expr(fmt.Sprintf(`%s + ?`, column), value)
+	}
+
+	if err := s.base.db.Session(&gorm.Session{}).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: `ownerID`}, {Name: `slotValue`}},
+		DoUpdates: clause.Assignments(updates),
+	}).Create(data).Error; err != nil {
+		return errors.WithMessage(err, s.base.tableName)
+	}
+
+	return nil
+}
+
+func (s schemaClient) GetSummary(ownerIDs []string, from, to int64) (records []*SchemaSummary, err error) {
+	query := s.base.db.Session(&gorm.Session{})
+	if len(ownerIDs) > 0 {
+		query = query.Where(`ownerID in ?`, ownerIDs)
+	}
+
+	if query, err = s.base.buildScopeByRange(from, to, query); err != nil {
+		return nil, errors.Wrap(err, `构建时间查询`)
+	}
+
+	var (
+		rows []map[string]interface{}
+	)
+
+	if err = query.Find(&rows).Error; err != nil {
+		return nil, errors.Wrap(err, `数据库操作`)
+	}
+
+	records = make([]*SchemaSummary, 0, len(rows))
+
+	for _, row := range rows {
+		record, convertErr := s.convert(row)
+		if convertErr != nil {
+			return nil, convertErr
+		}
+
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+func (s schemaClient) GetSummarySummary(ownerIDs []string, from, to int64) (record *SchemaSummary, err error) {
+	query := s.base.db.Session(&gorm.Session{})
+	if len(ownerIDs) > 0 {
+		query = query.Where(`ownerID in ?`, ownerIDs)
+	}
+
+	if query, err = s.base.buildScopeByRange(from, to, query); err != nil {
+		return nil, errors.Wrap(err, `构建时间查询`)
+	}
+
+	selects := make([]string, 0, len(s.metrics)+2)
+	selects = append(selects, `sum(value) as value`, `sum(times) as times`)
+
+	for _, metric := range s.metrics {
+		selects = append(selects, fmt.Sprintf(`sum(%[1]s) as %[1]s`, s.columns[metric]))
+	}
+
+	row := map[string]interface{}{}
+
+	if err = query.Select(strings.Join(selects, `,`)).Take(&row).Error; err != nil {
+		return nil, errors.Wrap(err, `数据库操作`)
+	}
+
+	return s.convert(row)
+}
+
+/*
+convert 将查询结果转换为 SchemaSummary
+参数:
+*	row           	map[string]interface{}	列名->值
+返回值:
+*	record        	*SchemaSummary        	数据
+*	err           	error                 	错误
+*/
+func (s schemaClient) convert(row map[string]interface{}) (record *SchemaSummary, err error) {
+	record = &SchemaSummary{
+		Slot:      Slot(toString(row[`slot`])),
+		OwnerID:   toString(row[`ownerID`]),
+		SlotValue: toString(row[`slotValue`]),
+		Values:    make(map[string]decimal.Decimal, len(s.metrics)),
+	}
+
+	if record.Value, err = toDecimal(row[`value`]); err != nil {
+		return nil, errors.Wrap(err, `value`)
+	}
+
+	var (
+		temp decimal.Decimal
+	)
+
+	if temp, err = toDecimal(row[`id`]); err != nil {
+		return nil, errors.Wrap(err, `id`)
+	}
+
+	record.ID = temp.IntPart()
+
+	if temp, err = toDecimal(row[`times`]); err != nil {
+		return nil, errors.Wrap(err, `times`)
+	}
+
+	record.Times = temp.IntPart()
+
+	for _, metric := range s.metrics {
+		if record.Values[metric], err = toDecimal(row[s.columns[metric]]); err != nil {
+			return nil, errors.Wrap(err, metric)
+		}
+	}
+
+	return record, nil
+}
+
+func toString(value interface{}) string {
+	switch data := value.(type) {
+	case nil:
+		return ``
+	case []byte:
+		return string(data)
+	default:
+		return fmt.Sprintf(`%v`, data)
+	}
+}
+
+func toDecimal(value interface{}) (decimal.Decimal, error) {
+	switch data := value.(type) {
+	case nil:
+		return decimal.Zero, nil
+	case decimal.Decimal:
+		return data, nil
+	case float64:
+		return decimal.NewFromFloat(data), nil
+	case float32:
+		return decimal.NewFromFloat32(data), nil
+	case int64:
+		return decimal.NewFromInt(data), nil
+	case uint64:
+		return decimal.NewFromInt(int64(data)), nil
+	case int32:
+		return decimal.NewFromInt32(data), nil
+	default:
+		return decimal.NewFromString(toString(data))
+	}
+}
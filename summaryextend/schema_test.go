@@ -0,0 +1,40 @@
+package summaryextend
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchemaClient_Summarize(t *testing.T) {
+	metrics := []string{`deposit`, `withdraw`, `bet`, `win`, `bonus`, `rebate`, `fee`, `refund`, `commission`, `transfer`, `adjust`}
+
+	schemaClient, err := NewSchemaClient(`summary_extend_schema`, SlotDay, metrics, logger, db)
+	require.NoError(t, err, `构建Client`)
+
+	before, err := schemaClient.GetSummarySummary([]string{`1`}, 0, 0)
+	require.NoError(t, err)
+
+	require.NoError(t, schemaClient.Summarize(`1`, decimal.New(1, 0), map[string]decimal.Decimal{
+		`deposit`: decimal.New(2, 0),
+		`adjust`:  decimal.New(3, 0),
+	}))
+
+	after, err := schemaClient.GetSummarySummary([]string{`1`}, 0, 0)
+	require.NoError(t, err)
+
+	require.True(t, after.Value.Sub(before.Value).Equal(decimal.New(1, 0)), `value`)
+	require.True(t, after.Values[`deposit`].Sub(before.Values[`deposit`]).Equal(decimal.New(2, 0)), `deposit`)
+	require.True(t, after.Values[`adjust`].Sub(before.Values[`adjust`]).Equal(decimal.New(3, 0)), `第11个指标`)
+
+	require.Error(t, schemaClient.Summarize(`1`, decimal.New(1, 0), map[string]decimal.Decimal{`notExist`: decimal.New(1, 0)}), `未声明的指标`)
+}
+
+func TestNewSchemaClient_InvalidMetric(t *testing.T) {
+	_, err := NewSchemaClient(`summary_extend_schema`, SlotDay, []string{`value; drop table a`}, logger, db)
+	require.Error(t, err, `非法指标名`)
+
+	_, err = NewSchemaClient(`summary_extend_schema`, SlotDay, []string{`a`, `a`}, logger, db)
+	require.Error(t, err, `重复指标名`)
+}
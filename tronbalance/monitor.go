@@ -0,0 +1,341 @@
+package tronbalance
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fighterlyt/common/alert"
+	"github.com/fighterlyt/common/durablemetrics"
+	"github.com/fighterlyt/common/helpers"
+	"github.com/fighterlyt/common/model"
+	"github.com/fighterlyt/common/parameters"
+	"github.com/fighterlyt/log"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+)
+
+const (
+	monitorKey             = `tronBalanceMonitor` // 模块标识
+	defaultMonitorInterval = time.Minute          // 默认查询间隔
+)
+
+// WatchAddress 监控的地址
+type WatchAddress struct {
+	Label         string            // 标签,例如 归集/提现/手续费
+	Address       string            // 地址
+	Tokens        []string          // 代币,model.TRX、TRC20代币名称或者TRC10代币ID
+	ThresholdKeys map[string]string // 代币->告警阈值的业务参数key,余额低于阈值时告警,不设置不告警
+}
+
+// AddressBalance 地址余额
+type AddressBalance struct {
+	Label      string                     `json:"label"`      // 标签
+	Address    string                     `json:"address"`    // 地址
+	Balances   map[string]decimal.Decimal `json:"balances"`   // 代币余额
+	UpdateTime int64                      `json:"updateTime"` // 最后更新时间
+}
+
+// Monitor 多地址多代币余额监控
+type Monitor interface {
+	model.Module
+	// Start 开始定时查询
+	Start()
+	// Watch 添加或者更新监控地址
+	Watch(addresses ...WatchAddress)
+	// Unwatch 取消监控地址
+	Unwatch(addresses ...string)
+	// Check 查询一次全部地址
+	Check() error
+	// Balances 最近一次查询的余额,按标签和地址排序
+	Balances() []AddressBalance
+}
+
+// gauge 监控数据,durablemetrics.GaugeVec 实现了该接口
+type gauge interface {
+	WithLabelValuesSet(f float64, lvs ...string)
+	DeleteLabelValues(lvs ...string)
+}
+
+var (
+	// walletGauge prometheus 指标只能注册一次,所有监控共用
+	walletGauge     *durablemetrics.GaugeVec
+	walletGaugeErr  error
+	walletGaugeOnce = &sync.Once{}
+)
+
+type monitor struct {
+	reader   BalanceReader
+	helper   parameters.Decoder
+	alerter  alert.Service
+	gauge    gauge
+	interval time.Duration
+	logger   log.Logger
+	lock     *sync.RWMutex
+	watched  map[string]WatchAddress
+	balances map[string]*AddressBalance
+	alerted  map[string]bool // 地址+代币 是否处于告警中,恢复前不重复告警
+	shutdown model.Shutdown
+	exit     chan struct{}
+}
+
+/*
+NewMonitor 新建余额监控
+参数:
+*	reader  	BalanceReader           	余额查询,见 NewTronBalanceReader
+*	helper  	parameters.Decoder      	业务参数,用于读取告警阈值,为空不告警
+*	alerter 	alert.Service           	告警服务,为空不告警
+*	interval	time.Duration           	查询间隔,为0使用默认值
+*	logger  	log.Logger              	日志器
+返回值:
+*	Monitor 	Monitor                 	监控
+*	error   	error                   	错误
+*/
+func NewMonitor(reader BalanceReader, helper parameters.Decoder, alerter alert.Service, interval time.Duration, logger log.Logger) (Monitor, error) { // nolint:lll
+	walletGaugeOnce.Do(func() {
+		walletGauge, walletGaugeErr = durablemetrics.NewGaugeVec("wallet_balances", "钱包余额", []string{"label", "address", "token"}, logger)
+	})
+
+	if walletGaugeErr != nil {
+		return nil, errors.Wrap(walletGaugeErr, "启动监控失败")
+	}
+
+	return newMonitor(reader, helper, alerter, walletGauge, interval, logger), nil
+}
+
+func newMonitor(reader BalanceReader, helper parameters.Decoder, alerter alert.Service, gauge gauge, interval time.Duration, logger log.Logger) *monitor { // nolint:lll
+	if interval <= 0 {
+		interval = defaultMonitorInterval
+	}
+
+	return &monitor{
+		reader:   reader,
+		helper:   helper,
+		alerter:  alerter,
+		gauge:    gauge,
+		interval: interval,
+		logger:   logger.Derive(`余额监控`),
+		lock:     &sync.RWMutex{},
+		watched:  make(map[string]WatchAddress),
+		balances: make(map[string]*AddressBalance),
+		alerted:  make(map[string]bool),
+		shutdown: model.NewShutdown(),
+		exit:     make(chan struct{}),
+	}
+}
+
+func (m *monitor) Start() {
+	helpers.EnsureGo(m.logger, func() {
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+
+		for {
+			if err := m.Check(); err != nil {
+				m.logger.Warn(`查询余额失败`, helpers.ZapError(err))
+			}
+
+			select {
+			case <-ticker.C:
+			case <-m.exit:
+				return
+			}
+		}
+	})
+}
+
+func (m *monitor) Watch(addresses ...WatchAddress) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for _, address := range addresses {
+		if old, exist := m.watched[address.Address]; exist {
+			m.forgetLocked(old, address)
+		}
+
+		m.watched[address.Address] = address
+	}
+}
+
+func (m *monitor) Unwatch(addresses ...string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for _, address := range addresses {
+		if old, exist := m.watched[address]; exist {
+			m.forgetLocked(old, WatchAddress{})
+		}
+
+		delete(m.watched, address)
+		delete(m.balances, address)
+	}
+}
+
+/*
+forgetLocked 删除不再监控的代币的监控数据和告警状态,调用方持有锁
+参数:
+*	old 	WatchAddress	原来的监控
+*	keep	WatchAddress	新的监控,标签相同时其中的代币保留
+返回值:
+*/
+func (m *monitor) forgetLocked(old, keep WatchAddress) {
+	kept := make(map[string]struct{}, len(keep.Tokens))
+
+	if keep.Label == old.Label {
+		for _, token := range keep.Tokens {
+			kept[token] = struct{}{}
+		}
+	}
+
+	for _, token := range old.Tokens {
+		if _, exist := kept[token]; exist {
+			continue
+		}
+
+		m.gauge.DeleteLabelValues(old.Label, old.Address, token)
+		delete(m.alerted, old.Address+`:`+token)
+	}
+}
+
+/*
+Check 查询全部地址的余额,更新监控数据并检查告警阈值,单个地址失败不影响其他地址
+参数:
+返回值:
+*	err	error	错误
+*/
+func (m *monitor) Check() (err error) {
+	m.lock.RLock()
+	watched := make([]WatchAddress, 0, len(m.watched))
+
+	for _, address := range m.watched {
+		watched = append(watched, address)
+	}
+	m.lock.RUnlock()
+
+	for _, address := range watched {
+		balance := &AddressBalance{
+			Label:      address.Label,
+			Address:    address.Address,
+			Balances:   make(map[string]decimal.Decimal, len(address.Tokens)),
+			UpdateTime: time.Now().Unix(),
+		}
+
+		for _, token := range address.Tokens {
+			value, balanceErr := m.reader.Balance(address.Address, token)
+			if balanceErr != nil {
+				err = multierr.Append(err, errors.Wrapf(balanceErr, `查询[%s][%s]的[%s]余额`, address.Label, address.Address, token))
+				continue
+			}
+
+			balance.Balances[token] = value
+
+			f, _ := value.Float64()
+			m.gauge.WithLabelValuesSet(f, address.Label, address.Address, token)
+
+			if thresholdErr := m.checkThreshold(address, token, value); thresholdErr != nil {
+				err = multierr.Append(err, thresholdErr)
+			}
+		}
+
+		m.lock.Lock()
+		// 查询期间可能已经取消监控
+		if current, exist := m.watched[address.Address]; exist {
+			m.balances[address.Address] = balance
+
+			if current.Label != address.Label {
+				m.forgetLocked(address, current)
+			}
+		} else {
+			m.forgetLocked(address, WatchAddress{})
+		}
+		m.lock.Unlock()
+	}
+
+	return err
+}
+
+/*
+checkThreshold 余额低于阈值时告警,恢复后发送恢复通知
+参数:
+*	address	WatchAddress   	地址
+*	token  	string         	代币
+*	value  	decimal.Decimal	余额
+返回值:
+*	error  	error          	错误
+*/
+func (m *monitor) checkThreshold(address WatchAddress, token string, value decimal.Decimal) error {
+	key, exist := address.ThresholdKeys[token]
+	if !exist || m.helper == nil || m.alerter == nil {
+		return nil
+	}
+
+	threshold, err := parameters.Get[decimal.Decimal](m.helper, key, decimal.Zero)
+	if err != nil {
+		return errors.Wrapf(err, `获取[%s][%s]告警阈值`, address.Label, token)
+	}
+
+	alertKey := address.Address + `:` + token
+	below := value.LessThan(threshold)
+
+	m.lock.Lock()
+	changed := m.alerted[alertKey] != below
+	m.alerted[alertKey] = below
+	m.lock.Unlock()
+
+	if !changed {
+		return nil
+	}
+
+	if below {
+		m.logger.Warn(`余额低于阈值`, zap.String(`地址`, address.Address), zap.String(`代币`, token), zap.String(`余额`, value.String()))
+		m.alerter.SendText(fmt.Sprintf(`[%s]%s 的 %s 余额 %s 低于 %s`, address.Label, address.Address, token, value.String(), threshold.String()))
+	} else {
+		m.alerter.SendText(fmt.Sprintf(`[%s]%s 的 %s 余额 %s 已恢复`, address.Label, address.Address, token, value.String()))
+	}
+
+	return nil
+}
+
+func (m *monitor) Balances() []AddressBalance {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	result := make([]AddressBalance, 0, len(m.balances))
+
+	for _, balance := range m.balances {
+		result = append(result, *balance)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Label != result[j].Label {
+			return result[i].Label < result[j].Label
+		}
+
+		return result[i].Address < result[j].Address
+	})
+
+	return result
+}
+
+func (m *monitor) Close() {
+	if m.shutdown.IsClosed() {
+		return
+	}
+
+	m.shutdown.Close()
+	close(m.exit)
+}
+
+func (m *monitor) IsClosed() bool {
+	return m.shutdown.IsClosed()
+}
+
+func (m *monitor) Key() string {
+	return monitorKey
+}
+
+func (m *monitor) Name() string {
+	return `余额监控`
+}
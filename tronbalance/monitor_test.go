@@ -0,0 +1,111 @@
+package tronbalance
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/fighterlyt/common/model"
+	"github.com/fighterlyt/common/parameters"
+	"github.com/fighterlyt/log"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+type mockReader map[string]decimal.Decimal
+
+func (m mockReader) Balance(address, token string) (decimal.Decimal, error) {
+	value, exist := m[address+token]
+	if !exist {
+		return decimal.Zero, errors.New(`mock`)
+	}
+
+	return value, nil
+}
+
+type mockHelper struct {
+	values map[string]string
+}
+
+func (m mockHelper) Decode(key, _ string, decode parameters.DecodeFunc) (value interface{}, exist bool, err error) {
+	raw, exist := m.values[key]
+	if !exist {
+		return nil, false, nil
+	}
+
+	value, err = decode(raw)
+
+	return value, true, err
+}
+
+type mockAlerter struct {
+	texts []string
+}
+
+func (m *mockAlerter) SendText(msg string) {
+	m.texts = append(m.texts, msg)
+}
+
+func (m *mockAlerter) SendMarkDown(md string) {
+	m.texts = append(m.texts, md)
+}
+
+type mockGauge map[string]float64
+
+func (m mockGauge) WithLabelValuesSet(f float64, lvs ...string) {
+	m[lvs[1]+lvs[2]] = f
+}
+
+func (m mockGauge) DeleteLabelValues(lvs ...string) {
+	delete(m, lvs[1]+lvs[2])
+}
+
+func TestMonitor_Check(t *testing.T) {
+	logger, err := log.NewEasyLogger(true, false, ``, `余额监控`)
+	require.NoError(t, err)
+
+	reader := mockReader{`aTRX`: decimal.New(5, 0), `aUSDT`: decimal.New(100, 0), `bTRX`: decimal.New(1, 0)}
+	alerter := &mockAlerter{}
+	gauge := mockGauge{}
+	helper := mockHelper{values: map[string]string{`threshold`: `10`}}
+
+	target := newMonitor(reader, helper, alerter, gauge, 0, logger)
+	target.Watch(
+		WatchAddress{Label: `归集`, Address: `a`, Tokens: []string{model.TRX, model.USDT}, ThresholdKeys: map[string]string{model.TRX: `threshold`}},
+		WatchAddress{Label: `提现`, Address: `b`, Tokens: []string{model.TRX, model.USDT}},
+	)
+
+	require.Error(t, target.Check(), `b 的 USDT 查询失败`)
+	require.Equal(t, mockGauge{`aTRX`: 5, `aUSDT`: 100, `bTRX`: 1}, gauge)
+	require.Len(t, alerter.texts, 1, `a 的 TRX 低于阈值`)
+
+	balances := target.Balances()
+	require.Len(t, balances, 2)
+	require.Equal(t, `归集`, balances[0].Label)
+	require.True(t, balances[0].Balances[model.USDT].Equal(decimal.New(100, 0)))
+
+	_ = target.Check()
+	require.Len(t, alerter.texts, 1, `持续低于阈值不重复告警`)
+
+	reader[`aTRX`] = decimal.New(20, 0)
+	_ = target.Check()
+	require.Len(t, alerter.texts, 2, `恢复通知`)
+
+	target.Unwatch(`b`)
+	require.NoError(t, target.Check())
+	require.Len(t, target.Balances(), 1)
+	require.Equal(t, mockGauge{`aTRX`: 20, `aUSDT`: 100}, gauge, `取消监控后删除监控数据`)
+
+	reader[`aTRX`] = decimal.New(5, 0)
+	_ = target.Check()
+	require.Len(t, alerter.texts, 3)
+
+	target.Unwatch(`a`)
+	require.Empty(t, gauge)
+	require.Empty(t, target.alerted, `取消监控后删除告警状态`)
+}
+
+func TestIsAccountNotFound(t *testing.T) {
+	require.True(t, isAccountNotFound(errors.New(`account not found`)), `gotron 返回的账户不存在`)
+	require.False(t, isAccountNotFound(errors.New(`rpc error: code = Unavailable`)), `其他错误`)
+	require.Equal(t, `account not found`, ErrAccountNotFound.Error(), `不修改包变量`)
+}
@@ -0,0 +1,173 @@
+package tronbalance
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/fighterlyt/common/model"
+	"github.com/fighterlyt/gotron-sdk/pkg/client"
+	"github.com/fighterlyt/gotron-sdk/pkg/proto/core"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+// ErrAccountNotFound tron账户不存在的异常
+var ErrAccountNotFound = errors.New("account not found")
+
+// BalanceReader 余额查询
+type BalanceReader interface {
+	// Balance 查询地址的代币余额,token 为 model.TRX、TRC20代币名称或者TRC10代币ID
+	Balance(address, token string) (decimal.Decimal, error)
+}
+
+type tronBalanceReader struct {
+	tronClient *client.GrpcClient
+	lock       *sync.RWMutex
+	precisions map[string]int32 // TRC20合约和TRC10代币的精度,发行后不会变化
+}
+
+/*
+NewTronBalanceReader 新建波场余额查询
+参数:
+*	tronClient   	*client.GrpcClient	tron客户端
+返回值:
+*	BalanceReader	BalanceReader     	余额查询
+*/
+func NewTronBalanceReader(tronClient *client.GrpcClient) BalanceReader {
+	return &tronBalanceReader{
+		tronClient: tronClient,
+		lock:       &sync.RWMutex{},
+		precisions: make(map[string]int32),
+	}
+}
+
+func (t tronBalanceReader) Balance(address, token string) (decimal.Decimal, error) {
+	switch {
+	case token == model.TRX:
+		return t.trxBalance(address)
+	case isTRC10(token):
+		return t.trc10Balance(address, token)
+	default:
+		return t.contractBalance(address, token)
+	}
+}
+
+// isTRC10 TRC10代币ID是纯数字
+func isTRC10(token string) bool {
+	if token == `` {
+		return false
+	}
+
+	for _, c := range token {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// 查询trx余额
+func (t tronBalanceReader) trxBalance(address string) (decimal.Decimal, error) {
+	account, err := t.account(address)
+	if err != nil || account == nil {
+		return decimal.Zero, err
+	}
+
+	return decimal.NewFromInt(account.Balance).Mul(decimal.New(1, -6)), nil
+}
+
+// 查询TRC10余额
+func (t tronBalanceReader) trc10Balance(address, token string) (decimal.Decimal, error) {
+	account, err := t.account(address)
+	if err != nil || account == nil {
+		return decimal.Zero, err
+	}
+
+	precision, err := t.precision(token, func() (int32, error) {
+		asset, assetErr := t.tronClient.GetAssetIssueByID(token)
+		if assetErr != nil {
+			return 0, assetErr
+		}
+
+		return asset.GetPrecision(), nil
+	})
+	if err != nil {
+		return decimal.Zero, errors.Wrapf(err, "获取代币[%s]精度失败", token)
+	}
+
+	return decimal.New(account.GetAssetV2()[token], -precision), nil
+}
+
+// account 查询账户,账户未激活时返回nil
+func (t tronBalanceReader) account(address string) (*core.Account, error) {
+	account, err := t.tronClient.GetAccount(address)
+	if err != nil {
+		// 这个异常是tron账户未激活
+		if isAccountNotFound(err) {
+			return nil, nil
+		}
+
+		return nil, errors.Wrap(err, "获取tron余额失败")
+	}
+
+	return account, nil
+}
+
+// isAccountNotFound gotron 使用 fmt.Errorf 返回账户不存在,只能比较错误信息
+func isAccountNotFound(err error) bool {
+	return errors.Cause(err).Error() == ErrAccountNotFound.Error()
+}
+
+// 查询合约币种余额
+func (t tronBalanceReader) contractBalance(address, currency string) (decimal.Decimal, error) {
+	contract, err := model.Trc20.ContractLocator().GetContract(currency)
+	if err != nil {
+		return decimal.Zero, errors.Wrapf(err, "获取合约[%s]地址失败", currency)
+	}
+
+	if contract == nil {
+		return decimal.Zero, fmt.Errorf("不支持的代币[%s]", currency)
+	}
+
+	balance, err := t.tronClient.TRC20ContractBalance(address, contract.Address())
+	if err != nil {
+		return decimal.Zero, errors.Wrap(err, "获取代币余额失败")
+	}
+
+	// 小数点位数
+	decimals, err := t.precision(contract.Address(), func() (int32, error) {
+		value, decimalsErr := t.tronClient.TRC20GetDecimals(contract.Address())
+		if decimalsErr != nil {
+			return 0, decimalsErr
+		}
+
+		return int32(value.Int64()), nil
+	})
+	if err != nil {
+		return decimal.Zero, errors.Wrap(err, "获取代币余额失败")
+	}
+
+	return decimal.NewFromBigInt(balance, -decimals), nil
+}
+
+func (t tronBalanceReader) precision(key string, load func() (int32, error)) (int32, error) {
+	t.lock.RLock()
+	precision, exist := t.precisions[key]
+	t.lock.RUnlock()
+
+	if exist {
+		return precision, nil
+	}
+
+	precision, err := load()
+	if err != nil {
+		return 0, err
+	}
+
+	t.lock.Lock()
+	t.precisions[key] = precision
+	t.lock.Unlock()
+
+	return precision, nil
+}
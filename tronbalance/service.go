@@ -1,7 +1,6 @@
 package tronbalance
 
 import (
-	"fmt"
 	"time"
 
 	"github.com/fighterlyt/common/helpers"
@@ -14,10 +13,7 @@ import (
 	"gorm.io/gorm"
 )
 
-// ErrAccountNotFound tron账户不存在的异常
-var ErrAccountNotFound = errors.New("account not found")
-
-// Service 余额服务
+// Service 余额服务,只监控归集和提现两个钱包的TRX和一个TRC20代币,多地址多代币使用 Monitor
 type Service struct {
 	db                    *gorm.DB                                                   // 客户端
 	checkBalanceInterval  time.Duration                                              // 查询余额间隔时间
@@ -30,6 +26,7 @@ type Service struct {
 	withdrawAddress       string                                                     // 提现钱包地址
 	walletMetrics         *metrics                                                   // 监控信息
 	currency              string                                                     // 查询的币种
+	reader                BalanceReader                                              // 余额查询
 }
 
 func NewService(db *gorm.DB, tronClient *client.GrpcClient, currency string, checkBalanceInterval time.Duration, logger log.Logger, getBalanceFunc func() (collectAddress, withdrawAddress string, err error)) (*Service, error) { // nolint:golint,lll
@@ -48,6 +45,7 @@ func NewService(db *gorm.DB, tronClient *client.GrpcClient, currency string, che
 		currency:              currency,
 		collectWalletBalance:  newWalletBalance(),
 		withdrawWalletBalance: newWalletBalance(),
+		reader:                NewTronBalanceReader(tronClient),
 	}
 
 	helpers.EnsureGo(logger, func() {
@@ -143,50 +141,5 @@ func (s *Service) checkTrxAndUsdt(address string) (map[string]decimal.Decimal, e
 
 // CheckBalance 查询余额
 func (s *Service) checkBalance(address, currency string) (decimal.Decimal, error) {
-	switch currency {
-	case model.TRX:
-		return s.checkTrxBalance(address)
-	default:
-		return s.checkContractBalance(address, currency)
-	}
-}
-
-// 查询trx余额
-func (s Service) checkTrxBalance(address string) (decimal.Decimal, error) {
-	account, err := s.tronClient.GetAccount(address)
-	if err != nil {
-		// 这个异常是tron账户未激活
-		if errors.As(err, &ErrAccountNotFound) {
-			return decimal.Zero, nil
-		}
-
-		return decimal.Zero, errors.Wrap(err, "获取tron余额失败")
-	}
-
-	return decimal.NewFromInt(account.Balance).Mul(decimal.New(1, -6)), nil
-}
-
-// 查询合约币种余额
-func (s Service) checkContractBalance(address, currency string) (decimal.Decimal, error) {
-	contract, err := model.Trc20.ContractLocator().GetContract(currency)
-	if err != nil {
-		return decimal.Zero, errors.Wrapf(err, "获取合约[%s]地址失败", currency)
-	}
-
-	if contract == nil {
-		return decimal.Zero, fmt.Errorf("不支持的代币[%s]", currency)
-	}
-
-	balance, err := s.tronClient.TRC20ContractBalance(address, contract.Address())
-	if err != nil {
-		return decimal.Zero, errors.Wrap(err, "获取代币余额失败")
-	}
-
-	// 小数点位数
-	decimals, err := s.tronClient.TRC20GetDecimals(contract.Address())
-	if err != nil {
-		return decimal.Zero, errors.Wrap(err, "获取代币余额失败")
-	}
-
-	return decimal.NewFromBigInt(balance, -int32(decimals.Int64())), nil
+	return s.reader.Balance(address, currency)
 }